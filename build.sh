@@ -36,8 +36,11 @@ fi
 # note: the environment variables are coupled to the environment described in docker-compose.yaml
 POSTGRES_DB=some_db POSTGRES_PASSWORD=some-password djangolang template
 
-# dump out the OpenAPI v3 schema for the Djangolang API
-./pkg/djangolang_example/bin/djangolang_example dump-openapi-json >./schema/openapi.json
+# build the Djangolang API plus the extensions (which live outside the templated folder so that they survive templating)
+go build -o ./pkg/djangolang_example_extensions/bin/djangolang_example_extensions ./pkg/djangolang_example_extensions/cmd
+
+# dump out the OpenAPI v3 schema for the Djangolang API plus the extensions
+./pkg/djangolang_example_extensions/bin/djangolang_example_extensions dump-openapi-json >./schema/openapi.json
 
 # generate the client for use by the frontend
 cd frontend
@@ -0,0 +1,13 @@
+DROP RULE IF EXISTS "delete_physical_things_cascade_to_geofence_events" ON "physical_things";
+
+DROP TABLE IF EXISTS public.geofence_events CASCADE;
+
+DROP FUNCTION IF EXISTS create_geofence_events;
+
+DROP FUNCTION IF EXISTS update_geofence_events;
+
+DROP TABLE IF EXISTS public.geofences CASCADE;
+
+DROP FUNCTION IF EXISTS create_geofences;
+
+DROP FUNCTION IF EXISTS update_geofences;
@@ -0,0 +1,159 @@
+--
+-- geofences
+--
+DROP TABLE IF EXISTS public.geofences CASCADE;
+
+CREATE TABLE
+    public.geofences (
+        id uuid PRIMARY KEY NOT NULL UNIQUE DEFAULT gen_random_uuid (),
+        created_at timestamptz NOT NULL DEFAULT now(),
+        updated_at timestamptz NOT NULL DEFAULT now(),
+        deleted_at timestamptz NULL DEFAULT NULL,
+        name text NOT NULL CHECK (trim(name) != ''),
+        polygon polygon NOT NULL
+    );
+
+ALTER TABLE public.geofences OWNER TO postgres;
+
+CREATE UNIQUE INDEX geofences_unique_name_not_deleted ON public.geofences (name)
+WHERE
+    deleted_at IS null;
+
+CREATE UNIQUE INDEX geofences_unique_name_deleted ON public.geofences (name, deleted_at)
+WHERE
+    deleted_at IS NOT null;
+
+--
+-- geofence_events
+--
+DROP TABLE IF EXISTS public.geofence_events CASCADE;
+
+CREATE TABLE
+    public.geofence_events (
+        id uuid PRIMARY KEY NOT NULL UNIQUE DEFAULT gen_random_uuid (),
+        created_at timestamptz NOT NULL DEFAULT now(),
+        updated_at timestamptz NOT NULL DEFAULT now(),
+        deleted_at timestamptz NULL DEFAULT NULL,
+        timestamp timestamptz NOT NULL,
+        type text NOT NULL CHECK (type IN ('enter', 'exit')),
+        geofence_id uuid NOT NULL REFERENCES public.geofences (id),
+        physical_thing_id uuid NOT NULL REFERENCES public.physical_things (id),
+        location_history_id uuid NULL REFERENCES public.location_history (id)
+    );
+
+ALTER TABLE public.geofence_events OWNER TO postgres;
+
+CREATE INDEX geofence_events_physical_thing_id_geofence_id_timestamp ON public.geofence_events (
+    physical_thing_id,
+    geofence_id,
+    timestamp DESC
+)
+WHERE
+    deleted_at IS null;
+
+--
+-- triggers for geofences
+--
+CREATE
+OR REPLACE FUNCTION create_geofences () RETURNS TRIGGER AS $$
+BEGIN
+  NEW.created_at = now();
+  NEW.updated_at = now();
+  NEW.deleted_at = null;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER create_geofences BEFORE INSERT ON geofences FOR EACH ROW
+EXECUTE PROCEDURE create_geofences ();
+
+CREATE
+OR REPLACE FUNCTION update_geofences () RETURNS TRIGGER AS $$
+BEGIN
+  NEW.created_at = OLD.created_at;
+  NEW.updated_at = now();
+  IF OLD.deleted_at IS NOT null AND NEW.deleted_at IS NOT null THEN
+    NEW.deleted_at = OLD.deleted_at;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_geofences BEFORE
+UPDATE ON geofences FOR EACH ROW
+EXECUTE PROCEDURE update_geofences ();
+
+CREATE RULE "delete_geofences" AS ON DELETE TO "geofences"
+DO INSTEAD (
+    UPDATE geofences
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now()
+    WHERE
+        id = old.id
+        AND deleted_at IS null
+);
+
+CREATE RULE "delete_geofences_cascade_to_geofence_events" AS ON DELETE TO "geofences"
+DO ALSO (
+    DELETE FROM geofence_events
+    WHERE
+        geofence_id = old.id
+        AND deleted_at IS null
+);
+
+--
+-- triggers for geofence_events
+--
+CREATE
+OR REPLACE FUNCTION create_geofence_events () RETURNS TRIGGER AS $$
+BEGIN
+  NEW.created_at = now();
+  NEW.updated_at = now();
+  NEW.deleted_at = null;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER create_geofence_events BEFORE INSERT ON geofence_events FOR EACH ROW
+EXECUTE PROCEDURE create_geofence_events ();
+
+CREATE
+OR REPLACE FUNCTION update_geofence_events () RETURNS TRIGGER AS $$
+BEGIN
+  NEW.created_at = OLD.created_at;
+  NEW.updated_at = now();
+  IF OLD.deleted_at IS NOT null AND NEW.deleted_at IS NOT null THEN
+    NEW.deleted_at = OLD.deleted_at;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_geofence_events BEFORE
+UPDATE ON geofence_events FOR EACH ROW
+EXECUTE PROCEDURE update_geofence_events ();
+
+CREATE RULE "delete_geofence_events" AS ON DELETE TO "geofence_events"
+DO INSTEAD (
+    UPDATE geofence_events
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now()
+    WHERE
+        id = old.id
+        AND deleted_at IS null
+);
+
+--
+-- triggers for physical_things
+--
+CREATE RULE "delete_physical_things_cascade_to_geofence_events" AS ON DELETE TO "physical_things"
+DO ALSO (
+    DELETE FROM geofence_events
+    WHERE
+        physical_thing_id = old.id
+        AND deleted_at IS null
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS public.location_history_parent_physical_thing_id_timestamp;
+
+DROP INDEX IF EXISTS public.location_history_timestamp;
+
+DROP INDEX IF EXISTS public.location_history_point;
@@ -0,0 +1,14 @@
+--
+-- location_history
+--
+CREATE INDEX location_history_point ON public.location_history USING gist (point)
+WHERE
+    deleted_at IS null;
+
+CREATE INDEX location_history_timestamp ON public.location_history (timestamp)
+WHERE
+    deleted_at IS null;
+
+CREATE INDEX location_history_parent_physical_thing_id_timestamp ON public.location_history (parent_physical_thing_id, timestamp)
+WHERE
+    deleted_at IS null;
@@ -0,0 +1,111 @@
+--
+-- physical_things
+--
+CREATE OR REPLACE RULE "delete_physical_things" AS ON DELETE TO "physical_things"
+DO INSTEAD (
+    UPDATE physical_things
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now()
+    WHERE
+        id = old.id
+        AND deleted_at IS null
+);
+
+DROP TRIGGER IF EXISTS set_deletion_batch_id_physical_things ON public.physical_things;
+
+DROP INDEX IF EXISTS public.physical_things_deletion_batch_id;
+
+ALTER TABLE public.physical_things
+DROP COLUMN IF EXISTS deletion_batch_id;
+
+--
+-- logical_things
+--
+CREATE OR REPLACE RULE "delete_logical_things" AS ON DELETE TO "logical_things"
+DO INSTEAD (
+    UPDATE logical_things
+    SET
+        created_at = old.created_at,
+        updated_at = old.updated_at,
+        deleted_at = now()
+    WHERE
+        id = old.id
+        AND deleted_at IS null
+);
+
+DROP TRIGGER IF EXISTS set_deletion_batch_id_logical_things ON public.logical_things;
+
+DROP INDEX IF EXISTS public.logical_things_deletion_batch_id;
+
+ALTER TABLE public.logical_things
+DROP COLUMN IF EXISTS deletion_batch_id;
+
+--
+-- location_history
+--
+CREATE OR REPLACE RULE "delete_location_history" AS ON DELETE TO "location_history"
+DO INSTEAD (
+    UPDATE location_history
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now()
+    WHERE
+        id = old.id
+        AND deleted_at IS null
+);
+
+DROP TRIGGER IF EXISTS set_deletion_batch_id_location_history ON public.location_history;
+
+DROP INDEX IF EXISTS public.location_history_deletion_batch_id;
+
+ALTER TABLE public.location_history
+DROP COLUMN IF EXISTS deletion_batch_id;
+
+--
+-- geofences
+--
+CREATE OR REPLACE RULE "delete_geofences" AS ON DELETE TO "geofences"
+DO INSTEAD (
+    UPDATE geofences
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now()
+    WHERE
+        id = old.id
+        AND deleted_at IS null
+);
+
+DROP TRIGGER IF EXISTS set_deletion_batch_id_geofences ON public.geofences;
+
+DROP INDEX IF EXISTS public.geofences_deletion_batch_id;
+
+ALTER TABLE public.geofences
+DROP COLUMN IF EXISTS deletion_batch_id;
+
+--
+-- geofence_events
+--
+CREATE OR REPLACE RULE "delete_geofence_events" AS ON DELETE TO "geofence_events"
+DO INSTEAD (
+    UPDATE geofence_events
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now()
+    WHERE
+        id = old.id
+        AND deleted_at IS null
+);
+
+DROP TRIGGER IF EXISTS set_deletion_batch_id_geofence_events ON public.geofence_events;
+
+DROP INDEX IF EXISTS public.geofence_events_deletion_batch_id;
+
+ALTER TABLE public.geofence_events
+DROP COLUMN IF EXISTS deletion_batch_id;
+
+DROP FUNCTION IF EXISTS set_deletion_batch_id ();
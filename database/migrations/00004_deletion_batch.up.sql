@@ -0,0 +1,177 @@
+--
+-- a soft-delete stamps the rows it deletes (including those deleted by the cascade rules) with the id of the deleting
+-- transaction, so that a restore can bring back exactly the rows that were deleted along with a row; a row that was
+-- soft-deleted by setting deleted_at (e.g. PhysicalThing.Delete, which then also issues a DELETE) gets its batch from
+-- that DELETE, and the batch is cleared whenever deleted_at is
+--
+CREATE
+OR REPLACE FUNCTION set_deletion_batch_id () RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.deleted_at IS null THEN
+    NEW.deletion_batch_id = null;
+  ELSIF TG_OP = 'UPDATE' AND OLD.deletion_batch_id IS NOT null THEN
+    NEW.deletion_batch_id = OLD.deletion_batch_id;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+--
+-- physical_things
+--
+ALTER TABLE public.physical_things
+ADD COLUMN deletion_batch_id bigint NULL DEFAULT NULL;
+
+CREATE INDEX physical_things_deletion_batch_id ON public.physical_things (deletion_batch_id)
+WHERE
+    deletion_batch_id IS NOT null;
+
+-- note: named so as to run after the create_ / update_ triggers (which settle deleted_at)
+CREATE TRIGGER set_deletion_batch_id_physical_things BEFORE INSERT
+OR
+UPDATE ON physical_things FOR EACH ROW
+EXECUTE PROCEDURE set_deletion_batch_id ();
+
+CREATE OR REPLACE RULE "delete_physical_things" AS ON DELETE TO "physical_things"
+DO INSTEAD (
+    UPDATE physical_things
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+--
+-- logical_things
+--
+ALTER TABLE public.logical_things
+ADD COLUMN deletion_batch_id bigint NULL DEFAULT NULL;
+
+CREATE INDEX logical_things_deletion_batch_id ON public.logical_things (deletion_batch_id)
+WHERE
+    deletion_batch_id IS NOT null;
+
+-- note: named so as to run after the create_ / update_ triggers (which settle deleted_at)
+CREATE TRIGGER set_deletion_batch_id_logical_things BEFORE INSERT
+OR
+UPDATE ON logical_things FOR EACH ROW
+EXECUTE PROCEDURE set_deletion_batch_id ();
+
+CREATE OR REPLACE RULE "delete_logical_things" AS ON DELETE TO "logical_things"
+DO INSTEAD (
+    UPDATE logical_things
+    SET
+        created_at = old.created_at,
+        updated_at = old.updated_at,
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+--
+-- location_history
+--
+ALTER TABLE public.location_history
+ADD COLUMN deletion_batch_id bigint NULL DEFAULT NULL;
+
+CREATE INDEX location_history_deletion_batch_id ON public.location_history (deletion_batch_id)
+WHERE
+    deletion_batch_id IS NOT null;
+
+-- note: named so as to run after the create_ / update_ triggers (which settle deleted_at)
+CREATE TRIGGER set_deletion_batch_id_location_history BEFORE INSERT
+OR
+UPDATE ON location_history FOR EACH ROW
+EXECUTE PROCEDURE set_deletion_batch_id ();
+
+CREATE OR REPLACE RULE "delete_location_history" AS ON DELETE TO "location_history"
+DO INSTEAD (
+    UPDATE location_history
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+--
+-- geofences
+--
+ALTER TABLE public.geofences
+ADD COLUMN deletion_batch_id bigint NULL DEFAULT NULL;
+
+CREATE INDEX geofences_deletion_batch_id ON public.geofences (deletion_batch_id)
+WHERE
+    deletion_batch_id IS NOT null;
+
+-- note: named so as to run after the create_ / update_ triggers (which settle deleted_at)
+CREATE TRIGGER set_deletion_batch_id_geofences BEFORE INSERT
+OR
+UPDATE ON geofences FOR EACH ROW
+EXECUTE PROCEDURE set_deletion_batch_id ();
+
+CREATE OR REPLACE RULE "delete_geofences" AS ON DELETE TO "geofences"
+DO INSTEAD (
+    UPDATE geofences
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+--
+-- geofence_events
+--
+ALTER TABLE public.geofence_events
+ADD COLUMN deletion_batch_id bigint NULL DEFAULT NULL;
+
+CREATE INDEX geofence_events_deletion_batch_id ON public.geofence_events (deletion_batch_id)
+WHERE
+    deletion_batch_id IS NOT null;
+
+-- note: named so as to run after the create_ / update_ triggers (which settle deleted_at)
+CREATE TRIGGER set_deletion_batch_id_geofence_events BEFORE INSERT
+OR
+UPDATE ON geofence_events FOR EACH ROW
+EXECUTE PROCEDURE set_deletion_batch_id ();
+
+CREATE OR REPLACE RULE "delete_geofence_events" AS ON DELETE TO "geofence_events"
+DO INSTEAD (
+    UPDATE geofence_events
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
@@ -0,0 +1,126 @@
+--
+-- rules for physical_things
+--
+CREATE OR REPLACE RULE "delete_physical_things" AS ON DELETE TO "physical_things"
+DO INSTEAD (
+    UPDATE physical_things
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+CREATE OR REPLACE RULE "delete_physical_things_cascade_to_logical_things" AS ON DELETE TO "physical_things"
+DO ALSO (
+    DELETE FROM logical_things
+    WHERE
+        parent_physical_thing_id = old.id
+        AND deleted_at IS null
+);
+
+CREATE OR REPLACE RULE "delete_physical_things_cascade_to_location_history" AS ON DELETE TO "physical_things"
+DO ALSO (
+    DELETE FROM location_history
+    WHERE
+        parent_physical_thing_id = old.id
+        AND deleted_at IS null
+);
+
+CREATE OR REPLACE RULE "delete_physical_things_cascade_to_geofence_events" AS ON DELETE TO "physical_things"
+DO ALSO (
+    DELETE FROM geofence_events
+    WHERE
+        physical_thing_id = old.id
+        AND deleted_at IS null
+);
+
+--
+-- rules for logical_things
+--
+CREATE OR REPLACE RULE "delete_logical_things" AS ON DELETE TO "logical_things"
+DO INSTEAD (
+    UPDATE logical_things
+    SET
+        created_at = old.created_at,
+        updated_at = old.updated_at,
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+--
+-- rules for location_history
+--
+CREATE OR REPLACE RULE "delete_location_history" AS ON DELETE TO "location_history"
+DO INSTEAD (
+    UPDATE location_history
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+--
+-- rules for geofences
+--
+CREATE OR REPLACE RULE "delete_geofences" AS ON DELETE TO "geofences"
+DO INSTEAD (
+    UPDATE geofences
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
+
+CREATE OR REPLACE RULE "delete_geofences_cascade_to_geofence_events" AS ON DELETE TO "geofences"
+DO ALSO (
+    DELETE FROM geofence_events
+    WHERE
+        geofence_id = old.id
+        AND deleted_at IS null
+);
+
+--
+-- rules for geofence_events
+--
+CREATE OR REPLACE RULE "delete_geofence_events" AS ON DELETE TO "geofence_events"
+DO INSTEAD (
+    UPDATE geofence_events
+    SET
+        created_at = old.created_at,
+        updated_at = now(),
+        deleted_at = now(),
+        deletion_batch_id = txid_current()
+    WHERE
+        id = old.id
+        AND (
+            deleted_at IS null
+            OR deletion_batch_id IS null
+        )
+);
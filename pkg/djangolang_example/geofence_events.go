@@ -0,0 +1,1437 @@
+package djangolang_example
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cridenour/go-postgis"
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/introspect"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang/pkg/types"
+	_pgtype "github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lib/pq/hstore"
+	"github.com/paulmach/orb/geojson"
+	"golang.org/x/exp/maps"
+)
+
+type GeofenceEvent struct {
+	ID                      uuid.UUID        `json:"id"`
+	CreatedAt               time.Time        `json:"created_at"`
+	UpdatedAt               time.Time        `json:"updated_at"`
+	DeletedAt               *time.Time       `json:"deleted_at"`
+	Timestamp               time.Time        `json:"timestamp"`
+	Type                    string           `json:"type"`
+	GeofenceID              uuid.UUID        `json:"geofence_id"`
+	GeofenceIDObject        *Geofence        `json:"geofence_id_object"`
+	PhysicalThingID         uuid.UUID        `json:"physical_thing_id"`
+	PhysicalThingIDObject   *PhysicalThing   `json:"physical_thing_id_object"`
+	LocationHistoryID       *uuid.UUID       `json:"location_history_id"`
+	LocationHistoryIDObject *LocationHistory `json:"location_history_id_object"`
+	DeletionBatchID         *int64           `json:"deletion_batch_id"`
+}
+
+var GeofenceEventTable = "geofence_events"
+
+var (
+	GeofenceEventTableIDColumn                = "id"
+	GeofenceEventTableCreatedAtColumn         = "created_at"
+	GeofenceEventTableUpdatedAtColumn         = "updated_at"
+	GeofenceEventTableDeletedAtColumn         = "deleted_at"
+	GeofenceEventTableTimestampColumn         = "timestamp"
+	GeofenceEventTableTypeColumn              = "type"
+	GeofenceEventTableGeofenceIDColumn        = "geofence_id"
+	GeofenceEventTablePhysicalThingIDColumn   = "physical_thing_id"
+	GeofenceEventTableLocationHistoryIDColumn = "location_history_id"
+	GeofenceEventTableDeletionBatchIDColumn   = "deletion_batch_id"
+)
+
+var (
+	GeofenceEventTableIDColumnWithTypeCast                = fmt.Sprintf(`"id" AS id`)
+	GeofenceEventTableCreatedAtColumnWithTypeCast         = fmt.Sprintf(`"created_at" AS created_at`)
+	GeofenceEventTableUpdatedAtColumnWithTypeCast         = fmt.Sprintf(`"updated_at" AS updated_at`)
+	GeofenceEventTableDeletedAtColumnWithTypeCast         = fmt.Sprintf(`"deleted_at" AS deleted_at`)
+	GeofenceEventTableTimestampColumnWithTypeCast         = fmt.Sprintf(`"timestamp" AS timestamp`)
+	GeofenceEventTableTypeColumnWithTypeCast              = fmt.Sprintf(`"type" AS type`)
+	GeofenceEventTableGeofenceIDColumnWithTypeCast        = fmt.Sprintf(`"geofence_id" AS geofence_id`)
+	GeofenceEventTablePhysicalThingIDColumnWithTypeCast   = fmt.Sprintf(`"physical_thing_id" AS physical_thing_id`)
+	GeofenceEventTableLocationHistoryIDColumnWithTypeCast = fmt.Sprintf(`"location_history_id" AS location_history_id`)
+	GeofenceEventTableDeletionBatchIDColumnWithTypeCast   = fmt.Sprintf(`"deletion_batch_id" AS deletion_batch_id`)
+)
+
+var GeofenceEventTableColumns = []string{
+	GeofenceEventTableIDColumn,
+	GeofenceEventTableCreatedAtColumn,
+	GeofenceEventTableUpdatedAtColumn,
+	GeofenceEventTableDeletedAtColumn,
+	GeofenceEventTableTimestampColumn,
+	GeofenceEventTableTypeColumn,
+	GeofenceEventTableGeofenceIDColumn,
+	GeofenceEventTablePhysicalThingIDColumn,
+	GeofenceEventTableLocationHistoryIDColumn,
+	GeofenceEventTableDeletionBatchIDColumn,
+}
+
+var GeofenceEventTableColumnsWithTypeCasts = []string{
+	GeofenceEventTableIDColumnWithTypeCast,
+	GeofenceEventTableCreatedAtColumnWithTypeCast,
+	GeofenceEventTableUpdatedAtColumnWithTypeCast,
+	GeofenceEventTableDeletedAtColumnWithTypeCast,
+	GeofenceEventTableTimestampColumnWithTypeCast,
+	GeofenceEventTableTypeColumnWithTypeCast,
+	GeofenceEventTableGeofenceIDColumnWithTypeCast,
+	GeofenceEventTablePhysicalThingIDColumnWithTypeCast,
+	GeofenceEventTableLocationHistoryIDColumnWithTypeCast,
+	GeofenceEventTableDeletionBatchIDColumnWithTypeCast,
+}
+
+var GeofenceEventTableColumnLookup = map[string]*introspect.Column{
+	GeofenceEventTableIDColumn:                new(introspect.Column),
+	GeofenceEventTableCreatedAtColumn:         new(introspect.Column),
+	GeofenceEventTableUpdatedAtColumn:         new(introspect.Column),
+	GeofenceEventTableDeletedAtColumn:         new(introspect.Column),
+	GeofenceEventTableTimestampColumn:         new(introspect.Column),
+	GeofenceEventTableTypeColumn:              new(introspect.Column),
+	GeofenceEventTableGeofenceIDColumn:        new(introspect.Column),
+	GeofenceEventTablePhysicalThingIDColumn:   new(introspect.Column),
+	GeofenceEventTableLocationHistoryIDColumn: new(introspect.Column),
+	GeofenceEventTableDeletionBatchIDColumn:   new(introspect.Column),
+}
+
+var (
+	GeofenceEventTablePrimaryKeyColumn = GeofenceEventTableIDColumn
+)
+
+var (
+	_ = time.Time{}
+	_ = uuid.UUID{}
+	_ = pq.StringArray{}
+	_ = hstore.Hstore{}
+	_ = geojson.Point{}
+	_ = pgtype.Point{}
+	_ = _pgtype.Point{}
+	_ = postgis.PointZ{}
+	_ = netip.Prefix{}
+)
+
+func (m *GeofenceEvent) GetPrimaryKeyColumn() string {
+	return GeofenceEventTablePrimaryKeyColumn
+}
+
+func (m *GeofenceEvent) GetPrimaryKeyValue() any {
+	return m.ID
+}
+
+func (m *GeofenceEvent) FromItem(item map[string]any) error {
+	if item == nil {
+		return fmt.Errorf(
+			"item unexpectedly nil during GeofenceEventFromItem",
+		)
+	}
+
+	if len(item) == 0 {
+		return fmt.Errorf(
+			"item unexpectedly empty during GeofenceEventFromItem",
+		)
+	}
+
+	wrapError := func(k string, v any, err error) error {
+		return fmt.Errorf("%v: %#+v; error: %v", k, v, err)
+	}
+
+	for k, v := range item {
+		_, ok := GeofenceEventTableColumnLookup[k]
+		if !ok {
+			return fmt.Errorf(
+				"item contained unexpected key %#+v during GeofenceEventFromItem; item: %#+v",
+				k, item,
+			)
+		}
+
+		switch k {
+		case "id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseUUID(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(uuid.UUID)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to uuid.UUID", temp1))
+				}
+			}
+
+			m.ID = temp2
+
+		case "created_at":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseTime(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(time.Time)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to time.Time", temp1))
+				}
+			}
+
+			m.CreatedAt = temp2
+
+		case "updated_at":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseTime(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(time.Time)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to time.Time", temp1))
+				}
+			}
+
+			m.UpdatedAt = temp2
+
+		case "deleted_at":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseTime(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(time.Time)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to time.Time", temp1))
+				}
+			}
+
+			m.DeletedAt = &temp2
+
+		case "timestamp":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseTime(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(time.Time)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to time.Time", temp1))
+				}
+			}
+
+			m.Timestamp = temp2
+
+		case "type":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseString(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(string)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to string", temp1))
+				}
+			}
+
+			m.Type = temp2
+
+		case "geofence_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseUUID(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(uuid.UUID)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to uuid.UUID", temp1))
+				}
+			}
+
+			m.GeofenceID = temp2
+
+		case "physical_thing_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseUUID(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(uuid.UUID)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to uuid.UUID", temp1))
+				}
+			}
+
+			m.PhysicalThingID = temp2
+
+		case "location_history_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseUUID(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(uuid.UUID)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to uuid.UUID", temp1))
+				}
+			}
+
+			m.LocationHistoryID = &temp2
+
+		case "deletion_batch_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseInt(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(int64)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to int64", temp1))
+				}
+			}
+
+			m.DeletionBatchID = &temp2
+
+		}
+	}
+
+	return nil
+}
+
+func (m *GeofenceEvent) Reload(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	includeDeleteds ...bool,
+) error {
+	extraWhere := ""
+	if len(includeDeleteds) > 0 && includeDeleteds[0] {
+		if slices.Contains(GeofenceEventTableColumns, "deleted_at") {
+			extraWhere = "\n    AND (deleted_at IS null OR deleted_at IS NOT null)"
+		}
+	}
+
+	t, err := SelectGeofenceEvent(
+		ctx,
+		tx,
+		fmt.Sprintf("%v = $1%v", m.GetPrimaryKeyColumn(), extraWhere),
+		m.GetPrimaryKeyValue(),
+	)
+	if err != nil {
+		return err
+	}
+
+	m.ID = t.ID
+	m.CreatedAt = t.CreatedAt
+	m.UpdatedAt = t.UpdatedAt
+	m.DeletedAt = t.DeletedAt
+	m.Timestamp = t.Timestamp
+	m.Type = t.Type
+	m.GeofenceID = t.GeofenceID
+	m.GeofenceIDObject = t.GeofenceIDObject
+	m.PhysicalThingID = t.PhysicalThingID
+	m.PhysicalThingIDObject = t.PhysicalThingIDObject
+	m.LocationHistoryID = t.LocationHistoryID
+	m.LocationHistoryIDObject = t.LocationHistoryIDObject
+	m.DeletionBatchID = t.DeletionBatchID
+
+	return nil
+}
+
+func (m *GeofenceEvent) Insert(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	setPrimaryKey bool,
+	setZeroValues bool,
+) error {
+	columns := make([]string, 0)
+	values := make([]any, 0)
+
+	if setPrimaryKey && (setZeroValues || !types.IsZeroUUID(m.ID)) {
+		columns = append(columns, GeofenceEventTableIDColumn)
+
+		v, err := types.FormatUUID(m.ID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.ID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.CreatedAt) {
+		columns = append(columns, GeofenceEventTableCreatedAtColumn)
+
+		v, err := types.FormatTime(m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.CreatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.UpdatedAt) {
+		columns = append(columns, GeofenceEventTableUpdatedAtColumn)
+
+		v, err := types.FormatTime(m.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.UpdatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.DeletedAt) {
+		columns = append(columns, GeofenceEventTableDeletedAtColumn)
+
+		v, err := types.FormatTime(m.DeletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.Timestamp) {
+		columns = append(columns, GeofenceEventTableTimestampColumn)
+
+		v, err := types.FormatTime(m.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Timestamp: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroString(m.Type) {
+		columns = append(columns, GeofenceEventTableTypeColumn)
+
+		v, err := types.FormatString(m.Type)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Type: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroUUID(m.GeofenceID) {
+		columns = append(columns, GeofenceEventTableGeofenceIDColumn)
+
+		v, err := types.FormatUUID(m.GeofenceID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.GeofenceID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroUUID(m.PhysicalThingID) {
+		columns = append(columns, GeofenceEventTablePhysicalThingIDColumn)
+
+		v, err := types.FormatUUID(m.PhysicalThingID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.PhysicalThingID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroUUID(m.LocationHistoryID) {
+		columns = append(columns, GeofenceEventTableLocationHistoryIDColumn)
+
+		v, err := types.FormatUUID(m.LocationHistoryID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.LocationHistoryID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) {
+		columns = append(columns, GeofenceEventTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	item, err := query.Insert(
+		ctx,
+		tx,
+		GeofenceEventTable,
+		columns,
+		nil,
+		false,
+		false,
+		GeofenceEventTableColumns,
+		values...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert %#+v: %v", m, err)
+	}
+	v := item[GeofenceEventTableIDColumn]
+
+	if v == nil {
+		return fmt.Errorf("failed to find %v in %#+v", GeofenceEventTableIDColumn, item)
+	}
+
+	wrapError := func(err error) error {
+		return fmt.Errorf(
+			"failed to treat %v: %#+v as uuid.UUID: %v",
+			GeofenceEventTableIDColumn,
+			item[GeofenceEventTableIDColumn],
+			err,
+		)
+	}
+
+	temp1, err := types.ParseUUID(v)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	temp2, ok := temp1.(uuid.UUID)
+	if !ok {
+		return wrapError(fmt.Errorf("failed to cast to uuid.UUID"))
+	}
+
+	m.ID = temp2
+
+	err = m.Reload(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to reload after insert")
+	}
+
+	return nil
+}
+
+func (m *GeofenceEvent) Update(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	setZeroValues bool,
+	forceSetValuesForFields ...string,
+) error {
+	columns := make([]string, 0)
+	values := make([]any, 0)
+
+	if setZeroValues || !types.IsZeroTime(m.CreatedAt) || slices.Contains(forceSetValuesForFields, GeofenceEventTableCreatedAtColumn) {
+		columns = append(columns, GeofenceEventTableCreatedAtColumn)
+
+		v, err := types.FormatTime(m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.CreatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.UpdatedAt) || slices.Contains(forceSetValuesForFields, GeofenceEventTableUpdatedAtColumn) {
+		columns = append(columns, GeofenceEventTableUpdatedAtColumn)
+
+		v, err := types.FormatTime(m.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.UpdatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.DeletedAt) || slices.Contains(forceSetValuesForFields, GeofenceEventTableDeletedAtColumn) {
+		columns = append(columns, GeofenceEventTableDeletedAtColumn)
+
+		v, err := types.FormatTime(m.DeletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.Timestamp) || slices.Contains(forceSetValuesForFields, GeofenceEventTableTimestampColumn) {
+		columns = append(columns, GeofenceEventTableTimestampColumn)
+
+		v, err := types.FormatTime(m.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Timestamp: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroString(m.Type) || slices.Contains(forceSetValuesForFields, GeofenceEventTableTypeColumn) {
+		columns = append(columns, GeofenceEventTableTypeColumn)
+
+		v, err := types.FormatString(m.Type)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Type: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroUUID(m.GeofenceID) || slices.Contains(forceSetValuesForFields, GeofenceEventTableGeofenceIDColumn) {
+		columns = append(columns, GeofenceEventTableGeofenceIDColumn)
+
+		v, err := types.FormatUUID(m.GeofenceID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.GeofenceID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroUUID(m.PhysicalThingID) || slices.Contains(forceSetValuesForFields, GeofenceEventTablePhysicalThingIDColumn) {
+		columns = append(columns, GeofenceEventTablePhysicalThingIDColumn)
+
+		v, err := types.FormatUUID(m.PhysicalThingID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.PhysicalThingID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroUUID(m.LocationHistoryID) || slices.Contains(forceSetValuesForFields, GeofenceEventTableLocationHistoryIDColumn) {
+		columns = append(columns, GeofenceEventTableLocationHistoryIDColumn)
+
+		v, err := types.FormatUUID(m.LocationHistoryID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.LocationHistoryID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) || slices.Contains(forceSetValuesForFields, GeofenceEventTableDeletionBatchIDColumn) {
+		columns = append(columns, GeofenceEventTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	v, err := types.FormatUUID(m.ID)
+	if err != nil {
+		return fmt.Errorf("failed to handle m.ID: %v", err)
+	}
+
+	values = append(values, v)
+
+	_, err = query.Update(
+		ctx,
+		tx,
+		GeofenceEventTable,
+		columns,
+		fmt.Sprintf("%v = $$??", GeofenceEventTableIDColumn),
+		GeofenceEventTableColumns,
+		values...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update %#+v: %v", m, err)
+	}
+
+	err = m.Reload(ctx, tx, slices.Contains(forceSetValuesForFields, "deleted_at"))
+	if err != nil {
+		return fmt.Errorf("failed to reload after update")
+	}
+
+	return nil
+}
+
+func (m *GeofenceEvent) Delete(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	hardDeletes ...bool,
+) error {
+	hardDelete := false
+	if len(hardDeletes) > 0 {
+		hardDelete = hardDeletes[0]
+	}
+
+	if !hardDelete && slices.Contains(GeofenceEventTableColumns, "deleted_at") {
+		m.DeletedAt = helpers.Ptr(time.Now().UTC())
+		err := m.Update(ctx, tx, false, "deleted_at")
+		if err != nil {
+			return fmt.Errorf("failed to soft-delete (update) %#+v: %v", m, err)
+		}
+	}
+
+	values := make([]any, 0)
+	v, err := types.FormatUUID(m.ID)
+	if err != nil {
+		return fmt.Errorf("failed to handle m.ID: %v", err)
+	}
+
+	values = append(values, v)
+
+	err = query.Delete(
+		ctx,
+		tx,
+		GeofenceEventTable,
+		fmt.Sprintf("%v = $$??", GeofenceEventTableIDColumn),
+		values...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete %#+v: %v", m, err)
+	}
+
+	_ = m.Reload(ctx, tx, true)
+
+	return nil
+}
+
+func SelectGeofenceEvents(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	limit *int,
+	offset *int,
+	values ...any,
+) ([]*GeofenceEvent, error) {
+	if slices.Contains(GeofenceEventTableColumns, "deleted_at") {
+		if !strings.Contains(where, "deleted_at") {
+			if where != "" {
+				where += "\n    AND "
+			}
+
+			where += "deleted_at IS null"
+		}
+	}
+
+	items, err := query.Select(
+		ctx,
+		tx,
+		GeofenceEventTableColumnsWithTypeCasts,
+		GeofenceEventTable,
+		where,
+		limit,
+		offset,
+		values...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call SelectGeofenceEvents; err: %v", err)
+	}
+
+	objects := make([]*GeofenceEvent, 0)
+
+	for _, item := range items {
+		object := &GeofenceEvent{}
+
+		err = object.FromItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call GeofenceEvent.FromItem; err: %v", err)
+		}
+
+		if !types.IsZeroUUID(object.GeofenceID) {
+			object.GeofenceIDObject, _ = SelectGeofence(
+				ctx,
+				tx,
+				fmt.Sprintf("%v = $1", GeofenceTablePrimaryKeyColumn),
+				object.GeofenceID,
+			)
+		}
+
+		if !types.IsZeroUUID(object.PhysicalThingID) {
+			object.PhysicalThingIDObject, _ = SelectPhysicalThing(
+				ctx,
+				tx,
+				fmt.Sprintf("%v = $1", PhysicalThingTablePrimaryKeyColumn),
+				object.PhysicalThingID,
+			)
+		}
+
+		if !types.IsZeroUUID(object.LocationHistoryID) {
+			object.LocationHistoryIDObject, _ = SelectLocationHistory(
+				ctx,
+				tx,
+				fmt.Sprintf("%v = $1", LocationHistoryTablePrimaryKeyColumn),
+				object.LocationHistoryID,
+			)
+		}
+
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+func SelectGeofenceEvent(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	values ...any,
+) (*GeofenceEvent, error) {
+	objects, err := SelectGeofenceEvents(
+		ctx,
+		tx,
+		where,
+		helpers.Ptr(2),
+		helpers.Ptr(0),
+		values...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call SelectGeofenceEvent; err: %v", err)
+	}
+
+	if len(objects) > 1 {
+		return nil, fmt.Errorf("attempt to call SelectGeofenceEvent returned more than 1 row")
+	}
+
+	if len(objects) < 1 {
+		return nil, fmt.Errorf("attempt to call SelectGeofenceEvent returned no rows")
+	}
+
+	object := objects[0]
+
+	return object, nil
+}
+
+func handleGetGeofenceEvents(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware) {
+	ctx := r.Context()
+
+	unrecognizedParams := make([]string, 0)
+	hadUnrecognizedParams := false
+
+	unparseableParams := make([]string, 0)
+	hadUnparseableParams := false
+
+	values := make([]any, 0)
+
+	wheres := make([]string, 0)
+	for rawKey, rawValues := range r.URL.Query() {
+		if rawKey == "limit" || rawKey == "offset" {
+			continue
+		}
+
+		parts := strings.Split(rawKey, "__")
+		isUnrecognized := len(parts) != 2
+
+		comparison := ""
+		isSliceComparison := false
+		isNullComparison := false
+		IsLikeComparison := false
+
+		if !isUnrecognized {
+			column := GeofenceEventTableColumnLookup[parts[0]]
+			if column == nil {
+				isUnrecognized = true
+			} else {
+				switch parts[1] {
+				case "eq":
+					comparison = "="
+				case "ne":
+					comparison = "!="
+				case "gt":
+					comparison = ">"
+				case "gte":
+					comparison = ">="
+				case "lt":
+					comparison = "<"
+				case "lte":
+					comparison = "<="
+				case "in":
+					comparison = "IN"
+					isSliceComparison = true
+				case "nin", "notin":
+					comparison = "NOT IN"
+					isSliceComparison = true
+				case "isnull":
+					comparison = "IS NULL"
+					isNullComparison = true
+				case "nisnull", "isnotnull":
+					comparison = "IS NOT NULL"
+					isNullComparison = true
+				case "l", "like":
+					comparison = "LIKE"
+					IsLikeComparison = true
+				case "nl", "nlike", "notlike":
+					comparison = "NOT LIKE"
+					IsLikeComparison = true
+				case "il", "ilike":
+					comparison = "ILIKE"
+					IsLikeComparison = true
+				case "nil", "nilike", "notilike":
+					comparison = "NOT ILIKE"
+					IsLikeComparison = true
+				default:
+					isUnrecognized = true
+				}
+			}
+		}
+
+		if isNullComparison {
+			wheres = append(wheres, fmt.Sprintf("%s %s", parts[0], comparison))
+			continue
+		}
+
+		for _, rawValue := range rawValues {
+			if isUnrecognized {
+				unrecognizedParams = append(unrecognizedParams, fmt.Sprintf("%s=%s", rawKey, rawValue))
+				hadUnrecognizedParams = true
+				continue
+			}
+
+			if hadUnrecognizedParams {
+				continue
+			}
+
+			attempts := make([]string, 0)
+
+			if !IsLikeComparison {
+				attempts = append(attempts, rawValue)
+			}
+
+			if isSliceComparison {
+				attempts = append(attempts, fmt.Sprintf("[%s]", rawValue))
+
+				vs := make([]string, 0)
+				for _, v := range strings.Split(rawValue, ",") {
+					vs = append(vs, fmt.Sprintf("\"%s\"", v))
+				}
+
+				attempts = append(attempts, fmt.Sprintf("[%s]", strings.Join(vs, ",")))
+			}
+
+			if IsLikeComparison {
+				attempts = append(attempts, fmt.Sprintf("\"%%%s%%\"", rawValue))
+			} else {
+				attempts = append(attempts, fmt.Sprintf("\"%s\"", rawValue))
+			}
+
+			var err error
+
+			for _, attempt := range attempts {
+				var value any
+				err = json.Unmarshal([]byte(attempt), &value)
+				if err == nil {
+					if isSliceComparison {
+						sliceValues, ok := value.([]any)
+						if !ok {
+							err = fmt.Errorf("failed to cast %#+v to []string", value)
+							break
+						}
+
+						values = append(values, sliceValues...)
+
+						sliceWheres := make([]string, 0)
+						for range values {
+							sliceWheres = append(sliceWheres, "$$??")
+						}
+
+						wheres = append(wheres, fmt.Sprintf("%s %s (%s)", parts[0], comparison, strings.Join(sliceWheres, ", ")))
+					} else {
+						values = append(values, value)
+						wheres = append(wheres, fmt.Sprintf("%s %s $$??", parts[0], comparison))
+					}
+
+					break
+				}
+			}
+
+			if err != nil {
+				unparseableParams = append(unparseableParams, fmt.Sprintf("%s=%s", rawKey, rawValue))
+				hadUnparseableParams = true
+				continue
+			}
+		}
+	}
+
+	if hadUnrecognizedParams {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("unrecognized params %s", strings.Join(unrecognizedParams, ", ")),
+		)
+		return
+	}
+
+	if hadUnparseableParams {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("unparseable params %s", strings.Join(unparseableParams, ", ")),
+		)
+		return
+	}
+
+	limit := 2000
+	rawLimit := r.URL.Query().Get("limit")
+	if rawLimit != "" {
+		possibleLimit, err := strconv.ParseInt(rawLimit, 10, 64)
+		if err == nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param limit=%s as int: %v", rawLimit, err),
+			)
+			return
+		}
+
+		limit = int(possibleLimit)
+	}
+
+	offset := 0
+	rawOffset := r.URL.Query().Get("offset")
+	if rawOffset != "" {
+		possibleOffset, err := strconv.ParseInt(rawOffset, 10, 64)
+		if err == nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param offset=%s as int: %v", rawOffset, err),
+			)
+			return
+		}
+
+		offset = int(possibleOffset)
+	}
+
+	requestHash, err := helpers.GetRequestHash(GeofenceEventTable, wheres, limit, offset, values, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	where := strings.Join(wheres, "\n    AND ")
+
+	objects, err := SelectGeofenceEvents(ctx, tx, where, &limit, &offset, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, objects)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+func handleGetGeofenceEvent(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	ctx := r.Context()
+
+	wheres := []string{fmt.Sprintf("%s = $$??", GeofenceEventTablePrimaryKeyColumn)}
+	values := []any{primaryKey}
+
+	requestHash, err := helpers.GetRequestHash(GeofenceEventTable, wheres, 2000, 0, values, primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	where := strings.Join(wheres, "\n    AND ")
+
+	object, err := SelectGeofenceEvent(ctx, tx, where, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, []*GeofenceEvent{object})
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+func handlePostGeofenceEvents(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware) {
+	_ = redisConn
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var allItems []map[string]any
+	err = json.Unmarshal(b, &allItems)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON list of objects: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	objects := make([]*GeofenceEvent, 0)
+	for _, item := range allItems {
+		object := &GeofenceEvent{}
+		err = object.FromItem(item)
+		if err != nil {
+			err = fmt.Errorf("failed to interpret %#+v as GeofenceEvent in item form: %v", item, err)
+			helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		objects = append(objects, object)
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for i, object := range objects {
+		err = object.Insert(r.Context(), tx, false, false)
+		if err != nil {
+			err = fmt.Errorf("failed to insert %#+v: %v", object, err)
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		objects[i] = object
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusCreated, objects)
+}
+
+func handlePutGeofenceEvent(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	_ = redisConn
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var item map[string]any
+	err = json.Unmarshal(b, &item)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON object: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	item[GeofenceEventTablePrimaryKeyColumn] = primaryKey
+
+	object := &GeofenceEvent{}
+	err = object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as GeofenceEvent in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = object.Update(r.Context(), tx, true)
+	if err != nil {
+		err = fmt.Errorf("failed to update %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusOK, []*GeofenceEvent{object})
+}
+
+func handlePatchGeofenceEvent(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	_ = redisConn
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var item map[string]any
+	err = json.Unmarshal(b, &item)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON object: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	forceSetValuesForFields := make([]string, 0)
+	for _, possibleField := range maps.Keys(item) {
+		if !slices.Contains(GeofenceEventTableColumns, possibleField) {
+			continue
+		}
+
+		forceSetValuesForFields = append(forceSetValuesForFields, possibleField)
+	}
+
+	item[GeofenceEventTablePrimaryKeyColumn] = primaryKey
+
+	object := &GeofenceEvent{}
+	err = object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as GeofenceEvent in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = object.Update(r.Context(), tx, false, forceSetValuesForFields...)
+	if err != nil {
+		err = fmt.Errorf("failed to update %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusOK, []*GeofenceEvent{object})
+}
+
+func handleDeleteGeofenceEvent(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	_ = redisConn
+
+	var item = make(map[string]any)
+
+	item[GeofenceEventTablePrimaryKeyColumn] = primaryKey
+
+	object := &GeofenceEvent{}
+	err := object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as GeofenceEvent in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = object.Delete(r.Context(), tx)
+	if err != nil {
+		err = fmt.Errorf("failed to delete %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusNoContent, nil)
+}
+
+func GetGeofenceEventRouter(db *sqlx.DB, redisConn redis.Conn, httpMiddlewares []server.HTTPMiddleware, modelMiddlewares []server.ModelMiddleware) chi.Router {
+	r := chi.NewRouter()
+
+	for _, m := range httpMiddlewares {
+		r.Use(m)
+	}
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		handleGetGeofenceEvents(w, r, db, redisConn, modelMiddlewares)
+	})
+
+	r.Get("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetGeofenceEvent(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		handlePostGeofenceEvents(w, r, db, redisConn, modelMiddlewares)
+	})
+
+	r.Put("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handlePutGeofenceEvent(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Patch("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handlePatchGeofenceEvent(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Delete("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteGeofenceEvent(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	return r
+}
+
+func NewGeofenceEventFromItem(item map[string]any) (any, error) {
+	object := &GeofenceEvent{}
+
+	err := object.FromItem(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+func init() {
+	register(
+		GeofenceEventTable,
+		GeofenceEvent{},
+		NewGeofenceEventFromItem,
+		"/geofence-events",
+		GetGeofenceEventRouter,
+	)
+}
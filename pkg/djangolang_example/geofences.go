@@ -0,0 +1,1260 @@
+package djangolang_example
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cridenour/go-postgis"
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/introspect"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang/pkg/types"
+	_pgtype "github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lib/pq/hstore"
+	"github.com/paulmach/orb/geojson"
+	"golang.org/x/exp/maps"
+)
+
+type Geofence struct {
+	ID              uuid.UUID     `json:"id"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	DeletedAt       *time.Time    `json:"deleted_at"`
+	Name            string        `json:"name"`
+	Polygon         []pgtype.Vec2 `json:"polygon"`
+	DeletionBatchID *int64        `json:"deletion_batch_id"`
+}
+
+var GeofenceTable = "geofences"
+
+var (
+	GeofenceTableIDColumn              = "id"
+	GeofenceTableCreatedAtColumn       = "created_at"
+	GeofenceTableUpdatedAtColumn       = "updated_at"
+	GeofenceTableDeletedAtColumn       = "deleted_at"
+	GeofenceTableNameColumn            = "name"
+	GeofenceTablePolygonColumn         = "polygon"
+	GeofenceTableDeletionBatchIDColumn = "deletion_batch_id"
+)
+
+var (
+	GeofenceTableIDColumnWithTypeCast              = fmt.Sprintf(`"id" AS id`)
+	GeofenceTableCreatedAtColumnWithTypeCast       = fmt.Sprintf(`"created_at" AS created_at`)
+	GeofenceTableUpdatedAtColumnWithTypeCast       = fmt.Sprintf(`"updated_at" AS updated_at`)
+	GeofenceTableDeletedAtColumnWithTypeCast       = fmt.Sprintf(`"deleted_at" AS deleted_at`)
+	GeofenceTableNameColumnWithTypeCast            = fmt.Sprintf(`"name" AS name`)
+	GeofenceTablePolygonColumnWithTypeCast         = fmt.Sprintf(`"polygon" AS polygon`)
+	GeofenceTableDeletionBatchIDColumnWithTypeCast = fmt.Sprintf(`"deletion_batch_id" AS deletion_batch_id`)
+)
+
+var GeofenceTableColumns = []string{
+	GeofenceTableIDColumn,
+	GeofenceTableCreatedAtColumn,
+	GeofenceTableUpdatedAtColumn,
+	GeofenceTableDeletedAtColumn,
+	GeofenceTableNameColumn,
+	GeofenceTablePolygonColumn,
+	GeofenceTableDeletionBatchIDColumn,
+}
+
+var GeofenceTableColumnsWithTypeCasts = []string{
+	GeofenceTableIDColumnWithTypeCast,
+	GeofenceTableCreatedAtColumnWithTypeCast,
+	GeofenceTableUpdatedAtColumnWithTypeCast,
+	GeofenceTableDeletedAtColumnWithTypeCast,
+	GeofenceTableNameColumnWithTypeCast,
+	GeofenceTablePolygonColumnWithTypeCast,
+	GeofenceTableDeletionBatchIDColumnWithTypeCast,
+}
+
+var GeofenceTableColumnLookup = map[string]*introspect.Column{
+	GeofenceTableIDColumn:              new(introspect.Column),
+	GeofenceTableCreatedAtColumn:       new(introspect.Column),
+	GeofenceTableUpdatedAtColumn:       new(introspect.Column),
+	GeofenceTableDeletedAtColumn:       new(introspect.Column),
+	GeofenceTableNameColumn:            new(introspect.Column),
+	GeofenceTablePolygonColumn:         new(introspect.Column),
+	GeofenceTableDeletionBatchIDColumn: new(introspect.Column),
+}
+
+var (
+	GeofenceTablePrimaryKeyColumn = GeofenceTableIDColumn
+)
+
+var (
+	_ = time.Time{}
+	_ = uuid.UUID{}
+	_ = pq.StringArray{}
+	_ = hstore.Hstore{}
+	_ = geojson.Point{}
+	_ = pgtype.Point{}
+	_ = _pgtype.Point{}
+	_ = postgis.PointZ{}
+	_ = netip.Prefix{}
+)
+
+func (m *Geofence) GetPrimaryKeyColumn() string {
+	return GeofenceTablePrimaryKeyColumn
+}
+
+func (m *Geofence) GetPrimaryKeyValue() any {
+	return m.ID
+}
+
+func (m *Geofence) FromItem(item map[string]any) error {
+	if item == nil {
+		return fmt.Errorf(
+			"item unexpectedly nil during GeofenceFromItem",
+		)
+	}
+
+	if len(item) == 0 {
+		return fmt.Errorf(
+			"item unexpectedly empty during GeofenceFromItem",
+		)
+	}
+
+	wrapError := func(k string, v any, err error) error {
+		return fmt.Errorf("%v: %#+v; error: %v", k, v, err)
+	}
+
+	for k, v := range item {
+		_, ok := GeofenceTableColumnLookup[k]
+		if !ok {
+			return fmt.Errorf(
+				"item contained unexpected key %#+v during GeofenceFromItem; item: %#+v",
+				k, item,
+			)
+		}
+
+		switch k {
+		case "id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseUUID(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(uuid.UUID)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to uuid.UUID", temp1))
+				}
+			}
+
+			m.ID = temp2
+
+		case "created_at":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseTime(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(time.Time)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to time.Time", temp1))
+				}
+			}
+
+			m.CreatedAt = temp2
+
+		case "updated_at":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseTime(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(time.Time)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to time.Time", temp1))
+				}
+			}
+
+			m.UpdatedAt = temp2
+
+		case "deleted_at":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseTime(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(time.Time)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to time.Time", temp1))
+				}
+			}
+
+			m.DeletedAt = &temp2
+
+		case "name":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseString(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(string)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to string", temp1))
+				}
+			}
+
+			m.Name = temp2
+
+		case "polygon":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParsePolygon(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.([]pgtype.Vec2)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to []pgtype.Vec2", temp1))
+				}
+			}
+
+			m.Polygon = temp2
+
+		case "deletion_batch_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseInt(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(int64)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to int64", temp1))
+				}
+			}
+
+			m.DeletionBatchID = &temp2
+
+		}
+	}
+
+	return nil
+}
+
+func (m *Geofence) Reload(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	includeDeleteds ...bool,
+) error {
+	extraWhere := ""
+	if len(includeDeleteds) > 0 && includeDeleteds[0] {
+		if slices.Contains(GeofenceTableColumns, "deleted_at") {
+			extraWhere = "\n    AND (deleted_at IS null OR deleted_at IS NOT null)"
+		}
+	}
+
+	t, err := SelectGeofence(
+		ctx,
+		tx,
+		fmt.Sprintf("%v = $1%v", m.GetPrimaryKeyColumn(), extraWhere),
+		m.GetPrimaryKeyValue(),
+	)
+	if err != nil {
+		return err
+	}
+
+	m.ID = t.ID
+	m.CreatedAt = t.CreatedAt
+	m.UpdatedAt = t.UpdatedAt
+	m.DeletedAt = t.DeletedAt
+	m.Name = t.Name
+	m.Polygon = t.Polygon
+	m.DeletionBatchID = t.DeletionBatchID
+
+	return nil
+}
+
+func (m *Geofence) Insert(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	setPrimaryKey bool,
+	setZeroValues bool,
+) error {
+	columns := make([]string, 0)
+	values := make([]any, 0)
+
+	if setPrimaryKey && (setZeroValues || !types.IsZeroUUID(m.ID)) {
+		columns = append(columns, GeofenceTableIDColumn)
+
+		v, err := types.FormatUUID(m.ID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.ID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.CreatedAt) {
+		columns = append(columns, GeofenceTableCreatedAtColumn)
+
+		v, err := types.FormatTime(m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.CreatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.UpdatedAt) {
+		columns = append(columns, GeofenceTableUpdatedAtColumn)
+
+		v, err := types.FormatTime(m.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.UpdatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.DeletedAt) {
+		columns = append(columns, GeofenceTableDeletedAtColumn)
+
+		v, err := types.FormatTime(m.DeletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroString(m.Name) {
+		columns = append(columns, GeofenceTableNameColumn)
+
+		v, err := types.FormatString(m.Name)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Name: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroPolygon(m.Polygon) {
+		columns = append(columns, GeofenceTablePolygonColumn)
+
+		v, err := types.FormatPolygon(m.Polygon)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Polygon: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) {
+		columns = append(columns, GeofenceTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	item, err := query.Insert(
+		ctx,
+		tx,
+		GeofenceTable,
+		columns,
+		nil,
+		false,
+		false,
+		GeofenceTableColumns,
+		values...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert %#+v: %v", m, err)
+	}
+	v := item[GeofenceTableIDColumn]
+
+	if v == nil {
+		return fmt.Errorf("failed to find %v in %#+v", GeofenceTableIDColumn, item)
+	}
+
+	wrapError := func(err error) error {
+		return fmt.Errorf(
+			"failed to treat %v: %#+v as uuid.UUID: %v",
+			GeofenceTableIDColumn,
+			item[GeofenceTableIDColumn],
+			err,
+		)
+	}
+
+	temp1, err := types.ParseUUID(v)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	temp2, ok := temp1.(uuid.UUID)
+	if !ok {
+		return wrapError(fmt.Errorf("failed to cast to uuid.UUID"))
+	}
+
+	m.ID = temp2
+
+	err = m.Reload(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to reload after insert")
+	}
+
+	return nil
+}
+
+func (m *Geofence) Update(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	setZeroValues bool,
+	forceSetValuesForFields ...string,
+) error {
+	columns := make([]string, 0)
+	values := make([]any, 0)
+
+	if setZeroValues || !types.IsZeroTime(m.CreatedAt) || slices.Contains(forceSetValuesForFields, GeofenceTableCreatedAtColumn) {
+		columns = append(columns, GeofenceTableCreatedAtColumn)
+
+		v, err := types.FormatTime(m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.CreatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.UpdatedAt) || slices.Contains(forceSetValuesForFields, GeofenceTableUpdatedAtColumn) {
+		columns = append(columns, GeofenceTableUpdatedAtColumn)
+
+		v, err := types.FormatTime(m.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.UpdatedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroTime(m.DeletedAt) || slices.Contains(forceSetValuesForFields, GeofenceTableDeletedAtColumn) {
+		columns = append(columns, GeofenceTableDeletedAtColumn)
+
+		v, err := types.FormatTime(m.DeletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletedAt: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroString(m.Name) || slices.Contains(forceSetValuesForFields, GeofenceTableNameColumn) {
+		columns = append(columns, GeofenceTableNameColumn)
+
+		v, err := types.FormatString(m.Name)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Name: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroPolygon(m.Polygon) || slices.Contains(forceSetValuesForFields, GeofenceTablePolygonColumn) {
+		columns = append(columns, GeofenceTablePolygonColumn)
+
+		v, err := types.FormatPolygon(m.Polygon)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.Polygon: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) || slices.Contains(forceSetValuesForFields, GeofenceTableDeletionBatchIDColumn) {
+		columns = append(columns, GeofenceTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	v, err := types.FormatUUID(m.ID)
+	if err != nil {
+		return fmt.Errorf("failed to handle m.ID: %v", err)
+	}
+
+	values = append(values, v)
+
+	_, err = query.Update(
+		ctx,
+		tx,
+		GeofenceTable,
+		columns,
+		fmt.Sprintf("%v = $$??", GeofenceTableIDColumn),
+		GeofenceTableColumns,
+		values...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update %#+v: %v", m, err)
+	}
+
+	err = m.Reload(ctx, tx, slices.Contains(forceSetValuesForFields, "deleted_at"))
+	if err != nil {
+		return fmt.Errorf("failed to reload after update")
+	}
+
+	return nil
+}
+
+func (m *Geofence) Delete(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	hardDeletes ...bool,
+) error {
+	hardDelete := false
+	if len(hardDeletes) > 0 {
+		hardDelete = hardDeletes[0]
+	}
+
+	if !hardDelete && slices.Contains(GeofenceTableColumns, "deleted_at") {
+		m.DeletedAt = helpers.Ptr(time.Now().UTC())
+		err := m.Update(ctx, tx, false, "deleted_at")
+		if err != nil {
+			return fmt.Errorf("failed to soft-delete (update) %#+v: %v", m, err)
+		}
+	}
+
+	values := make([]any, 0)
+	v, err := types.FormatUUID(m.ID)
+	if err != nil {
+		return fmt.Errorf("failed to handle m.ID: %v", err)
+	}
+
+	values = append(values, v)
+
+	err = query.Delete(
+		ctx,
+		tx,
+		GeofenceTable,
+		fmt.Sprintf("%v = $$??", GeofenceTableIDColumn),
+		values...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete %#+v: %v", m, err)
+	}
+
+	_ = m.Reload(ctx, tx, true)
+
+	return nil
+}
+
+func SelectGeofences(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	limit *int,
+	offset *int,
+	values ...any,
+) ([]*Geofence, error) {
+	if slices.Contains(GeofenceTableColumns, "deleted_at") {
+		if !strings.Contains(where, "deleted_at") {
+			if where != "" {
+				where += "\n    AND "
+			}
+
+			where += "deleted_at IS null"
+		}
+	}
+
+	items, err := query.Select(
+		ctx,
+		tx,
+		GeofenceTableColumnsWithTypeCasts,
+		GeofenceTable,
+		where,
+		limit,
+		offset,
+		values...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call SelectGeofences; err: %v", err)
+	}
+
+	objects := make([]*Geofence, 0)
+
+	for _, item := range items {
+		object := &Geofence{}
+
+		err = object.FromItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call Geofence.FromItem; err: %v", err)
+		}
+
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+func SelectGeofence(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	values ...any,
+) (*Geofence, error) {
+	objects, err := SelectGeofences(
+		ctx,
+		tx,
+		where,
+		helpers.Ptr(2),
+		helpers.Ptr(0),
+		values...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call SelectGeofence; err: %v", err)
+	}
+
+	if len(objects) > 1 {
+		return nil, fmt.Errorf("attempt to call SelectGeofence returned more than 1 row")
+	}
+
+	if len(objects) < 1 {
+		return nil, fmt.Errorf("attempt to call SelectGeofence returned no rows")
+	}
+
+	object := objects[0]
+
+	return object, nil
+}
+
+func handleGetGeofences(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware) {
+	ctx := r.Context()
+
+	unrecognizedParams := make([]string, 0)
+	hadUnrecognizedParams := false
+
+	unparseableParams := make([]string, 0)
+	hadUnparseableParams := false
+
+	values := make([]any, 0)
+
+	wheres := make([]string, 0)
+	for rawKey, rawValues := range r.URL.Query() {
+		if rawKey == "limit" || rawKey == "offset" {
+			continue
+		}
+
+		parts := strings.Split(rawKey, "__")
+		isUnrecognized := len(parts) != 2
+
+		comparison := ""
+		isSliceComparison := false
+		isNullComparison := false
+		IsLikeComparison := false
+
+		if !isUnrecognized {
+			column := GeofenceTableColumnLookup[parts[0]]
+			if column == nil {
+				isUnrecognized = true
+			} else {
+				switch parts[1] {
+				case "eq":
+					comparison = "="
+				case "ne":
+					comparison = "!="
+				case "gt":
+					comparison = ">"
+				case "gte":
+					comparison = ">="
+				case "lt":
+					comparison = "<"
+				case "lte":
+					comparison = "<="
+				case "in":
+					comparison = "IN"
+					isSliceComparison = true
+				case "nin", "notin":
+					comparison = "NOT IN"
+					isSliceComparison = true
+				case "isnull":
+					comparison = "IS NULL"
+					isNullComparison = true
+				case "nisnull", "isnotnull":
+					comparison = "IS NOT NULL"
+					isNullComparison = true
+				case "l", "like":
+					comparison = "LIKE"
+					IsLikeComparison = true
+				case "nl", "nlike", "notlike":
+					comparison = "NOT LIKE"
+					IsLikeComparison = true
+				case "il", "ilike":
+					comparison = "ILIKE"
+					IsLikeComparison = true
+				case "nil", "nilike", "notilike":
+					comparison = "NOT ILIKE"
+					IsLikeComparison = true
+				default:
+					isUnrecognized = true
+				}
+			}
+		}
+
+		if isNullComparison {
+			wheres = append(wheres, fmt.Sprintf("%s %s", parts[0], comparison))
+			continue
+		}
+
+		for _, rawValue := range rawValues {
+			if isUnrecognized {
+				unrecognizedParams = append(unrecognizedParams, fmt.Sprintf("%s=%s", rawKey, rawValue))
+				hadUnrecognizedParams = true
+				continue
+			}
+
+			if hadUnrecognizedParams {
+				continue
+			}
+
+			attempts := make([]string, 0)
+
+			if !IsLikeComparison {
+				attempts = append(attempts, rawValue)
+			}
+
+			if isSliceComparison {
+				attempts = append(attempts, fmt.Sprintf("[%s]", rawValue))
+
+				vs := make([]string, 0)
+				for _, v := range strings.Split(rawValue, ",") {
+					vs = append(vs, fmt.Sprintf("\"%s\"", v))
+				}
+
+				attempts = append(attempts, fmt.Sprintf("[%s]", strings.Join(vs, ",")))
+			}
+
+			if IsLikeComparison {
+				attempts = append(attempts, fmt.Sprintf("\"%%%s%%\"", rawValue))
+			} else {
+				attempts = append(attempts, fmt.Sprintf("\"%s\"", rawValue))
+			}
+
+			var err error
+
+			for _, attempt := range attempts {
+				var value any
+				err = json.Unmarshal([]byte(attempt), &value)
+				if err == nil {
+					if isSliceComparison {
+						sliceValues, ok := value.([]any)
+						if !ok {
+							err = fmt.Errorf("failed to cast %#+v to []string", value)
+							break
+						}
+
+						values = append(values, sliceValues...)
+
+						sliceWheres := make([]string, 0)
+						for range values {
+							sliceWheres = append(sliceWheres, "$$??")
+						}
+
+						wheres = append(wheres, fmt.Sprintf("%s %s (%s)", parts[0], comparison, strings.Join(sliceWheres, ", ")))
+					} else {
+						values = append(values, value)
+						wheres = append(wheres, fmt.Sprintf("%s %s $$??", parts[0], comparison))
+					}
+
+					break
+				}
+			}
+
+			if err != nil {
+				unparseableParams = append(unparseableParams, fmt.Sprintf("%s=%s", rawKey, rawValue))
+				hadUnparseableParams = true
+				continue
+			}
+		}
+	}
+
+	if hadUnrecognizedParams {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("unrecognized params %s", strings.Join(unrecognizedParams, ", ")),
+		)
+		return
+	}
+
+	if hadUnparseableParams {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("unparseable params %s", strings.Join(unparseableParams, ", ")),
+		)
+		return
+	}
+
+	limit := 2000
+	rawLimit := r.URL.Query().Get("limit")
+	if rawLimit != "" {
+		possibleLimit, err := strconv.ParseInt(rawLimit, 10, 64)
+		if err == nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param limit=%s as int: %v", rawLimit, err),
+			)
+			return
+		}
+
+		limit = int(possibleLimit)
+	}
+
+	offset := 0
+	rawOffset := r.URL.Query().Get("offset")
+	if rawOffset != "" {
+		possibleOffset, err := strconv.ParseInt(rawOffset, 10, 64)
+		if err == nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param offset=%s as int: %v", rawOffset, err),
+			)
+			return
+		}
+
+		offset = int(possibleOffset)
+	}
+
+	requestHash, err := helpers.GetRequestHash(GeofenceTable, wheres, limit, offset, values, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	where := strings.Join(wheres, "\n    AND ")
+
+	objects, err := SelectGeofences(ctx, tx, where, &limit, &offset, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, objects)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+func handleGetGeofence(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	ctx := r.Context()
+
+	wheres := []string{fmt.Sprintf("%s = $$??", GeofenceTablePrimaryKeyColumn)}
+	values := []any{primaryKey}
+
+	requestHash, err := helpers.GetRequestHash(GeofenceTable, wheres, 2000, 0, values, primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	where := strings.Join(wheres, "\n    AND ")
+
+	object, err := SelectGeofence(ctx, tx, where, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, []*Geofence{object})
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+func handlePostGeofences(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware) {
+	_ = redisConn
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var allItems []map[string]any
+	err = json.Unmarshal(b, &allItems)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON list of objects: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	objects := make([]*Geofence, 0)
+	for _, item := range allItems {
+		object := &Geofence{}
+		err = object.FromItem(item)
+		if err != nil {
+			err = fmt.Errorf("failed to interpret %#+v as Geofence in item form: %v", item, err)
+			helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		objects = append(objects, object)
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for i, object := range objects {
+		err = object.Insert(r.Context(), tx, false, false)
+		if err != nil {
+			err = fmt.Errorf("failed to insert %#+v: %v", object, err)
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		objects[i] = object
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusCreated, objects)
+}
+
+func handlePutGeofence(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	_ = redisConn
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var item map[string]any
+	err = json.Unmarshal(b, &item)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON object: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	item[GeofenceTablePrimaryKeyColumn] = primaryKey
+
+	object := &Geofence{}
+	err = object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as Geofence in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = object.Update(r.Context(), tx, true)
+	if err != nil {
+		err = fmt.Errorf("failed to update %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusOK, []*Geofence{object})
+}
+
+func handlePatchGeofence(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	_ = redisConn
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var item map[string]any
+	err = json.Unmarshal(b, &item)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON object: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	forceSetValuesForFields := make([]string, 0)
+	for _, possibleField := range maps.Keys(item) {
+		if !slices.Contains(GeofenceTableColumns, possibleField) {
+			continue
+		}
+
+		forceSetValuesForFields = append(forceSetValuesForFields, possibleField)
+	}
+
+	item[GeofenceTablePrimaryKeyColumn] = primaryKey
+
+	object := &Geofence{}
+	err = object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as Geofence in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = object.Update(r.Context(), tx, false, forceSetValuesForFields...)
+	if err != nil {
+		err = fmt.Errorf("failed to update %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusOK, []*Geofence{object})
+}
+
+func handleDeleteGeofence(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	_ = redisConn
+
+	var item = make(map[string]any)
+
+	item[GeofenceTablePrimaryKeyColumn] = primaryKey
+
+	object := &Geofence{}
+	err := object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as Geofence in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = object.Delete(r.Context(), tx)
+	if err != nil {
+		err = fmt.Errorf("failed to delete %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusNoContent, nil)
+}
+
+func GetGeofenceRouter(db *sqlx.DB, redisConn redis.Conn, httpMiddlewares []server.HTTPMiddleware, modelMiddlewares []server.ModelMiddleware) chi.Router {
+	r := chi.NewRouter()
+
+	for _, m := range httpMiddlewares {
+		r.Use(m)
+	}
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		handleGetGeofences(w, r, db, redisConn, modelMiddlewares)
+	})
+
+	r.Get("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetGeofence(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		handlePostGeofences(w, r, db, redisConn, modelMiddlewares)
+	})
+
+	r.Put("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handlePutGeofence(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Patch("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handlePatchGeofence(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Delete("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteGeofence(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	return r
+}
+
+func NewGeofenceFromItem(item map[string]any) (any, error) {
+	object := &Geofence{}
+
+	err := object.FromItem(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+func init() {
+	register(
+		GeofenceTable,
+		Geofence{},
+		NewGeofenceFromItem,
+		"/geofences",
+		GetGeofenceRouter,
+	)
+}
@@ -41,6 +41,7 @@ type LocationHistory struct {
 	Polygon                     *[]pgtype.Vec2 `json:"polygon"`
 	ParentPhysicalThingID       *uuid.UUID     `json:"parent_physical_thing_id"`
 	ParentPhysicalThingIDObject *PhysicalThing `json:"parent_physical_thing_id_object"`
+	DeletionBatchID             *int64         `json:"deletion_batch_id"`
 }
 
 var LocationHistoryTable = "location_history"
@@ -54,6 +55,7 @@ var (
 	LocationHistoryTablePointColumn                 = "point"
 	LocationHistoryTablePolygonColumn               = "polygon"
 	LocationHistoryTableParentPhysicalThingIDColumn = "parent_physical_thing_id"
+	LocationHistoryTableDeletionBatchIDColumn       = "deletion_batch_id"
 )
 
 var (
@@ -65,6 +67,7 @@ var (
 	LocationHistoryTablePointColumnWithTypeCast                 = fmt.Sprintf(`"point" AS point`)
 	LocationHistoryTablePolygonColumnWithTypeCast               = fmt.Sprintf(`"polygon" AS polygon`)
 	LocationHistoryTableParentPhysicalThingIDColumnWithTypeCast = fmt.Sprintf(`"parent_physical_thing_id" AS parent_physical_thing_id`)
+	LocationHistoryTableDeletionBatchIDColumnWithTypeCast       = fmt.Sprintf(`"deletion_batch_id" AS deletion_batch_id`)
 )
 
 var LocationHistoryTableColumns = []string{
@@ -76,6 +79,7 @@ var LocationHistoryTableColumns = []string{
 	LocationHistoryTablePointColumn,
 	LocationHistoryTablePolygonColumn,
 	LocationHistoryTableParentPhysicalThingIDColumn,
+	LocationHistoryTableDeletionBatchIDColumn,
 }
 
 var LocationHistoryTableColumnsWithTypeCasts = []string{
@@ -87,6 +91,7 @@ var LocationHistoryTableColumnsWithTypeCasts = []string{
 	LocationHistoryTablePointColumnWithTypeCast,
 	LocationHistoryTablePolygonColumnWithTypeCast,
 	LocationHistoryTableParentPhysicalThingIDColumnWithTypeCast,
+	LocationHistoryTableDeletionBatchIDColumnWithTypeCast,
 }
 
 var LocationHistoryTableColumnLookup = map[string]*introspect.Column{
@@ -98,6 +103,7 @@ var LocationHistoryTableColumnLookup = map[string]*introspect.Column{
 	LocationHistoryTablePointColumn:                 new(introspect.Column),
 	LocationHistoryTablePolygonColumn:               new(introspect.Column),
 	LocationHistoryTableParentPhysicalThingIDColumn: new(introspect.Column),
+	LocationHistoryTableDeletionBatchIDColumn:       new(introspect.Column),
 }
 
 var (
@@ -303,6 +309,25 @@ func (m *LocationHistory) FromItem(item map[string]any) error {
 
 			m.ParentPhysicalThingID = &temp2
 
+		case "deletion_batch_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseInt(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(int64)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to int64", temp1))
+				}
+			}
+
+			m.DeletionBatchID = &temp2
+
 		}
 	}
 
@@ -340,6 +365,7 @@ func (m *LocationHistory) Reload(
 	m.Polygon = t.Polygon
 	m.ParentPhysicalThingID = t.ParentPhysicalThingID
 	m.ParentPhysicalThingIDObject = t.ParentPhysicalThingIDObject
+	m.DeletionBatchID = t.DeletionBatchID
 
 	return nil
 }
@@ -441,6 +467,17 @@ func (m *LocationHistory) Insert(
 		values = append(values, v)
 	}
 
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) {
+		columns = append(columns, LocationHistoryTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
 	item, err := query.Insert(
 		ctx,
 		tx,
@@ -576,6 +613,17 @@ func (m *LocationHistory) Update(
 		values = append(values, v)
 	}
 
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) || slices.Contains(forceSetValuesForFields, LocationHistoryTableDeletionBatchIDColumn) {
+		columns = append(columns, LocationHistoryTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
 	v, err := types.FormatUUID(m.ID)
 	if err != nil {
 		return fmt.Errorf("failed to handle m.ID: %v", err)
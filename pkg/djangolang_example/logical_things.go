@@ -46,6 +46,7 @@ type LogicalThing struct {
 	ParentPhysicalThingIDObject *PhysicalThing     `json:"parent_physical_thing_id_object"`
 	ParentLogicalThingID        *uuid.UUID         `json:"parent_logical_thing_id"`
 	ParentLogicalThingIDObject  *LogicalThing      `json:"parent_logical_thing_id_object"`
+	DeletionBatchID             *int64             `json:"deletion_batch_id"`
 }
 
 var LogicalThingTable = "logical_things"
@@ -63,6 +64,7 @@ var (
 	LogicalThingTableRawDataColumn               = "raw_data"
 	LogicalThingTableParentPhysicalThingIDColumn = "parent_physical_thing_id"
 	LogicalThingTableParentLogicalThingIDColumn  = "parent_logical_thing_id"
+	LogicalThingTableDeletionBatchIDColumn       = "deletion_batch_id"
 )
 
 var (
@@ -78,6 +80,7 @@ var (
 	LogicalThingTableRawDataColumnWithTypeCast               = fmt.Sprintf(`"raw_data" AS raw_data`)
 	LogicalThingTableParentPhysicalThingIDColumnWithTypeCast = fmt.Sprintf(`"parent_physical_thing_id" AS parent_physical_thing_id`)
 	LogicalThingTableParentLogicalThingIDColumnWithTypeCast  = fmt.Sprintf(`"parent_logical_thing_id" AS parent_logical_thing_id`)
+	LogicalThingTableDeletionBatchIDColumnWithTypeCast       = fmt.Sprintf(`"deletion_batch_id" AS deletion_batch_id`)
 )
 
 var LogicalThingTableColumns = []string{
@@ -93,6 +96,7 @@ var LogicalThingTableColumns = []string{
 	LogicalThingTableRawDataColumn,
 	LogicalThingTableParentPhysicalThingIDColumn,
 	LogicalThingTableParentLogicalThingIDColumn,
+	LogicalThingTableDeletionBatchIDColumn,
 }
 
 var LogicalThingTableColumnsWithTypeCasts = []string{
@@ -108,6 +112,7 @@ var LogicalThingTableColumnsWithTypeCasts = []string{
 	LogicalThingTableRawDataColumnWithTypeCast,
 	LogicalThingTableParentPhysicalThingIDColumnWithTypeCast,
 	LogicalThingTableParentLogicalThingIDColumnWithTypeCast,
+	LogicalThingTableDeletionBatchIDColumnWithTypeCast,
 }
 
 var LogicalThingTableColumnLookup = map[string]*introspect.Column{
@@ -123,6 +128,7 @@ var LogicalThingTableColumnLookup = map[string]*introspect.Column{
 	LogicalThingTableRawDataColumn:               new(introspect.Column),
 	LogicalThingTableParentPhysicalThingIDColumn: new(introspect.Column),
 	LogicalThingTableParentLogicalThingIDColumn:  new(introspect.Column),
+	LogicalThingTableDeletionBatchIDColumn:       new(introspect.Column),
 }
 
 var (
@@ -404,6 +410,25 @@ func (m *LogicalThing) FromItem(item map[string]any) error {
 
 			m.ParentLogicalThingID = &temp2
 
+		case "deletion_batch_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseInt(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(int64)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to int64", temp1))
+				}
+			}
+
+			m.DeletionBatchID = &temp2
+
 		}
 	}
 
@@ -446,6 +471,7 @@ func (m *LogicalThing) Reload(
 	m.ParentPhysicalThingIDObject = t.ParentPhysicalThingIDObject
 	m.ParentLogicalThingID = t.ParentLogicalThingID
 	m.ParentLogicalThingIDObject = t.ParentLogicalThingIDObject
+	m.DeletionBatchID = t.DeletionBatchID
 
 	return nil
 }
@@ -591,6 +617,17 @@ func (m *LogicalThing) Insert(
 		values = append(values, v)
 	}
 
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) {
+		columns = append(columns, LogicalThingTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
 	item, err := query.Insert(
 		ctx,
 		tx,
@@ -770,6 +807,17 @@ func (m *LogicalThing) Update(
 		values = append(values, v)
 	}
 
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) || slices.Contains(forceSetValuesForFields, LogicalThingTableDeletionBatchIDColumn) {
+		columns = append(columns, LogicalThingTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
 	v, err := types.FormatUUID(m.ID)
 	if err != nil {
 		return fmt.Errorf("failed to handle m.ID: %v", err)
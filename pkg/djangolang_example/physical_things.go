@@ -32,44 +32,47 @@ import (
 )
 
 type PhysicalThing struct {
-	ID         uuid.UUID          `json:"id"`
-	CreatedAt  time.Time          `json:"created_at"`
-	UpdatedAt  time.Time          `json:"updated_at"`
-	DeletedAt  *time.Time         `json:"deleted_at"`
-	ExternalID *string            `json:"external_id"`
-	Name       string             `json:"name"`
-	Type       string             `json:"type"`
-	Tags       []string           `json:"tags"`
-	Metadata   map[string]*string `json:"metadata"`
-	RawData    any                `json:"raw_data"`
+	ID              uuid.UUID          `json:"id"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	DeletedAt       *time.Time         `json:"deleted_at"`
+	ExternalID      *string            `json:"external_id"`
+	Name            string             `json:"name"`
+	Type            string             `json:"type"`
+	Tags            []string           `json:"tags"`
+	Metadata        map[string]*string `json:"metadata"`
+	RawData         any                `json:"raw_data"`
+	DeletionBatchID *int64             `json:"deletion_batch_id"`
 }
 
 var PhysicalThingTable = "physical_things"
 
 var (
-	PhysicalThingTableIDColumn         = "id"
-	PhysicalThingTableCreatedAtColumn  = "created_at"
-	PhysicalThingTableUpdatedAtColumn  = "updated_at"
-	PhysicalThingTableDeletedAtColumn  = "deleted_at"
-	PhysicalThingTableExternalIDColumn = "external_id"
-	PhysicalThingTableNameColumn       = "name"
-	PhysicalThingTableTypeColumn       = "type"
-	PhysicalThingTableTagsColumn       = "tags"
-	PhysicalThingTableMetadataColumn   = "metadata"
-	PhysicalThingTableRawDataColumn    = "raw_data"
+	PhysicalThingTableIDColumn              = "id"
+	PhysicalThingTableCreatedAtColumn       = "created_at"
+	PhysicalThingTableUpdatedAtColumn       = "updated_at"
+	PhysicalThingTableDeletedAtColumn       = "deleted_at"
+	PhysicalThingTableExternalIDColumn      = "external_id"
+	PhysicalThingTableNameColumn            = "name"
+	PhysicalThingTableTypeColumn            = "type"
+	PhysicalThingTableTagsColumn            = "tags"
+	PhysicalThingTableMetadataColumn        = "metadata"
+	PhysicalThingTableRawDataColumn         = "raw_data"
+	PhysicalThingTableDeletionBatchIDColumn = "deletion_batch_id"
 )
 
 var (
-	PhysicalThingTableIDColumnWithTypeCast         = fmt.Sprintf(`"id" AS id`)
-	PhysicalThingTableCreatedAtColumnWithTypeCast  = fmt.Sprintf(`"created_at" AS created_at`)
-	PhysicalThingTableUpdatedAtColumnWithTypeCast  = fmt.Sprintf(`"updated_at" AS updated_at`)
-	PhysicalThingTableDeletedAtColumnWithTypeCast  = fmt.Sprintf(`"deleted_at" AS deleted_at`)
-	PhysicalThingTableExternalIDColumnWithTypeCast = fmt.Sprintf(`"external_id" AS external_id`)
-	PhysicalThingTableNameColumnWithTypeCast       = fmt.Sprintf(`"name" AS name`)
-	PhysicalThingTableTypeColumnWithTypeCast       = fmt.Sprintf(`"type" AS type`)
-	PhysicalThingTableTagsColumnWithTypeCast       = fmt.Sprintf(`"tags" AS tags`)
-	PhysicalThingTableMetadataColumnWithTypeCast   = fmt.Sprintf(`"metadata" AS metadata`)
-	PhysicalThingTableRawDataColumnWithTypeCast    = fmt.Sprintf(`"raw_data" AS raw_data`)
+	PhysicalThingTableIDColumnWithTypeCast              = fmt.Sprintf(`"id" AS id`)
+	PhysicalThingTableCreatedAtColumnWithTypeCast       = fmt.Sprintf(`"created_at" AS created_at`)
+	PhysicalThingTableUpdatedAtColumnWithTypeCast       = fmt.Sprintf(`"updated_at" AS updated_at`)
+	PhysicalThingTableDeletedAtColumnWithTypeCast       = fmt.Sprintf(`"deleted_at" AS deleted_at`)
+	PhysicalThingTableExternalIDColumnWithTypeCast      = fmt.Sprintf(`"external_id" AS external_id`)
+	PhysicalThingTableNameColumnWithTypeCast            = fmt.Sprintf(`"name" AS name`)
+	PhysicalThingTableTypeColumnWithTypeCast            = fmt.Sprintf(`"type" AS type`)
+	PhysicalThingTableTagsColumnWithTypeCast            = fmt.Sprintf(`"tags" AS tags`)
+	PhysicalThingTableMetadataColumnWithTypeCast        = fmt.Sprintf(`"metadata" AS metadata`)
+	PhysicalThingTableRawDataColumnWithTypeCast         = fmt.Sprintf(`"raw_data" AS raw_data`)
+	PhysicalThingTableDeletionBatchIDColumnWithTypeCast = fmt.Sprintf(`"deletion_batch_id" AS deletion_batch_id`)
 )
 
 var PhysicalThingTableColumns = []string{
@@ -83,6 +86,7 @@ var PhysicalThingTableColumns = []string{
 	PhysicalThingTableTagsColumn,
 	PhysicalThingTableMetadataColumn,
 	PhysicalThingTableRawDataColumn,
+	PhysicalThingTableDeletionBatchIDColumn,
 }
 
 var PhysicalThingTableColumnsWithTypeCasts = []string{
@@ -96,19 +100,21 @@ var PhysicalThingTableColumnsWithTypeCasts = []string{
 	PhysicalThingTableTagsColumnWithTypeCast,
 	PhysicalThingTableMetadataColumnWithTypeCast,
 	PhysicalThingTableRawDataColumnWithTypeCast,
+	PhysicalThingTableDeletionBatchIDColumnWithTypeCast,
 }
 
 var PhysicalThingTableColumnLookup = map[string]*introspect.Column{
-	PhysicalThingTableIDColumn:         new(introspect.Column),
-	PhysicalThingTableCreatedAtColumn:  new(introspect.Column),
-	PhysicalThingTableUpdatedAtColumn:  new(introspect.Column),
-	PhysicalThingTableDeletedAtColumn:  new(introspect.Column),
-	PhysicalThingTableExternalIDColumn: new(introspect.Column),
-	PhysicalThingTableNameColumn:       new(introspect.Column),
-	PhysicalThingTableTypeColumn:       new(introspect.Column),
-	PhysicalThingTableTagsColumn:       new(introspect.Column),
-	PhysicalThingTableMetadataColumn:   new(introspect.Column),
-	PhysicalThingTableRawDataColumn:    new(introspect.Column),
+	PhysicalThingTableIDColumn:              new(introspect.Column),
+	PhysicalThingTableCreatedAtColumn:       new(introspect.Column),
+	PhysicalThingTableUpdatedAtColumn:       new(introspect.Column),
+	PhysicalThingTableDeletedAtColumn:       new(introspect.Column),
+	PhysicalThingTableExternalIDColumn:      new(introspect.Column),
+	PhysicalThingTableNameColumn:            new(introspect.Column),
+	PhysicalThingTableTypeColumn:            new(introspect.Column),
+	PhysicalThingTableTagsColumn:            new(introspect.Column),
+	PhysicalThingTableMetadataColumn:        new(introspect.Column),
+	PhysicalThingTableRawDataColumn:         new(introspect.Column),
+	PhysicalThingTableDeletionBatchIDColumn: new(introspect.Column),
 }
 
 var (
@@ -352,6 +358,25 @@ func (m *PhysicalThing) FromItem(item map[string]any) error {
 
 			m.RawData = &temp2
 
+		case "deletion_batch_id":
+			if v == nil {
+				continue
+			}
+
+			temp1, err := types.ParseInt(v)
+			if err != nil {
+				return wrapError(k, v, err)
+			}
+
+			temp2, ok := temp1.(int64)
+			if !ok {
+				if temp1 != nil {
+					return wrapError(k, v, fmt.Errorf("failed to cast %#+v to int64", temp1))
+				}
+			}
+
+			m.DeletionBatchID = &temp2
+
 		}
 	}
 
@@ -390,6 +415,7 @@ func (m *PhysicalThing) Reload(
 	m.Tags = t.Tags
 	m.Metadata = t.Metadata
 	m.RawData = t.RawData
+	m.DeletionBatchID = t.DeletionBatchID
 
 	return nil
 }
@@ -513,6 +539,17 @@ func (m *PhysicalThing) Insert(
 		values = append(values, v)
 	}
 
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) {
+		columns = append(columns, PhysicalThingTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
 	item, err := query.Insert(
 		ctx,
 		tx,
@@ -670,6 +707,17 @@ func (m *PhysicalThing) Update(
 		values = append(values, v)
 	}
 
+	if setZeroValues || !types.IsZeroInt(m.DeletionBatchID) || slices.Contains(forceSetValuesForFields, PhysicalThingTableDeletionBatchIDColumn) {
+		columns = append(columns, PhysicalThingTableDeletionBatchIDColumn)
+
+		v, err := types.FormatInt(m.DeletionBatchID)
+		if err != nil {
+			return fmt.Errorf("failed to handle m.DeletionBatchID: %v", err)
+		}
+
+		values = append(values, v)
+	}
+
 	v, err := types.FormatUUID(m.ID)
 	if err != nil {
 		return fmt.Errorf("failed to handle m.ID: %v", err)
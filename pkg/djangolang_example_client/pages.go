@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// this file is not generated; it contains helpers for walking the keyset (cursor) pagination of the list endpoints
+
+func iteratePages[T any](getPage func(cursor *string) (*[]T, *string, error), fn func(T) error) error {
+	var cursor *string
+
+	for {
+		objects, nextCursor, err := getPage(cursor)
+		if err != nil {
+			return err
+		}
+
+		if objects != nil {
+			for _, object := range *objects {
+				err = fn(object)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if nextCursor == nil {
+			return nil
+		}
+
+		cursor = nextCursor
+	}
+}
+
+// IterateLocationHistories calls fn for every LocationHistory matching params, following next_cursor until there are no
+// more pages; params itself is not modified
+func (c *ClientWithResponses) IterateLocationHistories(ctx context.Context, params *GetLocationHistoriesParams, fn func(LocationHistory) error, reqEditors ...RequestEditorFn) error {
+	p := GetLocationHistoriesParams{}
+	if params != nil {
+		p = *params
+	}
+
+	return iteratePages(func(cursor *string) (*[]LocationHistory, *string, error) {
+		if cursor != nil {
+			p.Cursor = cursor
+			p.Offset = nil
+		}
+
+		rsp, err := c.GetLocationHistoriesWithResponse(ctx, &p, reqEditors...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rsp.JSON200 == nil {
+			return nil, nil, fmt.Errorf("failed to get page of LocationHistories: %v: %s", rsp.Status(), string(rsp.Body))
+		}
+
+		return rsp.JSON200.Objects, rsp.JSON200.NextCursor, nil
+	}, fn)
+}
+
+// IterateLogicalThings calls fn for every LogicalThing matching params, following next_cursor until there are no more
+// pages; params itself is not modified
+func (c *ClientWithResponses) IterateLogicalThings(ctx context.Context, params *GetLogicalThingsParams, fn func(LogicalThing) error, reqEditors ...RequestEditorFn) error {
+	p := GetLogicalThingsParams{}
+	if params != nil {
+		p = *params
+	}
+
+	return iteratePages(func(cursor *string) (*[]LogicalThing, *string, error) {
+		if cursor != nil {
+			p.Cursor = cursor
+			p.Offset = nil
+		}
+
+		rsp, err := c.GetLogicalThingsWithResponse(ctx, &p, reqEditors...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rsp.JSON200 == nil {
+			return nil, nil, fmt.Errorf("failed to get page of LogicalThings: %v: %s", rsp.Status(), string(rsp.Body))
+		}
+
+		return rsp.JSON200.Objects, rsp.JSON200.NextCursor, nil
+	}, fn)
+}
+
+// IteratePhysicalThings calls fn for every PhysicalThing matching params, following next_cursor until there are no more
+// pages; params itself is not modified
+func (c *ClientWithResponses) IteratePhysicalThings(ctx context.Context, params *GetPhysicalThingsParams, fn func(PhysicalThing) error, reqEditors ...RequestEditorFn) error {
+	p := GetPhysicalThingsParams{}
+	if params != nil {
+		p = *params
+	}
+
+	return iteratePages(func(cursor *string) (*[]PhysicalThing, *string, error) {
+		if cursor != nil {
+			p.Cursor = cursor
+			p.Offset = nil
+		}
+
+		rsp, err := c.GetPhysicalThingsWithResponse(ctx, &p, reqEditors...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rsp.JSON200 == nil {
+			return nil, nil, fmt.Errorf("failed to get page of PhysicalThings: %v: %s", rsp.Status(), string(rsp.Body))
+		}
+
+		return rsp.JSON200.Objects, rsp.JSON200.NextCursor, nil
+	}, fn)
+}
+
+// IterateFuzzes calls fn for every Fuzz matching params, following next_cursor until there are no more pages; params
+// itself is not modified
+func (c *ClientWithResponses) IterateFuzzes(ctx context.Context, params *GetFuzzesParams, fn func(Fuzz) error, reqEditors ...RequestEditorFn) error {
+	p := GetFuzzesParams{}
+	if params != nil {
+		p = *params
+	}
+
+	return iteratePages(func(cursor *string) (*[]Fuzz, *string, error) {
+		if cursor != nil {
+			p.Cursor = cursor
+			p.Offset = nil
+		}
+
+		rsp, err := c.GetFuzzesWithResponse(ctx, &p, reqEditors...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rsp.JSON200 == nil {
+			return nil, nil, fmt.Errorf("failed to get page of Fuzzes: %v: %s", rsp.Status(), string(rsp.Body))
+		}
+
+		return rsp.JSON200.Objects, rsp.JSON200.NextCursor, nil
+	}, fn)
+}
+
+// IterateGeofences calls fn for every Geofence matching params, following next_cursor until there are no more pages;
+// params itself is not modified
+func (c *ClientWithResponses) IterateGeofences(ctx context.Context, params *GetGeofencesParams, fn func(Geofence) error, reqEditors ...RequestEditorFn) error {
+	p := GetGeofencesParams{}
+	if params != nil {
+		p = *params
+	}
+
+	return iteratePages(func(cursor *string) (*[]Geofence, *string, error) {
+		if cursor != nil {
+			p.Cursor = cursor
+			p.Offset = nil
+		}
+
+		rsp, err := c.GetGeofencesWithResponse(ctx, &p, reqEditors...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rsp.JSON200 == nil {
+			return nil, nil, fmt.Errorf("failed to get page of Geofences: %v: %s", rsp.Status(), string(rsp.Body))
+		}
+
+		return rsp.JSON200.Objects, rsp.JSON200.NextCursor, nil
+	}, fn)
+}
+
+// IterateGeofenceEvents calls fn for every GeofenceEvent matching params, following next_cursor until there are no
+// more pages; params itself is not modified
+func (c *ClientWithResponses) IterateGeofenceEvents(ctx context.Context, params *GetGeofenceEventsParams, fn func(GeofenceEvent) error, reqEditors ...RequestEditorFn) error {
+	p := GetGeofenceEventsParams{}
+	if params != nil {
+		p = *params
+	}
+
+	return iteratePages(func(cursor *string) (*[]GeofenceEvent, *string, error) {
+		if cursor != nil {
+			p.Cursor = cursor
+			p.Offset = nil
+		}
+
+		rsp, err := c.GetGeofenceEventsWithResponse(ctx, &p, reqEditors...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rsp.JSON200 == nil {
+			return nil, nil, fmt.Errorf("failed to get page of GeofenceEvents: %v: %s", rsp.Status(), string(rsp.Body))
+		}
+
+		return rsp.JSON200.Objects, rsp.JSON200.NextCursor, nil
+	}, fn)
+}
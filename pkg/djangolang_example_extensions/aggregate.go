@@ -0,0 +1,371 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxAggregateGroups is the most groups an aggregation may have (unless limited), so that grouping by something unique
+// can't be used to get the raw rows
+const maxAggregateGroups = 100000
+
+// aggregateFunctions are the functions that the agg param supports, each with a check for the (Postgres) data type of the
+// columns it can be applied to; count is the only one that can be applied to *
+var aggregateFunctions = map[string]func(dataType string) bool{
+	"count": func(dataType string) bool { return true },
+	"min":   isOrderedDataType,
+	"max":   isOrderedDataType,
+	"sum":   isNumericDataType,
+	"avg":   isNumericDataType,
+}
+
+var aggregatePattern = regexp.MustCompile(`^\s*(\w+)\s*\(\s*(\*|\w+)\s*\)\s*$`)
+
+// aggregate is an expression from the agg param, e.g. min(timestamp)
+type aggregate struct {
+	function string
+	column   string
+}
+
+// name is the key the aggregate is given in the response, e.g. count for count(*) and min_timestamp for min(timestamp)
+func (a aggregate) name() string {
+	if a.column == "*" {
+		return a.function
+	}
+
+	return fmt.Sprintf("%s_%s", a.function, a.column)
+}
+
+func (a aggregate) String() string {
+	return fmt.Sprintf("%s(%s)", a.function, a.column)
+}
+
+// formatSQL renders the aggregate; sum and avg come back as double precision (rather than numeric) so that they're
+// numbers in the JSON
+func (a aggregate) formatSQL() string {
+	column := "*"
+	if a.column != "*" {
+		column = formatColumn("", a.column)
+	}
+
+	switch a.function {
+	case "count":
+		return fmt.Sprintf("count(%s)", column)
+	case "sum", "avg":
+		return fmt.Sprintf("%s(%s)::double precision", a.function, column)
+	}
+
+	return fmt.Sprintf("%s(%s)", a.function, column)
+}
+
+func isNumericDataType(dataType string) bool {
+	switch dataType {
+	case "smallint", "integer", "bigint", "real", "double precision", "numeric":
+		return true
+	}
+
+	return false
+}
+
+func isTimeDataType(dataType string) bool {
+	return dataType == "timestamp with time zone" || dataType == "timestamp without time zone"
+}
+
+func isOrderedDataType(dataType string) bool {
+	return isNumericDataType(dataType) || isTimeDataType(dataType) || dataType == "text" || dataType == "character varying"
+}
+
+// getColumnDataType returns the Postgres data type of the given column of the given table, if the table has that column
+// (as per its *TableColumnLookup)
+func getColumnDataType(table string, column string) (string, bool) {
+	_, ok := columnLookupByTable[table][column]
+	if !ok {
+		return "", false
+	}
+
+	dataType, ok := dataTypeByTable[table][column]
+
+	return dataType, ok
+}
+
+// parseGroupBy parses the (comma-separated) group_by param; point and polygon columns can't be grouped by (as they've
+// no equality)
+func parseGroupBy(table string, rawGroupBy []string) ([]string, error) {
+	columns := make([]string, 0)
+
+	for _, rawColumns := range rawGroupBy {
+		for _, column := range strings.Split(rawColumns, ",") {
+			column = strings.TrimSpace(column)
+
+			_, ok := columnLookupByTable[table][column]
+			if !ok {
+				return nil, fmt.Errorf("unknown column %#+v", column)
+			}
+
+			kind := columnKindsByTable[table][column]
+			if kind == columnKindPoint || kind == columnKindPolygon {
+				return nil, fmt.Errorf("can't group by %s column %#+v", kind, column)
+			}
+
+			columns = append(columns, column)
+		}
+	}
+
+	return columns, nil
+}
+
+// parseAggregates parses the (comma-separated) agg param, checking the functions against the types of their columns;
+// the default is count(*)
+func parseAggregates(table string, rawAggregates []string) ([]aggregate, error) {
+	aggregates := make([]aggregate, 0)
+
+	for _, rawAggregate := range rawAggregates {
+		for _, expression := range strings.Split(rawAggregate, ",") {
+			matches := aggregatePattern.FindStringSubmatch(expression)
+			if matches == nil {
+				return nil, fmt.Errorf("failed to parse %#+v as function(column)", expression)
+			}
+
+			a := aggregate{function: strings.ToLower(matches[1]), column: matches[2]}
+
+			isSupportedType, ok := aggregateFunctions[a.function]
+			if !ok {
+				return nil, fmt.Errorf("unknown function %#+v in %#+v", a.function, expression)
+			}
+
+			if a.column == "*" {
+				if a.function != "count" {
+					return nil, fmt.Errorf("only count can be applied to * in %#+v", expression)
+				}
+			} else {
+				dataType, ok := getColumnDataType(table, a.column)
+				if !ok {
+					return nil, fmt.Errorf("unknown column %#+v in %#+v", a.column, expression)
+				}
+
+				if !isSupportedType(dataType) {
+					return nil, fmt.Errorf("can't apply %s to column %#+v in %#+v", a.function, a.column, expression)
+				}
+			}
+
+			aggregates = append(aggregates, a)
+		}
+	}
+
+	if len(aggregates) == 0 {
+		aggregates = append(aggregates, aggregate{function: "count", column: "*"})
+	}
+
+	return aggregates, nil
+}
+
+// GetAggregates groups the (non-deleted) rows of the given table matching the wheres by the given columns and returns
+// the aggregates for each group, as a JSON object (keyed by the group columns and the aggregate names) per group, in
+// group column order; a limit of nil means all the groups (up to maxAggregateGroups)
+func GetAggregates(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table string,
+	groupBy []string,
+	aggregates []aggregate,
+	limit *int,
+	offset *int,
+	wheres []string,
+	values ...any,
+) ([]json.RawMessage, error) {
+	if hasDeletedAt(table) {
+		wheres = append(wheres, fmt.Sprintf("%s IS null", formatColumn("", "deleted_at")))
+	}
+
+	if len(wheres) == 0 {
+		wheres = append(wheres, "true")
+	}
+
+	// note: the row is built as a JSON object so that each column comes out as the right JSON type whatever its type
+	pairs := make([]string, 0)
+	for _, column := range groupBy {
+		pairs = append(pairs, fmt.Sprintf("'%s', %s", column, formatColumn("", column)))
+	}
+
+	for _, a := range aggregates {
+		pairs = append(pairs, fmt.Sprintf("'%s', %s", a.name(), a.formatSQL()))
+	}
+
+	sql := fmt.Sprintf(
+		`SELECT
+    jsonb_build_object(%s)
+FROM
+    %s
+WHERE
+    %s`,
+		strings.Join(pairs, ", "),
+		query.FormatObjectName(table),
+		strings.Join(wheres, "\n    AND "),
+	)
+
+	if len(groupBy) > 0 {
+		columns := make([]string, 0)
+		for _, column := range groupBy {
+			columns = append(columns, formatColumn("", column))
+		}
+
+		sql += fmt.Sprintf("\nGROUP BY\n    %s\nORDER BY\n    %s", strings.Join(columns, ", "), strings.Join(columns, ", "))
+	}
+
+	if limit != nil {
+		sql += fmt.Sprintf("\nLIMIT %d", *limit)
+	} else {
+		sql += fmt.Sprintf("\nLIMIT %d", maxAggregateGroups+1)
+	}
+
+	if offset != nil {
+		sql += fmt.Sprintf("\nOFFSET %d", *offset)
+	}
+
+	sql += ";"
+
+	objects := make([]json.RawMessage, 0)
+
+	err := queryRows(ctx, tx, sql, values, func(rows *sqlx.Rows) error {
+		var b []byte
+
+		err := rows.Scan(&b)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, json.RawMessage(b))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if limit == nil && len(objects) > maxAggregateGroups {
+		return nil, fmt.Errorf("aggregation has more than %d groups; try a limit or a narrower filter", maxAggregateGroups)
+	}
+
+	return objects, nil
+}
+
+func parseNonNegativeInt(r *http.Request, param string) (*int, error) {
+	rawValue := r.URL.Query().Get(param)
+	if rawValue == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseInt(rawValue, 10, 64)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("failed to parse param %s=%s as non-negative int: %v", param, rawValue, err)
+	}
+
+	return helpers.Ptr(int(value)), nil
+}
+
+func handleGetAggregates(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, table string) {
+	ctx := r.Context()
+
+	rawGroupBy := r.URL.Query()["group_by"]
+	groupBy, err := parseGroupBy(table, rawGroupBy)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse param group_by=%s: %v", strings.Join(rawGroupBy, ","), err))
+		return
+	}
+
+	rawAggregates := r.URL.Query()["agg"]
+	aggregates, err := parseAggregates(table, rawAggregates)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse param agg=%s: %v", strings.Join(rawAggregates, ","), err))
+		return
+	}
+
+	limit, err := parseNonNegativeInt(r, "limit")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	offset, err := parseNonNegativeInt(r, "offset")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	wheres, values, err := getQueryFilterWheres(table, r.URL.Query(), "group_by", "agg", "limit", "offset")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rawAggregateNames := make([]string, 0)
+	for _, a := range aggregates {
+		rawAggregateNames = append(rawAggregateNames, a.String())
+	}
+
+	requestHash, err := getDerivedRequestHash(
+		table,
+		wheres,
+		values,
+		"AGGREGATE",
+		fmt.Sprintf("GROUP BY %s", strings.Join(groupBy, ", ")),
+		fmt.Sprintf("AGG %s", strings.Join(rawAggregateNames, ", ")),
+		fmt.Sprintf("LIMIT %v", helpers.Deref(limit)),
+		fmt.Sprintf("OFFSET %v", helpers.Deref(offset)),
+	)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	objects, err := GetAggregates(ctx, tx, table, groupBy, aggregates, limit, offset, wheres, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, objects)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
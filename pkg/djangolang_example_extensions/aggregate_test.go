@@ -0,0 +1,136 @@
+package extensions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+func TestDataTypeByTable(t *testing.T) {
+	for table, columnLookup := range columnLookupByTable {
+		for column := range columnLookup {
+			_, ok := dataTypeByTable[table][column]
+			if !ok {
+				t.Errorf("%s.%s is in the column lookup but has no data type", table, column)
+			}
+		}
+
+		for column := range dataTypeByTable[table] {
+			_, ok := columnLookup[column]
+			if !ok {
+				t.Errorf("%s.%s has a data type but isn't in the column lookup", table, column)
+			}
+		}
+	}
+}
+
+func TestParseAggregates(t *testing.T) {
+	testCases := []struct {
+		name          string
+		table         string
+		rawAggregates []string
+		expected      []aggregate
+		expectErr     bool
+	}{
+		{
+			name:     "defaults to count",
+			table:    djangolang_example.LocationHistoryTable,
+			expected: []aggregate{{function: "count", column: "*"}},
+		},
+		{
+			name:          "comma-separated and repeated",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"count(*), MIN(timestamp)", "max(timestamp)"},
+			expected: []aggregate{
+				{function: "count", column: "*"},
+				{function: "min", column: "timestamp"},
+				{function: "max", column: "timestamp"},
+			},
+		},
+		{
+			name:          "count of any column",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"count(point)"},
+			expected:      []aggregate{{function: "count", column: "point"}},
+		},
+		{
+			name:          "sum of numeric column",
+			table:         djangolang_example.FuzzTable,
+			rawAggregates: []string{"sum(column14),avg(column21)"},
+			expected: []aggregate{
+				{function: "sum", column: "column14"},
+				{function: "avg", column: "column21"},
+			},
+		},
+		{
+			name:          "min of text column",
+			table:         djangolang_example.PhysicalThingTable,
+			rawAggregates: []string{"min(name)"},
+			expected:      []aggregate{{function: "min", column: "name"}},
+		},
+		{
+			name:          "sum of time column",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"sum(timestamp)"},
+			expectErr:     true,
+		},
+		{
+			name:          "min of uuid column",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"min(id)"},
+			expectErr:     true,
+		},
+		{
+			name:          "max of point column",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"max(point)"},
+			expectErr:     true,
+		},
+		{
+			name:          "unknown column",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"min(nope)"},
+			expectErr:     true,
+		},
+		{
+			name:          "unknown function",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"median(timestamp)"},
+			expectErr:     true,
+		},
+		{
+			name:          "star for other than count",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"min(*)"},
+			expectErr:     true,
+		},
+		{
+			name:          "not a function",
+			table:         djangolang_example.LocationHistoryTable,
+			rawAggregates: []string{"timestamp; DROP TABLE location_history"},
+			expectErr:     true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			aggregates, err := parseAggregates(testCase.table, testCase.rawAggregates)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", aggregates)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(aggregates, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, aggregates)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package extensions
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NewBearerTokenAuthorizer returns an authorizer (for SetDeletedRowsAuthorizer / SetHardDeleteAuthorizer) that
+// authorizes a request if and only if it has an "Authorization: Bearer <token>" header with the given token
+func NewBearerTokenAuthorizer(token string) func(r *http.Request, table string) error {
+	return func(r *http.Request, table string) error {
+		_ = table
+
+		requestToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return fmt.Errorf("no bearer token")
+		}
+
+		if token == "" || subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			return fmt.Errorf("wrong bearer token")
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"golang.org/x/exp/maps"
+	"gopkg.in/yaml.v2"
+
+	"github.com/initialed85/djangolang/pkg/helpers"
+	extensions "github.com/initialed85/djangolang_example/pkg/djangolang_example_extensions"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if len(os.Args) < 2 {
+		log.Fatal("first argument must be command (one of 'serve', 'dump-openapi-json', 'dump-openapi-yaml', 'import-gpx', 'export-gpx', 'purge')")
+	}
+
+	command := strings.TrimSpace(strings.ToLower(os.Args[1]))
+
+	switch command {
+
+	case "serve":
+		port, err := helpers.GetPort()
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		db, err := helpers.GetDBFromEnvironment(ctx)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+
+		go func() {
+			helpers.WaitForCtrlC(ctx)
+			cancel()
+		}()
+
+		redisURL := helpers.GetRedisURL()
+		var redisConn redis.Conn
+		if redisURL != "" {
+			redisConn, err = redis.DialURLContext(ctx, redisURL)
+			if err != nil {
+				log.Fatalf("err: %v", err)
+			}
+			defer func() {
+				_ = redisConn.Close()
+			}()
+		}
+
+		// note: without a token, no request may see or restore soft-deleted rows
+		deletedRowsToken := helpers.GetEnvironmentVariable("DELETED_ROWS_TOKEN")
+		if deletedRowsToken != "" {
+			extensions.SetDeletedRowsAuthorizer(extensions.NewBearerTokenAuthorizer(deletedRowsToken))
+		}
+
+		// note: without a token, no request may hard-delete
+		hardDeleteToken := helpers.GetEnvironmentVariable("HARD_DELETE_TOKEN")
+		if hardDeleteToken != "" {
+			extensions.SetHardDeleteAuthorizer(extensions.NewBearerTokenAuthorizer(hardDeleteToken))
+		}
+
+		err = extensions.RunServer(ctx, nil, fmt.Sprintf("0.0.0.0:%v", port), db, redisConn, nil, nil)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+	case "dump-openapi-json":
+		openApi, err := extensions.GetOpenAPI()
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		b, err := json.MarshalIndent(openApi, "", "  ")
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		fmt.Printf("%v", string(b))
+	case "dump-openapi-yaml":
+		openApi, err := extensions.GetOpenAPI()
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		b, err := yaml.Marshal(openApi)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		fmt.Printf("%v", string(b))
+
+	case "import-gpx":
+		if len(os.Args) < 4 {
+			log.Fatal("usage: import-gpx [physical thing id] [path to GPX / KML file]")
+		}
+
+		physicalThingID, err := uuid.Parse(os.Args[2])
+		if err != nil {
+			log.Fatalf("err: failed to parse physical thing id %v: %v", os.Args[2], err)
+		}
+
+		b, err := os.ReadFile(os.Args[3])
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		db, err := helpers.GetDBFromEnvironment(ctx)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		objects, err := extensions.ImportLocationHistorys(ctx, tx, physicalThingID, b)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		log.Printf("imported %d location history rows for %v from %v", len(objects), physicalThingID, os.Args[3])
+
+	case "export-gpx":
+		flags := flag.NewFlagSet(command, flag.ExitOnError)
+		format := flags.String("format", extensions.LocationHistoryFileFormatGPX, "gpx or kml")
+		rawFrom := flags.String("from", "", "start of the time window (inclusive), RFC3339")
+		rawTo := flags.String("to", "", "end of the time window (exclusive), RFC3339")
+		output := flags.String("output", "", "path to write the file to (instead of stdout)")
+
+		if len(os.Args) < 3 {
+			log.Fatal("usage: export-gpx [physical thing id] [--format=gpx|kml] [--from=...] [--to=...] [--output=...]")
+		}
+
+		physicalThingID, err := uuid.Parse(os.Args[2])
+		if err != nil {
+			log.Fatalf("err: failed to parse physical thing id %v: %v", os.Args[2], err)
+		}
+
+		_ = flags.Parse(os.Args[3:])
+
+		parseTime := func(name string, rawTime string) *time.Time {
+			if rawTime == "" {
+				return nil
+			}
+
+			t, err := time.Parse(time.RFC3339Nano, rawTime)
+			if err != nil {
+				log.Fatalf("err: failed to parse --%s=%s: %v", name, rawTime, err)
+			}
+
+			return &t
+		}
+
+		from := parseTime("from", *rawFrom)
+		to := parseTime("to", *rawTo)
+
+		db, err := helpers.GetDBFromEnvironment(ctx)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		b, err := extensions.ExportLocationHistorys(ctx, tx, physicalThingID, *format, from, to)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		if *output == "" {
+			fmt.Printf("%v", string(b))
+			return
+		}
+
+		err = os.WriteFile(*output, b, 0o644)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+	case "purge":
+		flags := flag.NewFlagSet(command, flag.ExitOnError)
+		rawOlderThan := flags.String("older-than", "", "how long ago rows must have been soft-deleted to be purged, e.g. 90d or 36h")
+		table := flags.String("table", "", "table to purge soft-deleted rows from (their dependents are purged with them)")
+		batchSize := flags.Int("batch-size", 1000, "rows to purge per transaction")
+		dryRun := flags.Bool("dry-run", false, "report what would be purged without purging it")
+
+		_ = flags.Parse(os.Args[2:])
+
+		if *rawOlderThan == "" || *table == "" {
+			log.Fatal("usage: purge --older-than=[duration, e.g. 90d] --table=[table] [--batch-size=1000] [--dry-run]")
+		}
+
+		olderThan, err := parseRetention(*rawOlderThan)
+		if err != nil {
+			log.Fatalf("err: failed to parse --older-than=%s: %v", *rawOlderThan, err)
+		}
+
+		before := time.Now().Add(-olderThan)
+
+		db, err := helpers.GetDBFromEnvironment(ctx)
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+
+		purgeBatch := func() *extensions.PurgeReport {
+			tx, err := db.BeginTxx(ctx, nil)
+			if err != nil {
+				log.Fatalf("err: %v", err)
+			}
+			defer func() {
+				_ = tx.Rollback()
+			}()
+
+			report, err := extensions.PurgeDeleted(ctx, tx, *table, before, *batchSize, *dryRun)
+			if err != nil {
+				log.Fatalf("err: %v", err)
+			}
+
+			if *dryRun {
+				return report
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				log.Fatalf("err: %v", err)
+			}
+
+			return report
+		}
+
+		deletedByTable := make(map[string]int64)
+		detachedByTable := make(map[string]int64)
+
+		for {
+			report := purgeBatch()
+
+			for batchTable, count := range report.DeletedByTable {
+				deletedByTable[batchTable] += count
+			}
+
+			for batchTable, count := range report.DetachedByTable {
+				detachedByTable[batchTable] += count
+			}
+
+			if *dryRun {
+				break
+			}
+
+			log.Printf("purged a batch of %d %s rows soft-deleted before %v", report.DeletedByTable[*table], *table, before.Format(time.RFC3339))
+
+			if report.DeletedByTable[*table] < int64(*batchSize) {
+				break
+			}
+		}
+
+		verb := "purged"
+		if *dryRun {
+			verb = "would purge"
+		}
+
+		reportTables := maps.Keys(deletedByTable)
+		slices.Sort(reportTables)
+
+		for _, reportTable := range reportTables {
+			log.Printf("%s %d %s rows (%d others had their reference to a purged row nulled)", verb, deletedByTable[reportTable], reportTable, detachedByTable[reportTable])
+		}
+	}
+}
+
+// parseRetention is time.ParseDuration that also takes a number of days, e.g. 90d; it must be positive, as a retention of
+// zero (or less) would purge everything that's been soft-deleted
+func parseRetention(rawRetention string) (time.Duration, error) {
+	var retention time.Duration
+
+	rawDays, ok := strings.CutSuffix(rawRetention, "d")
+	if ok {
+		days, err := strconv.ParseInt(rawDays, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %#+v as a number of days: %v", rawDays, err)
+		}
+
+		if days > int64(math.MaxInt64/(24*time.Hour)) {
+			return 0, fmt.Errorf("%v days is too long", days)
+		}
+
+		retention = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		retention, err = time.ParseDuration(rawRetention)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if retention <= 0 {
+		return 0, fmt.Errorf("retention must be positive but got %v", retention)
+	}
+
+	return retention, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	testCases := []struct {
+		name         string
+		rawRetention string
+		expected     time.Duration
+		expectErr    bool
+	}{
+		{name: "days", rawRetention: "90d", expected: time.Hour * 24 * 90},
+		{name: "one day", rawRetention: "1d", expected: time.Hour * 24},
+		{name: "hours", rawRetention: "36h", expected: time.Hour * 36},
+		{name: "compound duration", rawRetention: "1h30m", expected: time.Minute * 90},
+		{name: "most days", rawRetention: "106751d", expected: time.Hour * 24 * 106751},
+		{name: "too many days", rawRetention: "106752d", expectErr: true},
+		{name: "zero days", rawRetention: "0d", expectErr: true},
+		{name: "negative days", rawRetention: "-1d", expectErr: true},
+		{name: "zero duration", rawRetention: "0s", expectErr: true},
+		{name: "negative duration", rawRetention: "-36h", expectErr: true},
+		{name: "fractional days", rawRetention: "1.5d", expectErr: true},
+		{name: "no unit", rawRetention: "90", expectErr: true},
+		{name: "just d", rawRetention: "d", expectErr: true},
+		{name: "empty", rawRetention: "", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			retention, err := parseRetention(testCase.rawRetention)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %v", retention)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if retention != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, retention)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package extensions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/initialed85/djangolang/pkg/query"
+)
+
+// cursor is the (opaque to the client) position of a row in an ordering, for keyset pagination
+type cursor struct {
+	OrderBy  []orderByColumn `json:"order_by"`
+	Values   []any           `json:"values"`
+	Backward bool            `json:"backward"`
+}
+
+func (c *cursor) String() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %#+v to JSON: %v", c, err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// getWhere returns the condition (and values for its placeholders) that selects the rows that come after the cursor
+// (or before it, if walking backward); it has to be spelled out rather than using a row comparison so that mixed
+// directions and nulls work
+func (c *cursor) getWhere() (string, []any) {
+	ors := make([]string, 0)
+	values := make([]any, 0)
+
+	equalityWheres := make([]string, 0)
+	equalityValues := make([]any, 0)
+
+	for i, orderByColumn := range c.OrderBy {
+		column := query.FormatObjectName(orderByColumn.Column)
+		value := c.Values[i]
+		descending := orderByColumn.Descending != c.Backward
+
+		var afterWhere string
+		afterValues := make([]any, 0)
+
+		if value == nil {
+			// nulls sort last for ASC (so nothing comes after them) and first for DESC
+			if descending {
+				afterWhere = fmt.Sprintf("%v IS NOT null", column)
+			}
+		} else {
+			if descending {
+				afterWhere = fmt.Sprintf("%v < $$??", column)
+			} else {
+				afterWhere = fmt.Sprintf("(%v > $$?? OR %v IS null)", column, column)
+			}
+
+			afterValues = append(afterValues, value)
+		}
+
+		if afterWhere != "" {
+			ors = append(ors, fmt.Sprintf("(%v)", strings.Join(append(slices.Clone(equalityWheres), afterWhere), " AND ")))
+			values = append(values, equalityValues...)
+			values = append(values, afterValues...)
+		}
+
+		if value == nil {
+			equalityWheres = append(equalityWheres, fmt.Sprintf("%v IS null", column))
+		} else {
+			equalityWheres = append(equalityWheres, fmt.Sprintf("%v = $$??", column))
+			equalityValues = append(equalityValues, value)
+		}
+	}
+
+	if len(ors) == 0 {
+		return "false", values
+	}
+
+	return fmt.Sprintf("(%v)", strings.Join(ors, "\n        OR ")), values
+}
+
+func unmarshalWithNumbers(b []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+// isCursorable is false if the ordering includes something that isn't a column (and so can't be put in a cursor)
+func isCursorable(orderByColumns []orderByColumn) bool {
+	return !slices.ContainsFunc(orderByColumns, func(orderByColumn orderByColumn) bool {
+		return isPseudoColumn(orderByColumn.Column)
+	})
+}
+
+func parseCursor(rawCursor string, orderByColumns []orderByColumn) (*cursor, error) {
+	if !isCursorable(orderByColumns) {
+		return nil, fmt.Errorf("cursor can't be used when ordering by %v or %v", rankOrderByColumn, distanceOrderByColumn)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(rawCursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cursor: %v", err)
+	}
+
+	c := &cursor{}
+	err = unmarshalWithNumbers(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cursor: %v", err)
+	}
+
+	if !slices.Equal(c.OrderBy, orderByColumns) {
+		return nil, fmt.Errorf("cursor was issued for a different order_by")
+	}
+
+	if len(c.Values) != len(c.OrderBy) {
+		return nil, fmt.Errorf("cursor has %d values for %d columns", len(c.Values), len(c.OrderBy))
+	}
+
+	for i, value := range c.Values {
+		switch value.(type) {
+		case nil, string, json.Number, bool:
+		default:
+			return nil, fmt.Errorf("cursor has unsupported value %#+v for %v", value, c.OrderBy[i].Column)
+		}
+	}
+
+	return c, nil
+}
+
+func newCursor(object any, orderByColumns []orderByColumn, backward bool) (string, error) {
+	b, err := json.Marshal(object)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %#+v to JSON: %v", object, err)
+	}
+
+	var item map[string]any
+	err = unmarshalWithNumbers(b, &item)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal %#+v from JSON: %v", string(b), err)
+	}
+
+	c := &cursor{
+		OrderBy:  orderByColumns,
+		Values:   make([]any, 0),
+		Backward: backward,
+	}
+
+	for _, orderByColumn := range orderByColumns {
+		value := item[orderByColumn.Column]
+
+		switch value.(type) {
+		case nil, string, json.Number, bool:
+		default:
+			return "", fmt.Errorf("column %v can't be used for cursor pagination", orderByColumn.Column)
+		}
+
+		c.Values = append(c.Values, value)
+	}
+
+	return c.String()
+}
+
+// getPage expects objects to have been selected using limit+1 (so it can tell if there's another page) and in the
+// order implied by the cursor (if any); it returns the page in the requested order along with the cursors either side
+// (unless the ordering isn't cursorable, in which case offset has to be used)
+func getPage[T any](objects []T, orderByColumns []orderByColumn, limit int, offset int, c *cursor) ([]T, *string, *string, error) {
+	backward := c != nil && c.Backward
+
+	hasMore := len(objects) > limit
+	if hasMore {
+		objects = objects[:limit]
+	}
+
+	if backward {
+		slices.Reverse(objects)
+	}
+
+	if len(objects) == 0 || !isCursorable(orderByColumns) {
+		return objects, nil, nil, nil
+	}
+
+	var nextCursor *string
+	if backward || hasMore {
+		rawCursor, err := newCursor(objects[len(objects)-1], orderByColumns, false)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		nextCursor = &rawCursor
+	}
+
+	var prevCursor *string
+	if (!backward && (c != nil || offset > 0)) || (backward && hasMore) {
+		rawCursor, err := newCursor(objects[0], orderByColumns, true)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		prevCursor = &rawCursor
+	}
+
+	return objects, nextCursor, prevCursor, nil
+}
@@ -0,0 +1,312 @@
+package extensions
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type cursorTestObject struct {
+	ID       string         `json:"id"`
+	Name     *string        `json:"name"`
+	Count    int64          `json:"count"`
+	Enabled  bool           `json:"enabled"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	name := "Thing"
+
+	testCases := []struct {
+		name           string
+		object         cursorTestObject
+		orderByColumns []orderByColumn
+		backward       bool
+		expectedValues []any
+		expectErr      bool
+	}{
+		{
+			name:           "string and number",
+			object:         cursorTestObject{ID: "a", Name: &name, Count: 9007199254740993},
+			orderByColumns: []orderByColumn{{Column: "count", Descending: true}, {Column: "id"}},
+			expectedValues: []any{json.Number("9007199254740993"), "a"},
+		},
+		{
+			name:           "null and bool, backward",
+			object:         cursorTestObject{ID: "b", Enabled: true},
+			orderByColumns: []orderByColumn{{Column: "name"}, {Column: "enabled"}, {Column: "id"}},
+			backward:       true,
+			expectedValues: []any{nil, true, "b"},
+		},
+		{
+			name:           "object column",
+			object:         cursorTestObject{ID: "c", Metadata: map[string]any{"a": "b"}},
+			orderByColumns: []orderByColumn{{Column: "metadata"}, {Column: "id"}},
+			expectErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rawCursor, err := newCursor(testCase.object, testCase.orderByColumns, testCase.backward)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", rawCursor)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			c, err := parseCursor(rawCursor, testCase.orderByColumns)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := &cursor{OrderBy: testCase.orderByColumns, Values: testCase.expectedValues, Backward: testCase.backward}
+			if !reflect.DeepEqual(c, expected) {
+				t.Fatalf("expected %#+v but got %#+v", expected, c)
+			}
+		})
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	orderByColumns := []orderByColumn{{Column: "name"}, {Column: "id"}}
+
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	testCases := []struct {
+		name           string
+		rawCursor      string
+		orderByColumns []orderByColumn
+	}{
+		{
+			name:           "not base64",
+			rawCursor:      "!!!",
+			orderByColumns: orderByColumns,
+		},
+		{
+			name:           "not JSON",
+			rawCursor:      base64.RawURLEncoding.EncodeToString([]byte("nope")),
+			orderByColumns: orderByColumns,
+		},
+		{
+			name:           "different order_by",
+			rawCursor:      encode(cursor{OrderBy: []orderByColumn{{Column: "name", Descending: true}, {Column: "id"}}, Values: []any{"a", "b"}}),
+			orderByColumns: orderByColumns,
+		},
+		{
+			name:           "wrong number of values",
+			rawCursor:      encode(cursor{OrderBy: orderByColumns, Values: []any{"a"}}),
+			orderByColumns: orderByColumns,
+		},
+		{
+			name:           "unsupported value",
+			rawCursor:      encode(cursor{OrderBy: orderByColumns, Values: []any{[]any{"a"}, "b"}}),
+			orderByColumns: orderByColumns,
+		},
+		{
+			name:           "pseudo column",
+			rawCursor:      encode(cursor{OrderBy: []orderByColumn{{Column: distanceOrderByColumn}}, Values: []any{"a"}}),
+			orderByColumns: []orderByColumn{{Column: distanceOrderByColumn}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c, err := parseCursor(testCase.rawCursor, testCase.orderByColumns)
+			if err == nil {
+				t.Fatalf("expected an error but got %#+v", c)
+			}
+		})
+	}
+}
+
+func TestCursorGetWhere(t *testing.T) {
+	testCases := []struct {
+		name           string
+		cursor         cursor
+		expectedWhere  string
+		expectedValues []any
+	}{
+		{
+			name:           "ascending",
+			cursor:         cursor{OrderBy: []orderByColumn{{Column: "name"}, {Column: "id"}}, Values: []any{"a", "b"}},
+			expectedWhere:  "(((\"name\" > $$?? OR \"name\" IS null))\n        OR (\"name\" = $$?? AND (\"id\" > $$?? OR \"id\" IS null)))",
+			expectedValues: []any{"a", "a", "b"},
+		},
+		{
+			name:           "descending",
+			cursor:         cursor{OrderBy: []orderByColumn{{Column: "name", Descending: true}, {Column: "id"}}, Values: []any{"a", "b"}},
+			expectedWhere:  "((\"name\" < $$??)\n        OR (\"name\" = $$?? AND (\"id\" > $$?? OR \"id\" IS null)))",
+			expectedValues: []any{"a", "a", "b"},
+		},
+		{
+			name:           "backward flips the direction",
+			cursor:         cursor{OrderBy: []orderByColumn{{Column: "name"}, {Column: "id"}}, Values: []any{"a", "b"}, Backward: true},
+			expectedWhere:  "((\"name\" < $$??)\n        OR (\"name\" = $$?? AND \"id\" < $$??))",
+			expectedValues: []any{"a", "a", "b"},
+		},
+		{
+			name:           "ascending null",
+			cursor:         cursor{OrderBy: []orderByColumn{{Column: "name"}, {Column: "id"}}, Values: []any{nil, "b"}},
+			expectedWhere:  "((\"name\" IS null AND (\"id\" > $$?? OR \"id\" IS null)))",
+			expectedValues: []any{"b"},
+		},
+		{
+			name:           "descending null",
+			cursor:         cursor{OrderBy: []orderByColumn{{Column: "name", Descending: true}, {Column: "id"}}, Values: []any{nil, "b"}},
+			expectedWhere:  "((\"name\" IS NOT null)\n        OR (\"name\" IS null AND (\"id\" > $$?? OR \"id\" IS null)))",
+			expectedValues: []any{"b"},
+		},
+		{
+			name:           "nothing after",
+			cursor:         cursor{OrderBy: []orderByColumn{{Column: "name"}}, Values: []any{nil}},
+			expectedWhere:  "false",
+			expectedValues: []any{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			where, values := testCase.cursor.getWhere()
+
+			if where != testCase.expectedWhere {
+				t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+			}
+
+			if !reflect.DeepEqual(values, testCase.expectedValues) {
+				t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+			}
+		})
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	orderByColumns := []orderByColumn{{Column: "id"}}
+
+	objects := func(ids ...string) []cursorTestObject {
+		objects := make([]cursorTestObject, 0)
+		for _, id := range ids {
+			objects = append(objects, cursorTestObject{ID: id})
+		}
+
+		return objects
+	}
+
+	decode := func(rawCursor *string) *cursor {
+		if rawCursor == nil {
+			return nil
+		}
+
+		c, err := parseCursor(*rawCursor, orderByColumns)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return c
+	}
+
+	testCases := []struct {
+		name         string
+		objects      []cursorTestObject
+		limit        int
+		offset       int
+		cursor       *cursor
+		expectedIDs  []string
+		expectedNext *cursor
+		expectedPrev *cursor
+	}{
+		{
+			name:         "first page with more",
+			objects:      objects("a", "b", "c"),
+			limit:        2,
+			expectedIDs:  []string{"a", "b"},
+			expectedNext: &cursor{OrderBy: orderByColumns, Values: []any{"b"}},
+		},
+		{
+			name:        "only page",
+			objects:     objects("a", "b"),
+			limit:       2,
+			expectedIDs: []string{"a", "b"},
+		},
+		{
+			name:         "last page after cursor",
+			objects:      objects("c", "d"),
+			limit:        2,
+			cursor:       &cursor{OrderBy: orderByColumns, Values: []any{"b"}},
+			expectedIDs:  []string{"c", "d"},
+			expectedPrev: &cursor{OrderBy: orderByColumns, Values: []any{"c"}, Backward: true},
+		},
+		{
+			name:         "page after offset",
+			objects:      objects("c"),
+			limit:        2,
+			offset:       2,
+			expectedIDs:  []string{"c"},
+			expectedPrev: &cursor{OrderBy: orderByColumns, Values: []any{"c"}, Backward: true},
+		},
+		{
+			name:         "backward page with more",
+			objects:      objects("d", "c", "b"),
+			limit:        2,
+			cursor:       &cursor{OrderBy: orderByColumns, Values: []any{"e"}, Backward: true},
+			expectedIDs:  []string{"c", "d"},
+			expectedNext: &cursor{OrderBy: orderByColumns, Values: []any{"d"}},
+			expectedPrev: &cursor{OrderBy: orderByColumns, Values: []any{"c"}, Backward: true},
+		},
+		{
+			name:         "backward to the first page",
+			objects:      objects("b", "a"),
+			limit:        2,
+			cursor:       &cursor{OrderBy: orderByColumns, Values: []any{"c"}, Backward: true},
+			expectedIDs:  []string{"a", "b"},
+			expectedNext: &cursor{OrderBy: orderByColumns, Values: []any{"b"}},
+		},
+		{
+			name:        "empty",
+			objects:     objects(),
+			limit:       2,
+			cursor:      &cursor{OrderBy: orderByColumns, Values: []any{"z"}},
+			expectedIDs: []string{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			page, nextCursor, prevCursor, err := getPage(testCase.objects, orderByColumns, testCase.limit, testCase.offset, testCase.cursor)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			ids := make([]string, 0)
+			for _, object := range page {
+				ids = append(ids, object.ID)
+			}
+
+			if !reflect.DeepEqual(ids, testCase.expectedIDs) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expectedIDs, ids)
+			}
+
+			if next := decode(nextCursor); !reflect.DeepEqual(next, testCase.expectedNext) {
+				t.Fatalf("expected next cursor %#+v but got %#+v", testCase.expectedNext, next)
+			}
+
+			if prev := decode(prevCursor); !reflect.DeepEqual(prev, testCase.expectedPrev) {
+				t.Fatalf("expected prev cursor %#+v but got %#+v", testCase.expectedPrev, prev)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package extensions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+var errDeletedRowsForbidden = errors.New("not authorized for soft-deleted rows of")
+
+// DeletedRowsAuthorizer decides whether the given request may see (or restore) the soft-deleted rows of the given table,
+// returning an error if not
+type DeletedRowsAuthorizer func(r *http.Request, table string) error
+
+var deletedRowsAuthorizer DeletedRowsAuthorizer
+
+// SetDeletedRowsAuthorizer sets the hook that gates the include_deleted / only_deleted params and the restore endpoint;
+// until it's set, no request is authorized for soft-deleted rows
+func SetDeletedRowsAuthorizer(authorizer DeletedRowsAuthorizer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	deletedRowsAuthorizer = authorizer
+}
+
+func authorizeDeletedRows(r *http.Request, table string) error {
+	mu.Lock()
+	authorizer := deletedRowsAuthorizer
+	mu.Unlock()
+
+	if authorizer == nil {
+		return fmt.Errorf("%w %s; no authorizer is set", errDeletedRowsForbidden, table)
+	}
+
+	err := authorizer(r, table)
+	if err != nil {
+		return fmt.Errorf("%w %s: %v", errDeletedRowsForbidden, table, err)
+	}
+
+	return nil
+}
+
+// getDeletedRowsStatus is the status for an error from getDeletedWhere / RestorePhysicalThing
+func getDeletedRowsStatus(err error) int {
+	if errors.Is(err, errDeletedRowsForbidden) {
+		return http.StatusForbidden
+	}
+
+	return http.StatusInternalServerError
+}
+
+func parseBoolParam(r *http.Request, param string) (bool, error) {
+	rawValue := r.URL.Query().Get(param)
+	if rawValue == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(rawValue)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse param %s=%s as bool: %v", param, rawValue, err)
+	}
+
+	return value, nil
+}
+
+// getDeletedWhere returns the where (if any) for the include_deleted / only_deleted params of a request for the given
+// table, having checked that the request is authorized for soft-deleted rows; wheres (from the other params) that
+// mention deleted_at also get around the soft-delete exclusion of the Select functions, so they need the same
+// authorization
+func getDeletedWhere(r *http.Request, table string, wheres []string) (string, error) {
+	includeDeleted, err := parseBoolParam(r, "include_deleted")
+	if err != nil {
+		return "", err
+	}
+
+	onlyDeleted, err := parseBoolParam(r, "only_deleted")
+	if err != nil {
+		return "", err
+	}
+
+	if includeDeleted && onlyDeleted {
+		return "", fmt.Errorf("params include_deleted and only_deleted are mutually exclusive")
+	}
+
+	if !includeDeleted && !onlyDeleted && !mentionsDeletedAt(strings.Join(wheres, "\n")) {
+		return "", nil
+	}
+
+	if !hasDeletedAt(table) {
+		return "", fmt.Errorf("%s has no soft-deleted rows", table)
+	}
+
+	err = authorizeDeletedRows(r, table)
+	if err != nil {
+		return "", err
+	}
+
+	if includeDeleted {
+		return "(deleted_at IS null OR deleted_at IS NOT null)", nil
+	}
+
+	if onlyDeleted {
+		return "deleted_at IS NOT null", nil
+	}
+
+	return "", nil
+}
+
+// restoreCascades are the tables (and their foreign key columns) that the delete_physical_things_cascade_* rules
+// soft-delete along with a physical thing
+var restoreCascades = []foreignKey{
+	{table: djangolang_example.LogicalThingTable, column: djangolang_example.LogicalThingTableParentPhysicalThingIDColumn},
+	{table: djangolang_example.LocationHistoryTable, column: djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn},
+	{table: djangolang_example.GeofenceEventTable, column: djangolang_example.GeofenceEventTablePhysicalThingIDColumn},
+}
+
+// RestorePhysicalThing clears the deleted_at of the given (soft-deleted) physical thing and of the rows that were
+// soft-deleted along with it by the delete_physical_things_cascade_* rules, i.e. those with the same deletion_batch_id
+// (but not of those that were deleted before or after it); a physical thing without a deletion_batch_id (e.g. one that
+// was soft-deleted by setting deleted_at) is restored on its own
+func RestorePhysicalThing(ctx context.Context, tx *sqlx.Tx, physicalThingID uuid.UUID) (*djangolang_example.PhysicalThing, error) {
+	object, err := djangolang_example.SelectPhysicalThing(
+		WithExpansionDepth(ctx, 0),
+		tx,
+		fmt.Sprintf("%s = $$??\n    AND %s IS NOT null", djangolang_example.PhysicalThingTablePrimaryKeyColumn, djangolang_example.PhysicalThingTableDeletedAtColumn),
+		physicalThingID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find soft-deleted %s %v: %v", djangolang_example.PhysicalThingTable, physicalThingID, err)
+	}
+
+	deletionBatchID := object.DeletionBatchID
+
+	// note: clearing deleted_at also clears deletion_batch_id (see the set_deletion_batch_id trigger)
+	object.DeletedAt = nil
+
+	err = object.Update(ctx, tx, false, djangolang_example.PhysicalThingTableDeletedAtColumn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore %s %v: %v", djangolang_example.PhysicalThingTable, physicalThingID, err)
+	}
+
+	if deletionBatchID == nil {
+		return object, nil
+	}
+
+	for _, cascade := range restoreCascades {
+		sql := formatPlaceholders(fmt.Sprintf(
+			`UPDATE %s
+SET
+    deleted_at = null
+WHERE
+    %s = $$??
+    AND %s = $$??;`,
+			query.FormatObjectName(cascade.table),
+			formatColumn("", cascade.column),
+			formatColumn("", djangolang_example.PhysicalThingTableDeletionBatchIDColumn),
+		))
+
+		values := []any{physicalThingID, *deletionBatchID}
+
+		logQuery(sql, values)
+
+		_, err = tx.ExecContext(ctx, sql, values...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s for %s %v: %v", cascade.table, djangolang_example.PhysicalThingTable, physicalThingID, err)
+		}
+	}
+
+	return object, nil
+}
+
+func handlePostPhysicalThingRestore(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	_ = redisConn
+
+	physicalThingID, err := uuid.Parse(primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse primary key %s: %v", primaryKey, err))
+		return
+	}
+
+	err = authorizeDeletedRows(r, djangolang_example.PhysicalThingTable)
+	if err != nil {
+		helpers.HandleErrorResponse(w, getDeletedRowsStatus(err), err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	object, err := RestorePhysicalThing(r.Context(), tx, physicalThingID)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusOK, []*djangolang_example.PhysicalThing{object})
+}
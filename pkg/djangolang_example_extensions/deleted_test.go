@@ -0,0 +1,173 @@
+package extensions
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+func TestGetDeletedWhere(t *testing.T) {
+	t.Cleanup(func() {
+		SetDeletedRowsAuthorizer(nil)
+	})
+
+	allow := func(r *http.Request, table string) error {
+		return nil
+	}
+
+	deny := func(r *http.Request, table string) error {
+		return fmt.Errorf("denied")
+	}
+
+	testCases := []struct {
+		name           string
+		authorizer     DeletedRowsAuthorizer
+		table          string
+		rawQuery       string
+		wheres         []string
+		expectedWhere  string
+		expectedStatus int
+	}{
+		{
+			name:          "neither param needs no authorizer",
+			table:         djangolang_example.PhysicalThingTable,
+			rawQuery:      "include_deleted=false",
+			wheres:        []string{`"name" = $$??`},
+			expectedWhere: "",
+		},
+		{
+			name:           "include_deleted without an authorizer",
+			table:          djangolang_example.PhysicalThingTable,
+			rawQuery:       "include_deleted=true",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "include_deleted with a failing authorizer",
+			authorizer:     deny,
+			table:          djangolang_example.PhysicalThingTable,
+			rawQuery:       "include_deleted=true",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:          "include_deleted",
+			authorizer:    allow,
+			table:         djangolang_example.PhysicalThingTable,
+			rawQuery:      "include_deleted=true",
+			expectedWhere: "(deleted_at IS null OR deleted_at IS NOT null)",
+		},
+		{
+			name:          "only_deleted",
+			authorizer:    allow,
+			table:         djangolang_example.PhysicalThingTable,
+			rawQuery:      "only_deleted=1",
+			expectedWhere: "deleted_at IS NOT null",
+		},
+		{
+			name:           "both",
+			authorizer:     allow,
+			table:          djangolang_example.PhysicalThingTable,
+			rawQuery:       "include_deleted=true&only_deleted=true",
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "not a bool",
+			authorizer:     allow,
+			table:          djangolang_example.PhysicalThingTable,
+			rawQuery:       "only_deleted=maybe",
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "deleted_at filter without an authorizer",
+			table:          djangolang_example.PhysicalThingTable,
+			wheres:         []string{`"deleted_at" IS NOT NULL`},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:          "deleted_at filter",
+			authorizer:    allow,
+			table:         djangolang_example.PhysicalThingTable,
+			wheres:        []string{`"deleted_at" IS NOT NULL`},
+			expectedWhere: "",
+		},
+		{
+			name:          "deleted_at of a foreign table needs no authorizer",
+			table:         djangolang_example.LogicalThingTable,
+			wheres:        []string{`EXISTS (SELECT 1 FROM "physical_things" AS "__fk_1" WHERE "__fk_1"."deleted_at" IS null)`},
+			expectedWhere: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			SetDeletedRowsAuthorizer(testCase.authorizer)
+
+			r, err := http.NewRequest(http.MethodGet, "/physical-things?"+testCase.rawQuery, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			where, err := getDeletedWhere(r, testCase.table, testCase.wheres)
+			if testCase.expectedStatus != 0 {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", where)
+				}
+
+				status := getDeletedRowsStatus(err)
+				if status != testCase.expectedStatus {
+					t.Fatalf("expected status %d but got %d for %v", testCase.expectedStatus, status, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if where != testCase.expectedWhere {
+				t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+			}
+
+			// the Select functions leave out soft-deleted rows unless the where mentions deleted_at
+			if where != "" && !mentionsDeletedAt(where) {
+				t.Fatalf("expected %#+v to mention deleted_at", where)
+			}
+		})
+	}
+}
+
+func TestNewBearerTokenAuthorizer(t *testing.T) {
+	testCases := []struct {
+		name          string
+		token         string
+		authorization string
+		expectErr     bool
+	}{
+		{name: "right token", token: "secret", authorization: "Bearer secret"},
+		{name: "wrong token", token: "secret", authorization: "Bearer guess", expectErr: true},
+		{name: "prefix of the token", token: "secret", authorization: "Bearer secre", expectErr: true},
+		{name: "not a bearer token", token: "secret", authorization: "Basic secret", expectErr: true},
+		{name: "no header", token: "secret", expectErr: true},
+		{name: "empty token authorizes nothing", token: "", authorization: "Bearer ", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/physical-things", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if testCase.authorization != "" {
+				r.Header.Set("Authorization", testCase.authorization)
+			}
+
+			err = NewBearerTokenAuthorizer(testCase.token)(r, djangolang_example.PhysicalThingTable)
+			if testCase.expectErr != (err != nil) {
+				t.Fatalf("expected error %v but got %v", testCase.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultDistinctLimit is the default page size of distinct values, as for the list endpoints
+const defaultDistinctLimit = 2000
+
+// DistinctValue is a value of a column along with how many (non-deleted) rows have it; for array columns each element
+// is a value in its own right, counted once per row it appears in
+type DistinctValue struct {
+	Value json.RawMessage `json:"value"`
+	Count int64           `json:"count"`
+}
+
+// getDistinctValuesSQL returns the query for GetDistinctValues
+func getDistinctValuesSQL(table string, column string, limit int, offset int, wheres []string) (string, error) {
+	_, ok := columnLookupByTable[table][column]
+	if !ok {
+		return "", fmt.Errorf("unknown column %#+v", column)
+	}
+
+	kind := columnKindsByTable[table][column]
+	if kind == columnKindPoint || kind == columnKindPolygon {
+		return "", fmt.Errorf("can't get distinct values of %s column %#+v", kind, column)
+	}
+
+	from := query.FormatObjectName(table)
+	value := formatColumn("", column)
+
+	if kind == columnKindArray {
+		from = fmt.Sprintf("%s CROSS JOIN LATERAL unnest(%s) AS __distinct(__value)", from, formatColumn(table, column))
+		value = formatColumn("__distinct", "__value")
+	}
+
+	wheres = append(wheres, fmt.Sprintf("%s IS NOT null", value))
+
+	if hasDeletedAt(table) {
+		wheres = append(wheres, fmt.Sprintf("%s IS null", formatColumn(table, "deleted_at")))
+	}
+
+	sql := fmt.Sprintf(
+		`SELECT
+    to_jsonb(%s),
+    count(*)
+FROM
+    %s
+WHERE
+    %s
+GROUP BY
+    1
+ORDER BY
+    2 DESC, 1 ASC
+LIMIT %d
+OFFSET %d;`,
+		value,
+		from,
+		strings.Join(wheres, "\n    AND "),
+		limit,
+		offset,
+	)
+
+	return sql, nil
+}
+
+// GetDistinctValues returns the (non-null) distinct values of the given column of the given table amongst the
+// (non-deleted) rows matching the wheres, most common first; array columns are unnested
+func GetDistinctValues(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table string,
+	column string,
+	limit int,
+	offset int,
+	wheres []string,
+	values ...any,
+) ([]*DistinctValue, error) {
+	sql, err := getDistinctValuesSQL(table, column, limit, offset, wheres)
+	if err != nil {
+		return nil, err
+	}
+
+	distinctValues := make([]*DistinctValue, 0)
+
+	err = queryRows(ctx, tx, sql, values, func(rows *sqlx.Rows) error {
+		v := &DistinctValue{}
+
+		var b []byte
+
+		err := rows.Scan(&b, &v.Count)
+		if err != nil {
+			return err
+		}
+
+		v.Value = json.RawMessage(b)
+
+		distinctValues = append(distinctValues, v)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return distinctValues, nil
+}
+
+func handleGetDistinctValues(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, table string, column string) {
+	ctx := r.Context()
+
+	limit, err := parseNonNegativeInt(r, "limit")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if limit == nil {
+		limit = helpers.Ptr(defaultDistinctLimit)
+	}
+
+	offset, err := parseNonNegativeInt(r, "offset")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	wheres, values, err := getQueryFilterWheres(table, r.URL.Query(), "limit", "offset")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	requestHash, err := getDerivedRequestHash(
+		table,
+		wheres,
+		values,
+		"DISTINCT",
+		fmt.Sprintf("COLUMN %v", column),
+		fmt.Sprintf("LIMIT %v", *limit),
+		fmt.Sprintf("OFFSET %v", helpers.Deref(offset)),
+	)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	distinctValues, err := GetDistinctValues(ctx, tx, table, column, *limit, helpers.Deref(offset), wheres, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, distinctValues)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package extensions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+func TestGetDistinctValuesSQL(t *testing.T) {
+	testCases := []struct {
+		name             string
+		table            string
+		column           string
+		expectedContains []string
+		expectErr        bool
+	}{
+		{
+			name:   "scalar column",
+			table:  djangolang_example.PhysicalThingTable,
+			column: "type",
+			expectedContains: []string{
+				`to_jsonb("type")`,
+				`"name" = $$??`,
+				`"type" IS NOT null`,
+				`"physical_things"."deleted_at" IS null`,
+				"LIMIT 10\nOFFSET 20;",
+			},
+		},
+		{
+			name:   "array column is unnested",
+			table:  djangolang_example.PhysicalThingTable,
+			column: "tags",
+			expectedContains: []string{
+				`to_jsonb("__distinct"."__value")`,
+				`CROSS JOIN LATERAL unnest("physical_things"."tags") AS __distinct(__value)`,
+				`"__distinct"."__value" IS NOT null`,
+			},
+		},
+		{
+			name:      "point column",
+			table:     djangolang_example.LocationHistoryTable,
+			column:    "point",
+			expectErr: true,
+		},
+		{
+			name:      "unknown column",
+			table:     djangolang_example.PhysicalThingTable,
+			column:    "colour",
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			sql, err := getDistinctValuesSQL(testCase.table, testCase.column, 10, 20, []string{`"name" = $$??`})
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", sql)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, expected := range testCase.expectedContains {
+				if !strings.Contains(sql, expected) {
+					t.Fatalf("expected %#+v to contain %#+v", sql, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package extensions
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/initialed85/djangolang/pkg/introspect"
+)
+
+// parseFields turns something like "id,name,tags" into the columns to return for the given table; no fields means all
+// of them
+func parseFields(rawFields string, columnLookup map[string]*introspect.Column) ([]string, error) {
+	fields := make([]string, 0)
+
+	for _, rawPart := range strings.Split(rawFields, ",") {
+		part := strings.TrimSpace(rawPart)
+		if part == "" {
+			continue
+		}
+
+		_, ok := columnLookup[part]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized column %#+v", part)
+		}
+
+		if slices.Contains(fields, part) {
+			continue
+		}
+
+		fields = append(fields, part)
+	}
+
+	return fields, nil
+}
+
+// getColumnsToSelect narrows columnsWithTypeCasts (which must line up with columns) down to the given fields plus the
+// columns needed to order by (and so to build cursors)
+func getColumnsToSelect(fields []string, orderByColumns []orderByColumn, columns []string, columnsWithTypeCasts []string) []string {
+	if len(fields) == 0 {
+		return columnsWithTypeCasts
+	}
+
+	columnsToSelect := make([]string, 0)
+
+	for i, column := range columns {
+		isOrderByColumn := slices.ContainsFunc(orderByColumns, func(orderByColumn orderByColumn) bool {
+			return orderByColumn.Column == column
+		})
+
+		if !slices.Contains(fields, column) && !isOrderByColumn {
+			continue
+		}
+
+		columnsToSelect = append(columnsToSelect, columnsWithTypeCasts[i])
+	}
+
+	return columnsToSelect
+}
+
+// projectFields reduces each of objects to just the given fields (along with the _object expansion of any foreign key
+// fields) so that unselected fields are omitted from the JSON rather than coming through as zero values
+func projectFields[T any](objects []T, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return objects, nil
+	}
+
+	keys := make([]string, 0)
+	for _, field := range fields {
+		keys = append(keys, field, fmt.Sprintf("%v_object", field))
+	}
+
+	projectedObjects := make([]map[string]json.RawMessage, 0)
+
+	for _, object := range objects {
+		b, err := json.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %#+v to JSON: %v", object, err)
+		}
+
+		var item map[string]json.RawMessage
+		err = json.Unmarshal(b, &item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %#+v from JSON: %v", string(b), err)
+		}
+
+		projectedObject := make(map[string]json.RawMessage)
+
+		for _, key := range keys {
+			value, ok := item[key]
+			if !ok {
+				continue
+			}
+
+			projectedObject[key] = value
+		}
+
+		projectedObjects = append(projectedObjects, projectedObject)
+	}
+
+	return projectedObjects, nil
+}
@@ -0,0 +1,122 @@
+package extensions
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/initialed85/djangolang/pkg/introspect"
+)
+
+func TestParseFields(t *testing.T) {
+	columnLookup := map[string]*introspect.Column{
+		"id":   {Name: "id"},
+		"name": {Name: "name"},
+		"tags": {Name: "tags"},
+	}
+
+	testCases := []struct {
+		name      string
+		rawFields string
+		expected  []string
+		expectErr bool
+	}{
+		{name: "empty", rawFields: "", expected: []string{}},
+		{name: "some", rawFields: "name, tags", expected: []string{"name", "tags"}},
+		{name: "duplicates", rawFields: "name,name,,id", expected: []string{"name", "id"}},
+		{name: "unrecognized column", rawFields: "name,colour", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fields, err := parseFields(testCase.rawFields, columnLookup)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", fields)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(fields, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, fields)
+			}
+		})
+	}
+}
+
+func TestGetColumnsToSelect(t *testing.T) {
+	columns := []string{"id", "name", "updated_at", "point"}
+	columnsWithTypeCasts := []string{"id", "name", "updated_at", "point::text"}
+
+	testCases := []struct {
+		name           string
+		fields         []string
+		orderByColumns []orderByColumn
+		expected       []string
+	}{
+		{
+			name:     "no fields means all columns",
+			expected: columnsWithTypeCasts,
+		},
+		{
+			name:           "fields plus the columns to order by, in column order",
+			fields:         []string{"point"},
+			orderByColumns: []orderByColumn{{Column: "updated_at", Descending: true}, {Column: "id"}},
+			expected:       []string{"id", "updated_at", "point::text"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			columnsToSelect := getColumnsToSelect(testCase.fields, testCase.orderByColumns, columns, columnsWithTypeCasts)
+			if !reflect.DeepEqual(columnsToSelect, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, columnsToSelect)
+			}
+		})
+	}
+}
+
+func TestProjectFields(t *testing.T) {
+	type projectFieldsTestObject struct {
+		ID           string  `json:"id"`
+		Name         string  `json:"name"`
+		Count        int64   `json:"count"`
+		Parent       *string `json:"parent"`
+		ParentObject *string `json:"parent_object"`
+	}
+
+	objects := []projectFieldsTestObject{{ID: "a", Name: "A", Count: 0, Parent: nil}}
+
+	testCases := []struct {
+		name     string
+		fields   []string
+		expected string
+	}{
+		{name: "no fields", expected: `[{"id":"a","name":"A","count":0,"parent":null,"parent_object":null}]`},
+		{name: "zero values are kept", fields: []string{"count"}, expected: `[{"count":0}]`},
+		{name: "foreign key brings its object", fields: []string{"id", "parent"}, expected: `[{"id":"a","parent":null,"parent_object":null}]`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			projectedObjects, err := projectFields(objects, testCase.fields)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			b, err := json.Marshal(projectedObjects)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(b) != testCase.expected {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, string(b))
+			}
+		})
+	}
+}
@@ -0,0 +1,648 @@
+package extensions
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/lib/pq"
+	"github.com/lib/pq/hstore"
+	"golang.org/x/exp/maps"
+)
+
+var errUnrecognizedFilter = errors.New("unrecognized filter")
+
+const (
+	// filterMaxDepth limits how deeply filter expressions may be nested
+	filterMaxDepth = 16
+
+	// filterMaxForeignKeyDepth limits how many foreign keys a filter may traverse
+	filterMaxForeignKeyDepth = 4
+)
+
+type filterOperator struct {
+	comparison string
+	isSlice    bool
+	isNull     bool
+	isLike     bool
+}
+
+// filterOperators is the table of the operators permitted in a column__operator=value filter
+var filterOperators = map[string]filterOperator{
+	"eq":        {comparison: "="},
+	"ne":        {comparison: "!="},
+	"gt":        {comparison: ">"},
+	"gte":       {comparison: ">="},
+	"lt":        {comparison: "<"},
+	"lte":       {comparison: "<="},
+	"in":        {comparison: "IN", isSlice: true},
+	"nin":       {comparison: "NOT IN", isSlice: true},
+	"notin":     {comparison: "NOT IN", isSlice: true},
+	"isnull":    {comparison: "IS NULL", isNull: true},
+	"nisnull":   {comparison: "IS NOT NULL", isNull: true},
+	"isnotnull": {comparison: "IS NOT NULL", isNull: true},
+	"l":         {comparison: "LIKE", isLike: true},
+	"like":      {comparison: "LIKE", isLike: true},
+	"nl":        {comparison: "NOT LIKE", isLike: true},
+	"nlike":     {comparison: "NOT LIKE", isLike: true},
+	"notlike":   {comparison: "NOT LIKE", isLike: true},
+	"il":        {comparison: "ILIKE", isLike: true},
+	"ilike":     {comparison: "ILIKE", isLike: true},
+	"nil":       {comparison: "NOT ILIKE", isLike: true},
+	"nilike":    {comparison: "NOT ILIKE", isLike: true},
+	"notilike":  {comparison: "NOT ILIKE", isLike: true},
+}
+
+// typedFilterOperator is an operator that only applies to a particular kind of column; template is formatted with the
+// column and has exactly one placeholder
+// typedFilterOperator is formatted as template (with the column) and a value from parseValue, unless it has a
+// formatWhere, which formats the whole where (without any values) for operators that need their value more than once
+type typedFilterOperator struct {
+	template    string
+	parseValue  func(rawValue string) (any, error)
+	formatWhere func(column string, rawValue string) (string, error)
+	description string
+}
+
+// typedFilterOperators is (for each kind of column) the table of the extra operators permitted in a
+// column__operator=value filter
+var typedFilterOperators = map[columnKind]map[string]typedFilterOperator{
+	columnKindArray: {
+		"contains": {
+			template:    "%s @> $$??",
+			parseValue:  parseArrayFilterValue,
+			description: "SQL @> operator, true if the array contains all of the values; permits comma-separated values or a JSON array",
+		},
+		"overlaps": {
+			template:    "%s && $$??",
+			parseValue:  parseArrayFilterValue,
+			description: "SQL && operator, true if the array contains any of the values; permits comma-separated values or a JSON array",
+		},
+	},
+	columnKindHstore: {
+		"haskey": {
+			template:    "%s ? $$??",
+			parseValue:  parseStringFilterValue,
+			description: "SQL ? operator, true if the hstore contains the key",
+		},
+		"haskeys": {
+			template:    "%s ?& $$??",
+			parseValue:  parseArrayFilterValue,
+			description: "SQL ?& operator, true if the hstore contains all of the keys; permits comma-separated values or a JSON array",
+		},
+		"contains": {
+			template:    "%s @> $$??",
+			parseValue:  parseHstoreFilterValue,
+			description: "SQL @> operator, true if the hstore contains all of the key-value pairs of the given JSON object, e.g. {\"k\":\"v\"}",
+		},
+	},
+	columnKindTSVector: {
+		"search": {
+			template:    "%s @@ websearch_to_tsquery($$??)",
+			parseValue:  parseStringFilterValue,
+			description: "SQL @@ operator against websearch_to_tsquery, i.e. search engine syntax (unquoted words, \"quoted phrases\", or, -); permits order_by=-rank",
+		},
+	},
+	columnKindJSONB: {
+		"contains": {
+			template:    "%s @> $$??",
+			parseValue:  parseJSONFilterValue,
+			description: "SQL @> operator, true if the JSON contains the given JSON, e.g. {\"k\":\"v\"}",
+		},
+		"path": {
+			template:    "%s @? $$??",
+			parseValue:  parseStringFilterValue,
+			description: "SQL @? operator, true if the given JSONPath returns any items, e.g. $.battery ? (@ < 20)",
+		},
+	},
+	columnKindPoint: {
+		"within_bbox": {
+			template:    "%s <@ $$??::box",
+			parseValue:  parseBoxFilterValue,
+			description: "SQL <@ operator, true if the point is within the bounding box given as minx,miny,maxx,maxy",
+		},
+		"near": {
+			formatWhere: formatNearFilterWhere,
+			description: "True if the point is within the radius (in metres, by haversine distance) of the point given as longitude,latitude,radius; permits order_by=distance",
+		},
+	},
+	columnKindPolygon: {
+		"contains_point": {
+			template:    "%s @> $$??::point",
+			parseValue:  parsePointFilterValue,
+			description: "SQL @> operator, true if the polygon contains the point given as x,y",
+		},
+		"intersects_bbox": {
+			template:    "%s && polygon($$??::box)",
+			parseValue:  parseBoxFilterValue,
+			description: "SQL && operator, true if the polygon overlaps the bounding box given as minx,miny,maxx,maxy",
+		},
+	},
+}
+
+func parseStringFilterValue(rawValue string) (any, error) {
+	return rawValue, nil
+}
+
+// parseArrayFilterValue turns a JSON array (of scalars) or comma-separated values into a Postgres array; it's sent as
+// text, so Postgres takes care of casting it to the type of the column
+func parseArrayFilterValue(rawValue string) (any, error) {
+	values, err := parseFilterValue(rawValue, filterOperator{isSlice: true})
+	if err != nil {
+		return nil, err
+	}
+
+	array := make(pq.StringArray, 0)
+
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			array = append(array, v)
+		case float64:
+			array = append(array, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			array = append(array, strconv.FormatBool(v))
+		default:
+			return nil, fmt.Errorf("unsupported array item %#+v", value)
+		}
+	}
+
+	return array, nil
+}
+
+func parseHstoreFilterValue(rawValue string) (any, error) {
+	var object map[string]*string
+	err := json.Unmarshal([]byte(rawValue), &object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %#+v as a JSON object of strings: %v", rawValue, err)
+	}
+
+	value := hstore.Hstore{Map: make(map[string]sql.NullString)}
+
+	for k, v := range object {
+		if v == nil {
+			value.Map[k] = sql.NullString{}
+			continue
+		}
+
+		value.Map[k] = sql.NullString{String: *v, Valid: true}
+	}
+
+	return value, nil
+}
+
+func parseJSONFilterValue(rawValue string) (any, error) {
+	if !json.Valid([]byte(rawValue)) {
+		return nil, fmt.Errorf("failed to parse %#+v as JSON", rawValue)
+	}
+
+	return rawValue, nil
+}
+
+// parseFilterValue interprets rawValue as JSON if possible (so numbers, booleans etc come through typed) and as a string
+// otherwise; slice operators accept either a JSON array or comma-separated values
+func parseFilterValue(rawValue string, operator filterOperator) ([]any, error) {
+	attempts := make([]string, 0)
+
+	if !operator.isLike {
+		attempts = append(attempts, rawValue)
+	}
+
+	if operator.isSlice {
+		attempts = append(attempts, fmt.Sprintf("[%s]", rawValue))
+
+		vs := make([]string, 0)
+		for _, v := range strings.Split(rawValue, ",") {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+
+			vs = append(vs, string(b))
+		}
+
+		attempts = append(attempts, fmt.Sprintf("[%s]", strings.Join(vs, ",")))
+	}
+
+	if operator.isLike {
+		b, err := json.Marshal(fmt.Sprintf("%%%s%%", rawValue))
+		if err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, string(b))
+	} else {
+		b, err := json.Marshal(rawValue)
+		if err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, string(b))
+	}
+
+	for _, attempt := range attempts {
+		var value any
+		err := json.Unmarshal([]byte(attempt), &value)
+		if err != nil {
+			continue
+		}
+
+		if !operator.isSlice {
+			return []any{value}, nil
+		}
+
+		sliceValues, ok := value.([]any)
+		if !ok || len(sliceValues) == 0 {
+			continue
+		}
+
+		return sliceValues, nil
+	}
+
+	return nil, fmt.Errorf("failed to parse %#+v", rawValue)
+}
+
+// getFilterWhere compiles a single column__operator=value filter into a where (using $$?? placeholders) and its values;
+// the column may be a foreign key followed by a column (and so on) on the foreign table, e.g.
+// parent_physical_thing_id__type__eq, in which case it becomes an EXISTS on the foreign table
+func getFilterWhere(table string, rawKey string, rawValue string) (string, []any, error) {
+	return getQualifiedFilterWhere(table, "", 0, rawKey, rawValue)
+}
+
+func formatColumn(qualifier string, column string) string {
+	if qualifier == "" {
+		return query.FormatObjectName(column)
+	}
+
+	return fmt.Sprintf("%s.%s", query.FormatObjectName(qualifier), query.FormatObjectName(column))
+}
+
+func getQualifiedFilterWhere(table string, qualifier string, depth int, rawKey string, rawValue string) (string, []any, error) {
+	parts := strings.Split(rawKey, "__")
+	if len(parts) < 2 {
+		return "", nil, fmt.Errorf("%w %#+v", errUnrecognizedFilter, rawKey)
+	}
+
+	_, ok := columnLookupByTable[table][parts[0]]
+	if !ok {
+		return "", nil, fmt.Errorf("%w %#+v; unknown column %#+v", errUnrecognizedFilter, rawKey, parts[0])
+	}
+
+	if len(parts) > 2 {
+		return getForeignKeyFilterWhere(table, qualifier, depth, parts[0], strings.Join(parts[1:], "__"), rawValue)
+	}
+
+	column := formatColumn(qualifier, parts[0])
+
+	typedOperator, ok := typedFilterOperators[columnKindsByTable[table][parts[0]]][parts[1]]
+	if ok && typedOperator.formatWhere != nil {
+		where, err := typedOperator.formatWhere(column, rawValue)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return where, []any{}, nil
+	}
+
+	if ok {
+		value, err := typedOperator.parseValue(rawValue)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return fmt.Sprintf(typedOperator.template, column), []any{value}, nil
+	}
+
+	operator, ok := filterOperators[parts[1]]
+	if !ok {
+		return "", nil, fmt.Errorf("%w %#+v; unknown operator %#+v", errUnrecognizedFilter, rawKey, parts[1])
+	}
+
+	if operator.isNull {
+		return fmt.Sprintf("%s %s", column, operator.comparison), []any{}, nil
+	}
+
+	values, err := parseFilterValue(rawValue, operator)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if operator.isSlice {
+		placeholders := make([]string, 0)
+		for range values {
+			placeholders = append(placeholders, "$$??")
+		}
+
+		return fmt.Sprintf("%s %s (%s)", column, operator.comparison, strings.Join(placeholders, ", ")), values, nil
+	}
+
+	return fmt.Sprintf("%s %s $$??", column, operator.comparison), values, nil
+}
+
+// getForeignKeyFilterWhere compiles a filter on the row referred to by a foreign key into an EXISTS subquery; soft-deleted
+// foreign rows are ignored, and everything inside the subquery is qualified by alias so that the deleted_at references
+// can't be confused for those of the outer table (see mentionsDeletedAt)
+func getForeignKeyFilterWhere(table string, qualifier string, depth int, column string, rawKey string, rawValue string) (string, []any, error) {
+	fk, ok := foreignKeysByTable[table][column]
+	if !ok {
+		return "", nil, fmt.Errorf("%w %v__%v; column %#+v is not a foreign key", errUnrecognizedFilter, column, rawKey, column)
+	}
+
+	if depth >= filterMaxForeignKeyDepth {
+		return "", nil, fmt.Errorf("filter traverses more than %d foreign keys", filterMaxForeignKeyDepth)
+	}
+
+	if qualifier == "" {
+		qualifier = table
+	}
+
+	alias := fmt.Sprintf("__fk_%d", depth+1)
+
+	where, values, err := getQualifiedFilterWhere(fk.table, alias, depth+1, rawKey, rawValue)
+	if err != nil {
+		return "", nil, err
+	}
+
+	wheres := []string{
+		fmt.Sprintf("%s = %s", formatColumn(alias, fk.column), formatColumn(qualifier, column)),
+	}
+
+	if hasDeletedAt(fk.table) {
+		wheres = append(wheres, fmt.Sprintf("%s IS null", formatColumn(alias, "deleted_at")))
+	}
+
+	wheres = append(wheres, where)
+
+	return fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM %s AS %s WHERE %s)",
+		query.FormatObjectName(fk.table),
+		query.FormatObjectName(alias),
+		strings.Join(wheres, " AND "),
+	), values, nil
+}
+
+// filterExpression is a parsed filter param; it's either a group (and / or / not) of child expressions or (if operator is
+// empty) a single column__operator:value filter
+type filterExpression struct {
+	operator string
+	children []*filterExpression
+	key      string
+	value    string
+}
+
+func (e *filterExpression) getWhere(table string) (string, []any, error) {
+	if e.operator == "" {
+		where, values, err := getFilterWhere(table, e.key, e.value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%v:%v: %v", e.key, e.value, err)
+		}
+
+		return where, values, nil
+	}
+
+	wheres := make([]string, 0)
+	values := make([]any, 0)
+
+	for _, child := range e.children {
+		childWhere, childValues, err := child.getWhere(table)
+		if err != nil {
+			return "", nil, err
+		}
+
+		wheres = append(wheres, childWhere)
+		values = append(values, childValues...)
+	}
+
+	switch e.operator {
+	case "and":
+		return fmt.Sprintf("(%s)", strings.Join(wheres, " AND ")), values, nil
+	case "or":
+		return fmt.Sprintf("(%s)", strings.Join(wheres, " OR ")), values, nil
+	case "not":
+		return fmt.Sprintf("NOT (%s)", wheres[0]), values, nil
+	}
+
+	return "", nil, fmt.Errorf("unknown group %#+v", e.operator)
+}
+
+type filterParser struct {
+	s string
+	i int
+}
+
+func (p *filterParser) peek() byte {
+	if p.i >= len(p.s) {
+		return 0
+	}
+
+	return p.s[p.i]
+}
+
+func (p *filterParser) skipSpaces() {
+	for p.peek() == ' ' {
+		p.i++
+	}
+}
+
+func (p *filterParser) parseName() string {
+	start := p.i
+
+	for {
+		c := p.peek()
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			break
+		}
+
+		p.i++
+	}
+
+	return p.s[start:p.i]
+}
+
+// parseValue reads either a JSON string (for values that contain commas or parentheses) or everything up to the next
+// comma or closing parenthesis
+func (p *filterParser) parseValue() (string, error) {
+	start := p.i
+
+	if p.peek() == '"' {
+		p.i++
+
+		for p.i < len(p.s) {
+			c := p.s[p.i]
+
+			if c == '\\' {
+				p.i += 2
+				continue
+			}
+
+			p.i++
+
+			if c == '"' {
+				var value string
+				err := json.Unmarshal([]byte(p.s[start:p.i]), &value)
+				if err != nil {
+					return "", fmt.Errorf("failed to parse quoted value at position %d: %v", start, err)
+				}
+
+				return value, nil
+			}
+		}
+
+		return "", fmt.Errorf("unterminated quoted value at position %d", start)
+	}
+
+	for p.i < len(p.s) && p.s[p.i] != ',' && p.s[p.i] != ')' {
+		p.i++
+	}
+
+	return strings.TrimSpace(p.s[start:p.i]), nil
+}
+
+func (p *filterParser) parseExpression(depth int) (*filterExpression, error) {
+	if depth > filterMaxDepth {
+		return nil, fmt.Errorf("expression nested more than %d deep", filterMaxDepth)
+	}
+
+	p.skipSpaces()
+
+	start := p.i
+	name := p.parseName()
+	if name == "" {
+		return nil, fmt.Errorf("expected group or filter at position %d", start)
+	}
+
+	p.skipSpaces()
+
+	switch p.peek() {
+	case '(':
+		operator := strings.ToLower(name)
+		if operator != "and" && operator != "or" && operator != "not" {
+			return nil, fmt.Errorf("unknown group %#+v at position %d; must be one of and, or, not", name, start)
+		}
+
+		p.i++
+
+		e := &filterExpression{
+			operator: operator,
+			children: make([]*filterExpression, 0),
+		}
+
+		for {
+			child, err := p.parseExpression(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			e.children = append(e.children, child)
+
+			p.skipSpaces()
+
+			c := p.peek()
+			p.i++
+
+			if c == ')' {
+				break
+			}
+
+			if c != ',' {
+				return nil, fmt.Errorf("expected , or ) at position %d", p.i-1)
+			}
+		}
+
+		if operator == "not" && len(e.children) != 1 {
+			return nil, fmt.Errorf("not at position %d must have exactly 1 child", start)
+		}
+
+		return e, nil
+
+	case ':':
+		p.i++
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		return &filterExpression{key: name, value: value}, nil
+	}
+
+	return nil, fmt.Errorf("expected ( or : at position %d", p.i)
+}
+
+// parseFilterExpression parses something like `or(type__eq:A,and(name__ilike:foo,not(external_id__isnull:)))`; values
+// containing commas or parentheses (e.g. for in) must be quoted as JSON strings, e.g. `id__in:"a,b"`
+func parseFilterExpression(rawFilter string) (*filterExpression, error) {
+	p := &filterParser{s: rawFilter}
+
+	e, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+
+	if p.i != len(p.s) {
+		return nil, fmt.Errorf("unexpected %#+v at position %d", p.s[p.i:], p.i)
+	}
+
+	return e, nil
+}
+
+// getFilterExpressionWhere compiles a filter param into a where (using $$?? placeholders) and its values
+func getFilterExpressionWhere(table string, rawFilter string) (string, []any, error) {
+	e, err := parseFilterExpression(rawFilter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return e.getWhere(table)
+}
+
+// getQueryFilterWheres compiles the column__operator=value and filter params of a request into wheres (using $$??
+// placeholders) and their values, for endpoints other than the list endpoint that take the same filters; any params in
+// skippedKeys are left for the caller
+func getQueryFilterWheres(table string, query url.Values, skippedKeys ...string) ([]string, []any, error) {
+	wheres := make([]string, 0)
+	values := make([]any, 0)
+
+	rawKeys := maps.Keys(query)
+	slices.Sort(rawKeys)
+
+	for _, rawKey := range rawKeys {
+		if rawKey == "filter" || slices.Contains(skippedKeys, rawKey) {
+			continue
+		}
+
+		for _, rawValue := range query[rawKey] {
+			where, whereValues, err := getFilterWhere(table, rawKey, rawValue)
+			if err != nil {
+				if errors.Is(err, errUnrecognizedFilter) {
+					return nil, nil, fmt.Errorf("unrecognized param %s=%s", rawKey, rawValue)
+				}
+
+				return nil, nil, fmt.Errorf("failed to parse param %s=%s: %v", rawKey, rawValue, err)
+			}
+
+			wheres = append(wheres, where)
+			values = append(values, whereValues...)
+		}
+	}
+
+	for _, rawFilter := range query["filter"] {
+		filterWhere, filterValues, err := getFilterExpressionWhere(table, rawFilter)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse param filter=%s: %v", rawFilter, err)
+		}
+
+		wheres = append(wheres, filterWhere)
+		values = append(values, filterValues...)
+	}
+
+	return wheres, values, nil
+}
@@ -0,0 +1,519 @@
+package extensions
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/lib/pq"
+	"github.com/lib/pq/hstore"
+)
+
+func TestParseFilterExpression(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rawFilter string
+		expected  *filterExpression
+		expectErr bool
+	}{
+		{
+			name:      "single filter",
+			rawFilter: "type__eq:A",
+			expected:  &filterExpression{key: "type__eq", value: "A"},
+		},
+		{
+			name:      "empty value",
+			rawFilter: "external_id__isnull:",
+			expected:  &filterExpression{key: "external_id__isnull", value: ""},
+		},
+		{
+			name:      "nested groups with spaces",
+			rawFilter: " or( type__eq:A , AND(name__ilike:foo, not(external_id__isnull:)) ) ",
+			expected: &filterExpression{
+				operator: "or",
+				children: []*filterExpression{
+					{key: "type__eq", value: "A"},
+					{
+						operator: "and",
+						children: []*filterExpression{
+							{key: "name__ilike", value: "foo"},
+							{
+								operator: "not",
+								children: []*filterExpression{
+									{key: "external_id__isnull", value: ""},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "quoted value with commas and parentheses",
+			rawFilter: `and(name__in:"a,b (c)",type__eq:"say \"hi\"")`,
+			expected: &filterExpression{
+				operator: "and",
+				children: []*filterExpression{
+					{key: "name__in", value: "a,b (c)"},
+					{key: "type__eq", value: `say "hi"`},
+				},
+			},
+		},
+		{
+			name:      "unknown group",
+			rawFilter: "xor(type__eq:A,type__eq:B)",
+			expectErr: true,
+		},
+		{
+			name:      "not with more than one child",
+			rawFilter: "not(type__eq:A,type__eq:B)",
+			expectErr: true,
+		},
+		{
+			name:      "unclosed group",
+			rawFilter: "and(type__eq:A",
+			expectErr: true,
+		},
+		{
+			name:      "trailing garbage",
+			rawFilter: "and(type__eq:A))",
+			expectErr: true,
+		},
+		{
+			name:      "unterminated quote",
+			rawFilter: `type__eq:"A`,
+			expectErr: true,
+		},
+		{
+			name:      "missing colon",
+			rawFilter: "type__eq",
+			expectErr: true,
+		},
+		{
+			name:      "empty",
+			rawFilter: "",
+			expectErr: true,
+		},
+		{
+			name:      "nested as deep as permitted",
+			rawFilter: strings.Repeat("not(", filterMaxDepth) + "type__eq:A" + strings.Repeat(")", filterMaxDepth),
+			expected: func() *filterExpression {
+				e := &filterExpression{key: "type__eq", value: "A"}
+				for i := 0; i < filterMaxDepth; i++ {
+					e = &filterExpression{operator: "not", children: []*filterExpression{e}}
+				}
+
+				return e
+			}(),
+		},
+		{
+			name:      "nested too deep",
+			rawFilter: strings.Repeat("not(", filterMaxDepth+1) + "type__eq:A" + strings.Repeat(")", filterMaxDepth+1),
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			e, err := parseFilterExpression(testCase.rawFilter)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", e)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(e, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, e)
+			}
+		})
+	}
+}
+
+func TestGetFilterWhere(t *testing.T) {
+	testCases := []struct {
+		name           string
+		table          string
+		rawKey         string
+		rawValue       string
+		expectedWhere  string
+		expectedValues []any
+		expectErr      bool
+	}{
+		{
+			name:           "eq string",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "name__eq",
+			rawValue:       "Thing",
+			expectedWhere:  `"name" = $$??`,
+			expectedValues: []any{"Thing"},
+		},
+		{
+			name:           "gt number",
+			table:          djangolang_example.FuzzTable,
+			rawKey:         "column14__gt",
+			rawValue:       "5",
+			expectedWhere:  `"column14" > $$??`,
+			expectedValues: []any{float64(5)},
+		},
+		{
+			name:           "in comma-separated",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "type__in",
+			rawValue:       "A,B",
+			expectedWhere:  `"type" IN ($$??, $$??)`,
+			expectedValues: []any{"A", "B"},
+		},
+		{
+			name:           "notin JSON array",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "type__notin",
+			rawValue:       `["A","B,C"]`,
+			expectedWhere:  `"type" NOT IN ($$??, $$??)`,
+			expectedValues: []any{"A", "B,C"},
+		},
+		{
+			name:           "isnull ignores value",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "external_id__isnull",
+			rawValue:       "whatever",
+			expectedWhere:  `"external_id" IS NULL`,
+			expectedValues: []any{},
+		},
+		{
+			name:           "ilike wraps value",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "name__ilike",
+			rawValue:       "thing",
+			expectedWhere:  `"name" ILIKE $$??`,
+			expectedValues: []any{"%thing%"},
+		},
+		{
+			name:      "unknown column",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "nope__eq",
+			rawValue:  "A",
+			expectErr: true,
+		},
+		{
+			name:      "unknown operator",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "name__approx",
+			rawValue:  "A",
+			expectErr: true,
+		},
+		{
+			name:      "no operator",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "name",
+			rawValue:  "A",
+			expectErr: true,
+		},
+		{
+			name:      "typed operator on the wrong kind of column",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "name__contains",
+			rawValue:  "A",
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			where, values, err := getFilterWhere(testCase.table, testCase.rawKey, testCase.rawValue)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v, %#+v", where, values)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if where != testCase.expectedWhere {
+				t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+			}
+
+			if !reflect.DeepEqual(values, testCase.expectedValues) {
+				t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+			}
+		})
+	}
+}
+
+func TestGetFilterExpressionWhere(t *testing.T) {
+	testCases := []struct {
+		name           string
+		rawFilter      string
+		expectedWhere  string
+		expectedValues []any
+		expectErr      bool
+	}{
+		{
+			name:           "groups",
+			rawFilter:      "or(type__eq:A,not(name__ilike:b))",
+			expectedWhere:  `("type" = $$?? OR NOT ("name" ILIKE $$??))`,
+			expectedValues: []any{"A", "%b%"},
+		},
+		{
+			name:           "quoted in",
+			rawFilter:      `and(type__in:"A,B",external_id__isnotnull:)`,
+			expectedWhere:  `("type" IN ($$??, $$??) AND "external_id" IS NOT NULL)`,
+			expectedValues: []any{"A", "B"},
+		},
+		{
+			name:      "bad filter in group",
+			rawFilter: "and(type__eq:A,nope__eq:B)",
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			where, values, err := getFilterExpressionWhere(djangolang_example.PhysicalThingTable, testCase.rawFilter)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v, %#+v", where, values)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if where != testCase.expectedWhere {
+				t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+			}
+
+			if !reflect.DeepEqual(values, testCase.expectedValues) {
+				t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+			}
+
+			if strings.Count(where, "$$??") != len(values) {
+				t.Fatalf("expected %d placeholders in %#+v", len(values), where)
+			}
+		})
+	}
+
+}
+
+func TestGetForeignKeyFilterWhere(t *testing.T) {
+	testCases := []struct {
+		name          string
+		table         string
+		rawKey        string
+		expectedWhere string
+		expectErr     bool
+	}{
+		{
+			name:          "one foreign key",
+			table:         djangolang_example.LogicalThingTable,
+			rawKey:        "parent_physical_thing_id__type__eq",
+			expectedWhere: `EXISTS (SELECT 1 FROM "physical_things" AS "__fk_1" WHERE "__fk_1"."id" = "logical_things"."parent_physical_thing_id" AND "__fk_1"."deleted_at" IS null AND "__fk_1"."type" = $$??)`,
+		},
+		{
+			name:          "two foreign keys",
+			table:         djangolang_example.LogicalThingTable,
+			rawKey:        "parent_logical_thing_id__parent_physical_thing_id__name__eq",
+			expectedWhere: `EXISTS (SELECT 1 FROM "logical_things" AS "__fk_1" WHERE "__fk_1"."id" = "logical_things"."parent_logical_thing_id" AND "__fk_1"."deleted_at" IS null AND EXISTS (SELECT 1 FROM "physical_things" AS "__fk_2" WHERE "__fk_2"."id" = "__fk_1"."parent_physical_thing_id" AND "__fk_2"."deleted_at" IS null AND "__fk_2"."name" = $$??))`,
+		},
+		{
+			name:          "as many foreign keys as permitted",
+			table:         djangolang_example.LogicalThingTable,
+			rawKey:        strings.Repeat("parent_logical_thing_id__", filterMaxForeignKeyDepth) + "name__eq",
+			expectedWhere: "",
+		},
+		{
+			name:      "too many foreign keys",
+			table:     djangolang_example.LogicalThingTable,
+			rawKey:    strings.Repeat("parent_logical_thing_id__", filterMaxForeignKeyDepth+1) + "name__eq",
+			expectErr: true,
+		},
+		{
+			name:      "not a foreign key",
+			table:     djangolang_example.LogicalThingTable,
+			rawKey:    "name__type__eq",
+			expectErr: true,
+		},
+		{
+			name:      "unknown column on the foreign table",
+			table:     djangolang_example.LogicalThingTable,
+			rawKey:    "parent_physical_thing_id__nope__eq",
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			where, values, err := getFilterWhere(testCase.table, testCase.rawKey, "A")
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v, %#+v", where, values)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if testCase.expectedWhere != "" && where != testCase.expectedWhere {
+				t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+			}
+
+			if !reflect.DeepEqual(values, []any{"A"}) {
+				t.Fatalf("expected values %#+v but got %#+v", []any{"A"}, values)
+			}
+
+			// the soft-delete exclusion of the Select functions mustn't mistake those of the foreign tables for its own
+			if mentionsDeletedAt(where) {
+				t.Fatalf("expected %#+v not to mention deleted_at of %s", where, testCase.table)
+			}
+		})
+	}
+}
+
+func TestGetTypedFilterWhere(t *testing.T) {
+	testCases := []struct {
+		name           string
+		table          string
+		rawKey         string
+		rawValue       string
+		expectedWhere  string
+		expectedValues []any
+		expectErr      bool
+	}{
+		{
+			name:           "array contains comma-separated",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "tags__contains",
+			rawValue:       "a,b",
+			expectedWhere:  `"tags" @> $$??`,
+			expectedValues: []any{pq.StringArray{"a", "b"}},
+		},
+		{
+			name:           "array overlaps JSON array",
+			table:          djangolang_example.FuzzTable,
+			rawKey:         "column6__overlaps",
+			rawValue:       `[1,2.5]`,
+			expectedWhere:  `"column6" && $$??`,
+			expectedValues: []any{pq.StringArray{"1", "2.5"}},
+		},
+		{
+			name:      "array of objects",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "tags__contains",
+			rawValue:  `[{"a":"b"}]`,
+			expectErr: true,
+		},
+		{
+			name:           "hstore haskey",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "metadata__haskey",
+			rawValue:       "a,b",
+			expectedWhere:  `"metadata" ? $$??`,
+			expectedValues: []any{"a,b"},
+		},
+		{
+			name:           "hstore haskeys",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "metadata__haskeys",
+			rawValue:       "a,b",
+			expectedWhere:  `"metadata" ?& $$??`,
+			expectedValues: []any{pq.StringArray{"a", "b"}},
+		},
+		{
+			name:          "hstore contains",
+			table:         djangolang_example.PhysicalThingTable,
+			rawKey:        "metadata__contains",
+			rawValue:      `{"a":"b","c":null}`,
+			expectedWhere: `"metadata" @> $$??`,
+			expectedValues: []any{hstore.Hstore{Map: map[string]sql.NullString{
+				"a": {String: "b", Valid: true},
+				"c": {},
+			}}},
+		},
+		{
+			name:      "hstore contains non-string",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "metadata__contains",
+			rawValue:  `{"a":1}`,
+			expectErr: true,
+		},
+		{
+			name:           "jsonb contains",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "raw_data__contains",
+			rawValue:       `{"a":[1]}`,
+			expectedWhere:  `"raw_data" @> $$??`,
+			expectedValues: []any{`{"a":[1]}`},
+		},
+		{
+			name:      "jsonb contains invalid JSON",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "raw_data__contains",
+			rawValue:  `{"a":`,
+			expectErr: true,
+		},
+		{
+			name:           "jsonb path",
+			table:          djangolang_example.FuzzTable,
+			rawKey:         "column4__path",
+			rawValue:       `$.battery ? (@ < 20)`,
+			expectedWhere:  `"column4" @? $$??`,
+			expectedValues: []any{`$.battery ? (@ < 20)`},
+		},
+		{
+			name:      "jsonb operator on an array",
+			table:     djangolang_example.PhysicalThingTable,
+			rawKey:    "tags__path",
+			rawValue:  `$.a`,
+			expectErr: true,
+		},
+		{
+			name:           "scalar operators still apply",
+			table:          djangolang_example.PhysicalThingTable,
+			rawKey:         "tags__isnull",
+			expectedWhere:  `"tags" IS NULL`,
+			expectedValues: []any{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			where, values, err := getFilterWhere(testCase.table, testCase.rawKey, testCase.rawValue)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v, %#+v", where, values)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if where != testCase.expectedWhere {
+				t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+			}
+
+			if !reflect.DeepEqual(values, testCase.expectedValues) {
+				t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package extensions
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// distanceOrderByColumn is the pseudo-column that orders by distance from the distance_from param
+const distanceOrderByColumn = "distance"
+
+// formatFloat renders v for formatting straight into SQL, which (as it's just a number) is safe to do, and is done
+// where a value is needed more than once in an expression (e.g. the center and radius of formatWithinMetresSQL)
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseFloats parses exactly n comma-separated numbers
+func parseFloats(rawValue string, n int) ([]float64, error) {
+	parts := strings.Split(rawValue, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated numbers but got %#+v", n, rawValue)
+	}
+
+	vs := make([]float64, 0)
+
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %#+v as a number: %v", part, err)
+		}
+
+		vs = append(vs, v)
+	}
+
+	return vs, nil
+}
+
+// parsePointFilterValue turns x,y into Postgres point syntax
+func parsePointFilterValue(rawValue string) (any, error) {
+	vs, err := parseFloats(rawValue, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return fmt.Sprintf("(%s,%s)", formatFloat(vs[0]), formatFloat(vs[1])), nil
+}
+
+// parseBoxFilterValue turns minx,miny,maxx,maxy into Postgres box syntax
+func parseBoxFilterValue(rawValue string) (any, error) {
+	vs, err := parseFloats(rawValue, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	if vs[0] > vs[2] || vs[1] > vs[3] {
+		return nil, fmt.Errorf("expected minx,miny,maxx,maxy but got %#+v", rawValue)
+	}
+
+	return fmt.Sprintf("(%s,%s),(%s,%s)", formatFloat(vs[0]), formatFloat(vs[1]), formatFloat(vs[2]), formatFloat(vs[3])), nil
+}
+
+// formatNearFilterWhere turns longitude,latitude,radius into a condition that's true if the point column is within
+// radius metres of the given point (as per formatWithinMetresSQL)
+func formatNearFilterWhere(column string, rawValue string) (string, error) {
+	vs, err := parseFloats(rawValue, 3)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range vs {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("expected finite numbers but got %#+v", rawValue)
+		}
+	}
+
+	if vs[2] < 0 {
+		return "", fmt.Errorf("radius must not be negative but got %#+v", rawValue)
+	}
+
+	return fmt.Sprintf("(%s)", formatWithinMetresSQL(column, fmt.Sprintf("point(%s, %s)", formatFloat(vs[0]), formatFloat(vs[1])), vs[2])), nil
+}
+
+// getGeometricColumns returns the point and polygon columns of the given table (sorted)
+func getGeometricColumns(table string) []string {
+	columns := make([]string, 0)
+
+	for column, kind := range columnKindsByTable[table] {
+		if kind != columnKindPoint && kind != columnKindPolygon {
+			continue
+		}
+
+		columns = append(columns, column)
+	}
+
+	slices.Sort(columns)
+
+	return columns
+}
+
+func hasGeometricColumns(table string) bool {
+	return len(getGeometricColumns(table)) > 0
+}
+
+// getDistanceExpression returns an expression (and its values) for the distance of a row from the point given by the
+// distance_from param; for tables with more than one geometric column it's the distance to the nearest of them
+func getDistanceExpression(table string, query url.Values) (string, []any, error) {
+	rawDistanceFrom := query.Get("distance_from")
+	if rawDistanceFrom == "" {
+		return "", nil, fmt.Errorf("%v requires a distance_from param", distanceOrderByColumn)
+	}
+
+	distanceFrom, err := parsePointFilterValue(rawDistanceFrom)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse param distance_from=%s: %v", rawDistanceFrom, err)
+	}
+
+	distances := make([]string, 0)
+	values := make([]any, 0)
+
+	for _, column := range getGeometricColumns(table) {
+		distances = append(distances, fmt.Sprintf("$$??::point <-> %s", formatColumn("", column)))
+		values = append(values, distanceFrom)
+	}
+
+	if len(distances) == 0 {
+		return "", nil, fmt.Errorf("%v doesn't support %v", table, distanceOrderByColumn)
+	}
+
+	if len(distances) == 1 {
+		return distances[0], values, nil
+	}
+
+	// note: LEAST ignores nulls, so this is the distance to whichever columns are set
+	return fmt.Sprintf("LEAST(%s)", strings.Join(distances, ", ")), values, nil
+}
+
+// earthRadius is the mean radius of the Earth in metres, for distances between points that are longitude / latitude
+const earthRadius = 6371008.8
+
+// getHaversineDistance returns the great-circle distance in metres between the points a and b (each of which is
+// longitude / latitude)
+func getHaversineDistance(a pgtype.Vec2, b pgtype.Vec2) float64 {
+	latitudeA, latitudeB := a.Y*math.Pi/180, b.Y*math.Pi/180
+
+	h := math.Pow(math.Sin((latitudeB-latitudeA)/2), 2) +
+		math.Cos(latitudeA)*math.Cos(latitudeB)*math.Pow(math.Sin((b.X-a.X)*math.Pi/180/2), 2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// formatHaversineDistanceSQL returns an expression for the great-circle distance in metres between the points given by
+// the expressions a and b (each of which is longitude / latitude)
+func formatHaversineDistanceSQL(a string, b string) string {
+	return fmt.Sprintf(
+		"(2 * %s * asin(least(1, sqrt(power(sin(radians((%s)[1] - (%s)[1]) / 2), 2) + cos(radians((%s)[1])) * cos(radians((%s)[1])) * power(sin(radians((%s)[0] - (%s)[0]) / 2), 2)))))",
+		formatFloat(earthRadius),
+		b, a,
+		a, b,
+		b, a,
+	)
+}
+
+// formatMetresBoundingBoxSQL returns an expression for a box (in degrees) that contains every point within radius
+// metres of the point given by the expression center, so that the point index can be used before the (exact) haversine
+// distance is checked; the box is as wide as the whole world if the circle reaches a pole or crosses the antimeridian
+func formatMetresBoundingBoxSQL(center string, radius float64) string {
+	latitudeDelta := formatFloat(radius / earthRadius * 180 / math.Pi)
+
+	longitudeDelta := fmt.Sprintf("degrees(asin(sin(radians(%s)) / cos(radians((%s)[1]))))", latitudeDelta, center)
+
+	// note: CASE is evaluated in order, so asin only sees values it can take
+	longitudeDelta = fmt.Sprintf(
+		"CASE WHEN abs((%s)[1]) + %s >= 90 THEN 360 WHEN abs((%s)[0]) + %s > 180 THEN 360 ELSE %s END",
+		center, latitudeDelta,
+		center, longitudeDelta,
+		longitudeDelta,
+	)
+
+	return fmt.Sprintf(
+		"box(point((%s)[0] - (%s), (%s)[1] - %s), point((%s)[0] + (%s), (%s)[1] + %s))",
+		center, longitudeDelta, center, latitudeDelta,
+		center, longitudeDelta, center, latitudeDelta,
+	)
+}
+
+// formatWithinMetresSQL returns a condition that's true if the point column is within radius metres of the point given
+// by the expression center
+func formatWithinMetresSQL(column string, center string, radius float64) string {
+	return fmt.Sprintf(
+		"%s <@ %s AND %s <= %s",
+		column,
+		formatMetresBoundingBoxSQL(center, radius),
+		formatHaversineDistanceSQL(center, column),
+		formatFloat(radius),
+	)
+}
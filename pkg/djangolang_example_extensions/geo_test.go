@@ -0,0 +1,190 @@
+package extensions
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+func TestGetGeometricFilterWhere(t *testing.T) {
+	testCases := []struct {
+		name           string
+		table          string
+		rawKey         string
+		rawValue       string
+		expectedWhere  string
+		expectedValues []any
+		expectErr      bool
+	}{
+		{
+			name:           "point within_bbox",
+			table:          djangolang_example.LocationHistoryTable,
+			rawKey:         "point__within_bbox",
+			rawValue:       "138, -35.5, 139,-34",
+			expectedWhere:  `"point" <@ $$??::box`,
+			expectedValues: []any{"(138,-35.5),(139,-34)"},
+		},
+		{
+			name:      "point within_bbox with min over max",
+			table:     djangolang_example.LocationHistoryTable,
+			rawKey:    "point__within_bbox",
+			rawValue:  "139,-35,138,-34",
+			expectErr: true,
+		},
+		{
+			name:      "point within_bbox with too few numbers",
+			table:     djangolang_example.LocationHistoryTable,
+			rawKey:    "point__within_bbox",
+			rawValue:  "138,-35,139",
+			expectErr: true,
+		},
+		{
+			name:           "point near",
+			table:          djangolang_example.LocationHistoryTable,
+			rawKey:         "point__near",
+			rawValue:       "138.6,-34.9,500",
+			expectedWhere:  "(" + formatWithinMetresSQL(`"point"`, "point(138.6, -34.9)", 500) + ")",
+			expectedValues: []any{},
+		},
+		{
+			name:      "point near with a negative radius",
+			table:     djangolang_example.LocationHistoryTable,
+			rawKey:    "point__near",
+			rawValue:  "138.6,-34.9,-1",
+			expectErr: true,
+		},
+		{
+			name:      "point near with a radius that isn't finite",
+			table:     djangolang_example.LocationHistoryTable,
+			rawKey:    "point__near",
+			rawValue:  "138.6,-34.9,Inf",
+			expectErr: true,
+		},
+		{
+			name:      "point near with a point that isn't a number",
+			table:     djangolang_example.LocationHistoryTable,
+			rawKey:    "point__near",
+			rawValue:  "NaN,-34.9,500",
+			expectErr: true,
+		},
+		{
+			name:           "polygon contains_point",
+			table:          djangolang_example.LocationHistoryTable,
+			rawKey:         "polygon__contains_point",
+			rawValue:       "1,2",
+			expectedWhere:  `"polygon" @> $$??::point`,
+			expectedValues: []any{"(1,2)"},
+		},
+		{
+			name:           "polygon intersects_bbox",
+			table:          djangolang_example.FuzzTable,
+			rawKey:         "column29__intersects_bbox",
+			rawValue:       "0,0,1,1",
+			expectedWhere:  `"column29" && polygon($$??::box)`,
+			expectedValues: []any{"(0,0),(1,1)"},
+		},
+		{
+			name:      "point operator on a polygon",
+			table:     djangolang_example.LocationHistoryTable,
+			rawKey:    "polygon__near",
+			rawValue:  "1,2,3",
+			expectErr: true,
+		},
+		{
+			name:      "not a number",
+			table:     djangolang_example.LocationHistoryTable,
+			rawKey:    "polygon__contains_point",
+			rawValue:  "east,2",
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			where, values, err := getFilterWhere(testCase.table, testCase.rawKey, testCase.rawValue)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v, %#+v", where, values)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if where != testCase.expectedWhere {
+				t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+			}
+
+			if !reflect.DeepEqual(values, testCase.expectedValues) {
+				t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+			}
+		})
+	}
+}
+
+func TestGetDistanceExpression(t *testing.T) {
+	testCases := []struct {
+		name               string
+		table              string
+		query              url.Values
+		expectedExpression string
+		expectedValues     []any
+		expectErr          bool
+	}{
+		{
+			name:               "nearest of point and polygon",
+			table:              djangolang_example.LocationHistoryTable,
+			query:              url.Values{"distance_from": {"138.6,-34.9"}},
+			expectedExpression: `LEAST($$??::point <-> "point", $$??::point <-> "polygon")`,
+			expectedValues:     []any{"(138.6,-34.9)", "(138.6,-34.9)"},
+		},
+		{
+			name:      "no distance_from",
+			table:     djangolang_example.LocationHistoryTable,
+			query:     url.Values{},
+			expectErr: true,
+		},
+		{
+			name:      "bad distance_from",
+			table:     djangolang_example.LocationHistoryTable,
+			query:     url.Values{"distance_from": {"138.6"}},
+			expectErr: true,
+		},
+		{
+			name:      "table without geometric columns",
+			table:     djangolang_example.PhysicalThingTable,
+			query:     url.Values{"distance_from": {"138.6,-34.9"}},
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			expression, values, err := getDistanceExpression(testCase.table, testCase.query)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v, %#+v", expression, values)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if expression != testCase.expectedExpression {
+				t.Fatalf("expected expression %#+v but got %#+v", testCase.expectedExpression, expression)
+			}
+
+			if !reflect.DeepEqual(values, testCase.expectedValues) {
+				t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+			}
+		})
+	}
+}
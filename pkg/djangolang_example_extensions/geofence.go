@@ -0,0 +1,159 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	GeofenceEventTypeEnter = "enter"
+	GeofenceEventTypeExit  = "exit"
+)
+
+// latestGeofenceEventWhere narrows geofence_events down to the most recent (by timestamp) row for each geofence for the
+// physical thing given as the only value
+var latestGeofenceEventWhere = fmt.Sprintf(
+	`%s IN (
+    SELECT DISTINCT ON (%s) %s
+    FROM %s AS "__latest"
+    WHERE %s IS null
+        AND %s = $$??
+    ORDER BY %s, %s DESC, %s DESC
+)`,
+	formatColumn("", djangolang_example.GeofenceEventTableIDColumn),
+	formatColumn("__latest", djangolang_example.GeofenceEventTableGeofenceIDColumn),
+	formatColumn("__latest", djangolang_example.GeofenceEventTableIDColumn),
+	query.FormatObjectName(djangolang_example.GeofenceEventTable),
+	formatColumn("__latest", djangolang_example.GeofenceEventTableDeletedAtColumn),
+	formatColumn("__latest", djangolang_example.GeofenceEventTablePhysicalThingIDColumn),
+	formatColumn("__latest", djangolang_example.GeofenceEventTableGeofenceIDColumn),
+	formatColumn("__latest", djangolang_example.GeofenceEventTableTimestampColumn),
+	formatColumn("__latest", djangolang_example.GeofenceEventTableIDColumn),
+)
+
+// EvaluateGeofences compares the given location history point against the geofences and inserts (and returns) an enter /
+// exit event for each geofence that its physical thing has crossed since the last event for that geofence; points
+// without a Point or a physical thing never cross anything
+func EvaluateGeofences(ctx context.Context, tx *sqlx.Tx, object *djangolang_example.LocationHistory) ([]*djangolang_example.GeofenceEvent, error) {
+	events := make([]*djangolang_example.GeofenceEvent, 0)
+
+	if object.Point == nil || object.ParentPhysicalThingID == nil {
+		return events, nil
+	}
+
+	physicalThingID := *object.ParentPhysicalThingID
+
+	// note: serialises evaluation per physical thing, so that concurrent inserts for it can't both record the same
+	// crossing
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1));", physicalThingID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock geofence evaluation for %v; err: %v", physicalThingID, err)
+	}
+
+	ctx = WithExpansionDepth(ctx, 0)
+
+	containingGeofences, err := SelectGeofencesWithOptions(
+		ctx,
+		tx,
+		fmt.Sprintf("%s @> $$??::point", formatColumn("", djangolang_example.GeofenceTablePolygonColumn)),
+		SelectOptions{},
+		fmt.Sprintf("(%s,%s)", formatFloat(object.Point.X), formatFloat(object.Point.Y)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	latestEvents, err := SelectGeofenceEventsWithOptions(ctx, tx, latestGeofenceEventWhere, SelectOptions{}, physicalThingID)
+	if err != nil {
+		return nil, err
+	}
+
+	containingGeofenceIDs := make([]uuid.UUID, 0)
+	for _, geofence := range containingGeofences {
+		containingGeofenceIDs = append(containingGeofenceIDs, geofence.ID)
+	}
+
+	events = getGeofenceEvents(object, containingGeofenceIDs, latestEvents)
+
+	for _, event := range events {
+		err = event.Insert(ctx, tx, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert %v event for geofence %v; err: %v", event.Type, event.GeofenceID, err)
+		}
+	}
+
+	return events, nil
+}
+
+// getGeofenceEvents returns an enter event for each of the given geofences that the point is in but that the physical
+// thing wasn't last known to be in, and an exit event for each geofence that it was last known to be in but the point
+// isn't in (the latest events being the most recent event for each geofence for the physical thing)
+func getGeofenceEvents(object *djangolang_example.LocationHistory, containingGeofenceIDs []uuid.UUID, latestEvents []*djangolang_example.GeofenceEvent) []*djangolang_example.GeofenceEvent {
+	events := make([]*djangolang_example.GeofenceEvent, 0)
+
+	latestEventByGeofenceID := make(map[uuid.UUID]*djangolang_example.GeofenceEvent)
+	for _, latestEvent := range latestEvents {
+		latestEventByGeofenceID[latestEvent.GeofenceID] = latestEvent
+	}
+
+	isContaining := make(map[uuid.UUID]struct{})
+
+	newEvent := func(geofenceID uuid.UUID, eventType string) {
+		events = append(events, &djangolang_example.GeofenceEvent{
+			Timestamp:         object.Timestamp,
+			Type:              eventType,
+			GeofenceID:        geofenceID,
+			PhysicalThingID:   *object.ParentPhysicalThingID,
+			LocationHistoryID: &object.ID,
+		})
+	}
+
+	for _, geofenceID := range containingGeofenceIDs {
+		isContaining[geofenceID] = struct{}{}
+
+		// note: points that arrive out of order (older than the latest event for a geofence) are ignored for that
+		// geofence rather than rewriting history
+		latestEvent := latestEventByGeofenceID[geofenceID]
+		if latestEvent != nil && (latestEvent.Type == GeofenceEventTypeEnter || latestEvent.Timestamp.After(object.Timestamp)) {
+			continue
+		}
+
+		newEvent(geofenceID, GeofenceEventTypeEnter)
+	}
+
+	for _, latestEvent := range latestEvents {
+		_, ok := isContaining[latestEvent.GeofenceID]
+		if ok || latestEvent.Type != GeofenceEventTypeEnter || latestEvent.Timestamp.After(object.Timestamp) {
+			continue
+		}
+
+		newEvent(latestEvent.GeofenceID, GeofenceEventTypeExit)
+	}
+
+	return events
+}
+
+// evaluateGeofencesForInserts is EvaluateGeofences for each of the given (just inserted) location history points, in time
+// order; it's only called by InsertLocationHistorys, in the same transaction as the inserts, so that no point escapes
+// evaluation (as could happen if it were driven by the change stream, which drops changes for slow consumers)
+func evaluateGeofencesForInserts(ctx context.Context, tx *sqlx.Tx, objects []*djangolang_example.LocationHistory) error {
+	sortedObjects := slices.Clone(objects)
+	slices.SortStableFunc(sortedObjects, func(a *djangolang_example.LocationHistory, b *djangolang_example.LocationHistory) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	for _, object := range sortedObjects {
+		_, err := EvaluateGeofences(ctx, tx, object)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate geofences for %v; err: %v", object.ID, err)
+		}
+	}
+
+	return nil
+}
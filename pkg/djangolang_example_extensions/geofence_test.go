@@ -0,0 +1,176 @@
+package extensions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+type geofenceTestEvent struct {
+	geofenceID uuid.UUID
+	eventType  string
+}
+
+func getGeofenceTestEvents(events []*djangolang_example.GeofenceEvent) []geofenceTestEvent {
+	geofenceTestEvents := make([]geofenceTestEvent, 0)
+	for _, event := range events {
+		geofenceTestEvents = append(geofenceTestEvents, geofenceTestEvent{geofenceID: event.GeofenceID, eventType: event.Type})
+	}
+
+	return geofenceTestEvents
+}
+
+func TestGetGeofenceEvents(t *testing.T) {
+	a := uuid.New()
+	b := uuid.New()
+
+	at := func(minute int) time.Time {
+		return time.Date(2024, 1, 2, 3, minute, 0, 0, time.UTC)
+	}
+
+	latest := func(geofenceID uuid.UUID, eventType string, minute int) *djangolang_example.GeofenceEvent {
+		return &djangolang_example.GeofenceEvent{GeofenceID: geofenceID, Type: eventType, Timestamp: at(minute)}
+	}
+
+	testCases := []struct {
+		name                  string
+		containingGeofenceIDs []uuid.UUID
+		latestEvents          []*djangolang_example.GeofenceEvent
+		expected              []geofenceTestEvent
+	}{
+		{
+			name:     "outside with no history",
+			expected: []geofenceTestEvent{},
+		},
+		{
+			name:                  "first point inside",
+			containingGeofenceIDs: []uuid.UUID{a},
+			expected:              []geofenceTestEvent{{a, GeofenceEventTypeEnter}},
+		},
+		{
+			name:                  "still inside",
+			containingGeofenceIDs: []uuid.UUID{a},
+			latestEvents:          []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeEnter, 1)},
+			expected:              []geofenceTestEvent{},
+		},
+		{
+			name:         "leaves",
+			latestEvents: []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeEnter, 1)},
+			expected:     []geofenceTestEvent{{a, GeofenceEventTypeExit}},
+		},
+		{
+			name:         "still outside",
+			latestEvents: []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeExit, 1)},
+			expected:     []geofenceTestEvent{},
+		},
+		{
+			name:                  "re-enters",
+			containingGeofenceIDs: []uuid.UUID{a},
+			latestEvents:          []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeExit, 1)},
+			expected:              []geofenceTestEvent{{a, GeofenceEventTypeEnter}},
+		},
+		{
+			name:                  "crosses from one into another",
+			containingGeofenceIDs: []uuid.UUID{b},
+			latestEvents:          []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeEnter, 1)},
+			expected:              []geofenceTestEvent{{b, GeofenceEventTypeEnter}, {a, GeofenceEventTypeExit}},
+		},
+		{
+			name:                  "enters an overlapping one",
+			containingGeofenceIDs: []uuid.UUID{a, b},
+			latestEvents:          []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeEnter, 1)},
+			expected:              []geofenceTestEvent{{b, GeofenceEventTypeEnter}},
+		},
+		{
+			name:                  "out of order point inside is ignored",
+			containingGeofenceIDs: []uuid.UUID{a},
+			latestEvents:          []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeExit, 9)},
+			expected:              []geofenceTestEvent{},
+		},
+		{
+			name:         "out of order point outside is ignored",
+			latestEvents: []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeEnter, 9)},
+			expected:     []geofenceTestEvent{},
+		},
+		{
+			name:                  "point at the same time as the latest event",
+			containingGeofenceIDs: []uuid.UUID{a},
+			latestEvents:          []*djangolang_example.GeofenceEvent{latest(a, GeofenceEventTypeExit, 5)},
+			expected:              []geofenceTestEvent{{a, GeofenceEventTypeEnter}},
+		},
+	}
+
+	physicalThingID := uuid.New()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			object := &djangolang_example.LocationHistory{ID: uuid.New(), Timestamp: at(5), ParentPhysicalThingID: &physicalThingID}
+
+			events := getGeofenceEvents(object, testCase.containingGeofenceIDs, testCase.latestEvents)
+
+			for _, event := range events {
+				if event.PhysicalThingID != physicalThingID || event.LocationHistoryID == nil || *event.LocationHistoryID != object.ID || !event.Timestamp.Equal(object.Timestamp) {
+					t.Fatalf("expected event for %v at %v but got %#+v", object.ID, object.Timestamp, event)
+				}
+			}
+
+			geofenceTestEvents := getGeofenceTestEvents(events)
+			if !reflect.DeepEqual(geofenceTestEvents, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, geofenceTestEvents)
+			}
+		})
+	}
+}
+
+// TestGetGeofenceEventsTrack feeds a track that goes in and out of a geofence through, keeping the latest events as
+// EvaluateGeofences would find them
+func TestGetGeofenceEventsTrack(t *testing.T) {
+	a := uuid.New()
+	physicalThingID := uuid.New()
+
+	track := []struct {
+		inside   bool
+		expected []geofenceTestEvent
+	}{
+		{inside: false, expected: []geofenceTestEvent{}},
+		{inside: true, expected: []geofenceTestEvent{{a, GeofenceEventTypeEnter}}},
+		{inside: true, expected: []geofenceTestEvent{}},
+		{inside: false, expected: []geofenceTestEvent{{a, GeofenceEventTypeExit}}},
+		{inside: false, expected: []geofenceTestEvent{}},
+		{inside: true, expected: []geofenceTestEvent{{a, GeofenceEventTypeEnter}}},
+	}
+
+	latestEventByGeofenceID := make(map[uuid.UUID]*djangolang_example.GeofenceEvent)
+
+	for i, point := range track {
+		object := &djangolang_example.LocationHistory{
+			ID:                    uuid.New(),
+			Timestamp:             time.Date(2024, 1, 2, 3, i, 0, 0, time.UTC),
+			ParentPhysicalThingID: &physicalThingID,
+		}
+
+		containingGeofenceIDs := make([]uuid.UUID, 0)
+		if point.inside {
+			containingGeofenceIDs = append(containingGeofenceIDs, a)
+		}
+
+		latestEvents := make([]*djangolang_example.GeofenceEvent, 0)
+		for _, latestEvent := range latestEventByGeofenceID {
+			latestEvents = append(latestEvents, latestEvent)
+		}
+
+		events := getGeofenceEvents(object, containingGeofenceIDs, latestEvents)
+
+		geofenceTestEvents := getGeofenceTestEvents(events)
+		if !reflect.DeepEqual(geofenceTestEvents, point.expected) {
+			t.Fatalf("point %d: expected %#+v but got %#+v", i, point.expected, geofenceTestEvents)
+		}
+
+		for _, event := range events {
+			latestEventByGeofenceID[event.GeofenceID] = event
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package extensions
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+func getGeofenceRouter(db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, fallback http.HandlerFunc) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.GeofenceTable, SelectGeofencesWithOptions, nil, nil)
+	})
+
+	r.Get("/_aggregate", func(w http.ResponseWriter, r *http.Request) {
+		handleGetAggregates(w, r, db, redisConn, modelMiddlewares, djangolang_example.GeofenceTable)
+	})
+
+	r.Get("/_series", func(w http.ResponseWriter, r *http.Request) {
+		handleGetSeries(w, r, db, redisConn, modelMiddlewares, djangolang_example.GeofenceTable)
+	})
+
+	r.Get("/_distinct/{column}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetDistinctValues(w, r, db, redisConn, modelMiddlewares, djangolang_example.GeofenceTable, chi.URLParam(r, "column"))
+	})
+
+	r.Get("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		if !handleGetItem(w, r, db, redisConn, djangolang_example.GeofenceTable, SelectGeofencesWithOptions, chi.URLParam(r, "primaryKey")) {
+			fallback(w, r)
+		}
+	})
+
+	r.Get("/{primaryKey}/events", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.GeofenceEventTable, SelectGeofenceEventsWithOptions, []string{fmt.Sprintf("%s = $$??", djangolang_example.GeofenceEventTableGeofenceIDColumn)}, []any{chi.URLParam(r, "primaryKey")})
+	})
+
+	return r
+}
@@ -0,0 +1,272 @@
+package extensions
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+const contentTypeApplicationGeoJSON = "application/geo+json"
+
+// geoJSONTables are the tables whose list endpoints can respond with (and whose create endpoints can accept) a GeoJSON
+// FeatureCollection
+var geoJSONTables = []string{
+	djangolang_example.LocationHistoryTable,
+}
+
+func hasMediaType(headers []string, mediaType string) bool {
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			parsedMediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			if parsedMediaType == mediaType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// acceptsGeoJSON is true if the request asks for GeoJSON (rather than the usual JSON) via the Accept header
+func acceptsGeoJSON(r *http.Request) bool {
+	return hasMediaType(r.Header.Values("Accept"), contentTypeApplicationGeoJSON)
+}
+
+// isGeoJSON is true if the body of the request is GeoJSON as per the Content-Type header
+func isGeoJSON(r *http.Request) bool {
+	return hasMediaType(r.Header.Values("Content-Type"), contentTypeApplicationGeoJSON)
+}
+
+func getRing(vs []pgtype.Vec2) orb.Ring {
+	ring := make(orb.Ring, 0)
+	for _, v := range vs {
+		ring = append(ring, orb.Point{v.X, v.Y})
+	}
+
+	// note: Postgres polygons are implicitly closed, GeoJSON rings have to be explicitly closed
+	if len(ring) > 0 && !ring.Closed() {
+		ring = append(ring, ring[0])
+	}
+
+	return ring
+}
+
+func getGeometry(kind columnKind, rawValue json.RawMessage) (orb.Geometry, error) {
+	switch kind {
+	case columnKindPoint:
+		var v *pgtype.Vec2
+		err := json.Unmarshal(rawValue, &v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %#+v as point: %v", string(rawValue), err)
+		}
+
+		if v == nil {
+			return nil, nil
+		}
+
+		return orb.Point{v.X, v.Y}, nil
+	case columnKindPolygon:
+		var vs *[]pgtype.Vec2
+		err := json.Unmarshal(rawValue, &vs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %#+v as polygon: %v", string(rawValue), err)
+		}
+
+		if vs == nil {
+			return nil, nil
+		}
+
+		return orb.Polygon{getRing(*vs)}, nil
+	}
+
+	return nil, fmt.Errorf("%v columns aren't geometric", kind)
+}
+
+// getFeatureCollection turns objects (or the result of projectFields) for the given table into a GeoJSON
+// FeatureCollection, with the geometric columns as the geometry of each feature and the other fields as its properties
+func getFeatureCollection(table string, objects any) (*geojson.FeatureCollection, error) {
+	b, err := json.Marshal(objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %#+v to JSON: %v", objects, err)
+	}
+
+	var items []map[string]json.RawMessage
+	err = json.Unmarshal(b, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %#+v from JSON: %v", string(b), err)
+	}
+
+	fc := geojson.NewFeatureCollection()
+
+	for _, item := range items {
+		geometries := make(orb.Collection, 0)
+
+		for _, column := range getGeometricColumns(table) {
+			rawValue, ok := item[column]
+			if !ok {
+				continue
+			}
+
+			delete(item, column)
+
+			geometry, err := getGeometry(columnKindsByTable[table][column], rawValue)
+			if err != nil {
+				return nil, err
+			}
+
+			if geometry == nil {
+				continue
+			}
+
+			geometries = append(geometries, geometry)
+		}
+
+		var geometry orb.Geometry
+		switch len(geometries) {
+		case 0:
+			return nil, fmt.Errorf("failed to find any geometry for %v row %v", table, string(item["id"]))
+		case 1:
+			geometry = geometries[0]
+		default:
+			geometry = geometries
+		}
+
+		feature := geojson.NewFeature(geometry)
+
+		id, ok := item["id"]
+		if ok {
+			feature.ID = id
+		}
+
+		for k, v := range item {
+			feature.Properties[k] = v
+		}
+
+		fc.Append(feature)
+	}
+
+	return fc, nil
+}
+
+// getItemsFromFeatureCollection turns a GeoJSON FeatureCollection into items (as per FromItem) for the given table, with
+// the geometry of each feature going to the geometric column of the matching kind and the properties going to the
+// other columns
+func getItemsFromFeatureCollection(table string, b []byte) ([]map[string]any, error) {
+	fc, err := geojson.UnmarshalFeatureCollection(b)
+	if err != nil {
+		return nil, err
+	}
+
+	columnByKind := make(map[columnKind]string)
+	for _, column := range getGeometricColumns(table) {
+		_, ok := columnByKind[columnKindsByTable[table][column]]
+		if ok {
+			continue
+		}
+
+		columnByKind[columnKindsByTable[table][column]] = column
+	}
+
+	items := make([]map[string]any, 0)
+
+	for i, feature := range fc.Features {
+		item := make(map[string]any)
+
+		for k, v := range feature.Properties {
+			// note: the _object fields are read-only expansions of foreign keys, dropped so that output can be fed back in
+			if strings.HasSuffix(k, "_object") {
+				continue
+			}
+
+			item[k] = v
+		}
+
+		_, ok := item["id"]
+		if !ok && feature.ID != nil {
+			item["id"] = feature.ID
+		}
+
+		switch geometry := feature.Geometry.(type) {
+		case orb.Point:
+			column, ok := columnByKind[columnKindPoint]
+			if !ok {
+				return nil, fmt.Errorf("feature %d has a Point geometry but %v has no point column", i, table)
+			}
+
+			item[column] = pgtype.Point{P: pgtype.Vec2{X: geometry.X(), Y: geometry.Y()}, Valid: true}
+		case orb.Polygon:
+			column, ok := columnByKind[columnKindPolygon]
+			if !ok {
+				return nil, fmt.Errorf("feature %d has a Polygon geometry but %v has no polygon column", i, table)
+			}
+
+			if len(geometry) != 1 {
+				return nil, fmt.Errorf("feature %d has a Polygon geometry with holes, which Postgres polygons don't support", i)
+			}
+
+			ring := geometry[0]
+			if len(ring) > 1 && ring.Closed() {
+				ring = ring[:len(ring)-1]
+			}
+
+			vs := make([]pgtype.Vec2, 0)
+			for _, p := range ring {
+				vs = append(vs, pgtype.Vec2{X: p.X(), Y: p.Y()})
+			}
+
+			item[column] = pgtype.Polygon{P: vs, Valid: true}
+		default:
+			return nil, fmt.Errorf("feature %d has a %T geometry but only Point and Polygon are supported", i, feature.Geometry)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// handleFeatureCollectionResponse is handleListResponse for GeoJSON; the pagination info goes in foreign members
+func handleFeatureCollectionResponse(w http.ResponseWriter, status int, fc *geojson.FeatureCollection, nextCursor *string, prevCursor *string, total *int64) []byte {
+	if fc.ExtraMembers == nil {
+		fc.ExtraMembers = make(geojson.Properties)
+	}
+
+	if nextCursor != nil {
+		fc.ExtraMembers["next_cursor"] = *nextCursor
+	}
+
+	if prevCursor != nil {
+		fc.ExtraMembers["prev_cursor"] = *prevCursor
+	}
+
+	if total != nil {
+		fc.ExtraMembers["total"] = *total
+	}
+
+	b, err := json.Marshal(fc)
+	if err != nil {
+		status, _, b, _ = helpers.GetResponse(
+			http.StatusInternalServerError,
+			fmt.Errorf("failed to marshal %#+v to GeoJSON: %v", fc, err),
+			nil,
+		)
+	} else {
+		w.Header().Set("Content-Type", contentTypeApplicationGeoJSON)
+	}
+
+	helpers.WriteResponse(w, status, b)
+
+	return b
+}
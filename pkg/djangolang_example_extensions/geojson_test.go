@@ -0,0 +1,145 @@
+package extensions
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+)
+
+func TestAcceptsGeoJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		accept   []string
+		expected bool
+	}{
+		{name: "none", expected: false},
+		{name: "JSON", accept: []string{"application/json"}, expected: false},
+		{name: "GeoJSON", accept: []string{"application/geo+json"}, expected: true},
+		{name: "GeoJSON among others", accept: []string{"application/json;q=0.9, application/geo+json;q=1"}, expected: true},
+		{name: "GeoJSON in a second header", accept: []string{"text/html", "application/geo+json"}, expected: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/location-histories", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, accept := range testCase.accept {
+				r.Header.Add("Accept", accept)
+			}
+
+			if acceptsGeoJSON(r) != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, !testCase.expected)
+			}
+		})
+	}
+}
+
+func TestGetFeatureCollection(t *testing.T) {
+	objects := []map[string]any{
+		{"id": "a", "point": pgtype.Vec2{X: 1, Y: 2}, "polygon": nil},
+		{"id": "b", "point": nil, "polygon": []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+		{"id": "c", "point": pgtype.Vec2{X: 1, Y: 2}, "polygon": []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+	}
+
+	fc, err := getFeatureCollection(djangolang_example.LocationHistoryTable, objects)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ring := orb.Ring{{0, 0}, {1, 0}, {1, 1}, {0, 0}}
+
+	expectedGeometries := []orb.Geometry{
+		orb.Point{1, 2},
+		orb.Polygon{ring},
+		orb.Collection{orb.Point{1, 2}, orb.Polygon{ring}},
+	}
+
+	if len(fc.Features) != len(expectedGeometries) {
+		t.Fatalf("expected %d features but got %d", len(expectedGeometries), len(fc.Features))
+	}
+
+	for i, feature := range fc.Features {
+		if !reflect.DeepEqual(feature.Geometry, expectedGeometries[i]) {
+			t.Fatalf("feature %d: expected geometry %#+v but got %#+v", i, expectedGeometries[i], feature.Geometry)
+		}
+
+		_, ok := feature.Properties["point"]
+		if ok {
+			t.Fatalf("feature %d: expected the geometric columns not to be properties", i)
+		}
+
+		if !reflect.DeepEqual(feature.ID, json.RawMessage(`"`+objects[i]["id"].(string)+`"`)) {
+			t.Fatalf("feature %d: expected ID %v but got %#+v", i, objects[i]["id"], feature.ID)
+		}
+	}
+
+	_, err = getFeatureCollection(djangolang_example.LocationHistoryTable, []map[string]any{{"id": "d", "point": nil, "polygon": nil}})
+	if err == nil {
+		t.Fatalf("expected an error for a row without geometry")
+	}
+}
+
+func TestGetItemsFromFeatureCollection(t *testing.T) {
+	testCases := []struct {
+		name      string
+		b         string
+		expected  []map[string]any
+		expectErr bool
+	}{
+		{
+			name: "point and closed polygon",
+			b: `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "id": "a", "geometry": {"type": "Point", "coordinates": [1, 2]}, "properties": {"parent_physical_thing_id_object": {}}},
+				{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}, "properties": {"id": "b"}}
+			]}`,
+			expected: []map[string]any{
+				{"id": "a", "point": pgtype.Point{P: pgtype.Vec2{X: 1, Y: 2}, Valid: true}},
+				{"id": "b", "polygon": pgtype.Polygon{P: []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}, Valid: true}},
+			},
+		},
+		{
+			name:      "polygon with a hole",
+			b:         `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [4, 0], [4, 4], [0, 0]], [[1, 1], [2, 1], [2, 2], [1, 1]]]}, "properties": {}}]}`,
+			expectErr: true,
+		},
+		{
+			name:      "unsupported geometry",
+			b:         `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}, "properties": {}}]}`,
+			expectErr: true,
+		},
+		{
+			name:      "not GeoJSON",
+			b:         `[]`,
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			items, err := getItemsFromFeatureCollection(djangolang_example.LocationHistoryTable, []byte(testCase.b))
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", items)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(items, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, items)
+			}
+		})
+	}
+}
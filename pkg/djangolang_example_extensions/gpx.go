@@ -0,0 +1,674 @@
+package extensions
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	LocationHistoryFileFormatGPX = "gpx"
+	LocationHistoryFileFormatKML = "kml"
+)
+
+const (
+	contentTypeApplicationGPX = "application/gpx+xml"
+	contentTypeApplicationKML = "application/vnd.google-earth.kml+xml"
+)
+
+// note: GPX and KML coordinates are longitude / latitude, which are taken to be the x / y of the point (as for tiles)
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+}
+
+type gpxSegment struct {
+	Points []*gpxPoint `xml:"trkpt"`
+}
+
+type gpxTrack struct {
+	Name     string        `xml:"name,omitempty"`
+	Segments []*gpxSegment `xml:"trkseg"`
+}
+
+type gpxRoute struct {
+	Points []*gpxPoint `xml:"rtept"`
+}
+
+type gpxFile struct {
+	XMLName   xml.Name    `xml:"gpx"`
+	Xmlns     string      `xml:"xmlns,attr,omitempty"`
+	Version   string      `xml:"version,attr,omitempty"`
+	Creator   string      `xml:"creator,attr,omitempty"`
+	Waypoints []*gpxPoint `xml:"wpt"`
+	Routes    []*gpxRoute `xml:"rte"`
+	Tracks    []*gpxTrack `xml:"trk"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	Coordinates string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+}
+
+// kmlTrack is a gx:Track, i.e. a run of timestamped coordinates in a single placemark (only ever read)
+type kmlTrack struct {
+	Whens  []string `xml:"when"`
+	Coords []string `xml:"coord"`
+}
+
+type kmlPlacemark struct {
+	Name      string        `xml:"name,omitempty"`
+	TimeStamp *kmlTimeStamp `xml:"TimeStamp"`
+	TimeSpan  *kmlTimeSpan  `xml:"TimeSpan"`
+	Point     *kmlPoint     `xml:"Point"`
+	Polygon   *kmlPolygon   `xml:"Polygon"`
+	Track     *kmlTrack     `xml:"Track"`
+}
+
+type kmlDocument struct {
+	Name       string          `xml:"name,omitempty"`
+	Placemarks []*kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlFile struct {
+	XMLName  xml.Name     `xml:"kml"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Document *kmlDocument `xml:"Document"`
+}
+
+// parseFileTime parses a GPX / KML time; times without a zone are taken to be UTC
+func parseFileTime(rawTime string) (time.Time, error) {
+	rawTime = strings.TrimSpace(rawTime)
+
+	var err error
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		var t time.Time
+		t, err = time.Parse(layout, rawTime)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("failed to parse time %#+v: %v", rawTime, err)
+}
+
+func formatFileTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func getFileVec2(lon float64, lat float64) (pgtype.Vec2, error) {
+	if math.IsNaN(lon) || math.IsNaN(lat) || lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return pgtype.Vec2{}, fmt.Errorf("coordinates lon=%v, lat=%v out of range", lon, lat)
+	}
+
+	return pgtype.Vec2{X: lon, Y: lat}, nil
+}
+
+// parseKMLTuple parses a lon, lat[, alt] tuple that has already been split up
+func parseKMLTuple(parts []string) (pgtype.Vec2, error) {
+	if len(parts) < 2 || len(parts) > 3 {
+		return pgtype.Vec2{}, fmt.Errorf("failed to parse coordinates %#+v: expected lon, lat[, alt]", parts)
+	}
+
+	lon, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return pgtype.Vec2{}, fmt.Errorf("failed to parse coordinates %#+v: %v", parts, err)
+	}
+
+	lat, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return pgtype.Vec2{}, fmt.Errorf("failed to parse coordinates %#+v: %v", parts, err)
+	}
+
+	return getFileVec2(lon, lat)
+}
+
+// parseKMLCoordinates parses KML coordinates, i.e. whitespace-separated lon,lat[,alt] tuples
+func parseKMLCoordinates(rawCoordinates string) ([]pgtype.Vec2, error) {
+	vs := make([]pgtype.Vec2, 0)
+	for _, tuple := range strings.Fields(rawCoordinates) {
+		v, err := parseKMLTuple(strings.Split(tuple, ","))
+		if err != nil {
+			return nil, err
+		}
+
+		vs = append(vs, v)
+	}
+
+	return vs, nil
+}
+
+func formatKMLCoordinates(vs []pgtype.Vec2) string {
+	tuples := make([]string, 0)
+	for _, v := range vs {
+		tuples = append(tuples, fmt.Sprintf("%s,%s", formatFloat(v.X), formatFloat(v.Y)))
+	}
+
+	return strings.Join(tuples, " ")
+}
+
+func parseGPX(b []byte) ([]*djangolang_example.LocationHistory, error) {
+	f := gpxFile{}
+
+	err := xml.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GPX: %v", err)
+	}
+
+	points := make([]*gpxPoint, 0)
+
+	for _, track := range f.Tracks {
+		for _, segment := range track.Segments {
+			points = append(points, segment.Points...)
+		}
+	}
+
+	for _, route := range f.Routes {
+		points = append(points, route.Points...)
+	}
+
+	points = append(points, f.Waypoints...)
+
+	objects := make([]*djangolang_example.LocationHistory, 0)
+
+	for i, point := range points {
+		if point.Time == "" {
+			return nil, fmt.Errorf("failed to parse GPX point %d (lat=%v, lon=%v): no time", i, point.Lat, point.Lon)
+		}
+
+		timestamp, err := parseFileTime(point.Time)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GPX point %d: %v", i, err)
+		}
+
+		v, err := getFileVec2(point.Lon, point.Lat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GPX point %d: %v", i, err)
+		}
+
+		objects = append(objects, &djangolang_example.LocationHistory{Timestamp: timestamp, Point: &v})
+	}
+
+	return objects, nil
+}
+
+func getKMLPlacemarkObjects(placemark *kmlPlacemark) ([]*djangolang_example.LocationHistory, error) {
+	objects := make([]*djangolang_example.LocationHistory, 0)
+
+	if placemark.Track != nil {
+		if len(placemark.Track.Whens) != len(placemark.Track.Coords) {
+			return nil, fmt.Errorf("track has %d whens but %d coords", len(placemark.Track.Whens), len(placemark.Track.Coords))
+		}
+
+		for i, rawWhen := range placemark.Track.Whens {
+			timestamp, err := parseFileTime(rawWhen)
+			if err != nil {
+				return nil, err
+			}
+
+			// note: a gx:coord is a single whitespace-separated lon lat [alt] tuple
+			v, err := parseKMLTuple(strings.Fields(placemark.Track.Coords[i]))
+			if err != nil {
+				return nil, err
+			}
+
+			objects = append(objects, &djangolang_example.LocationHistory{Timestamp: timestamp, Point: &v})
+		}
+	}
+
+	if placemark.Point == nil && placemark.Polygon == nil {
+		return objects, nil
+	}
+
+	rawTime := ""
+	if placemark.TimeStamp != nil {
+		rawTime = placemark.TimeStamp.When
+	} else if placemark.TimeSpan != nil {
+		rawTime = placemark.TimeSpan.Begin
+	}
+
+	if strings.TrimSpace(rawTime) == "" {
+		return nil, fmt.Errorf("no TimeStamp or TimeSpan")
+	}
+
+	timestamp, err := parseFileTime(rawTime)
+	if err != nil {
+		return nil, err
+	}
+
+	object := &djangolang_example.LocationHistory{Timestamp: timestamp}
+
+	if placemark.Point != nil {
+		vs, err := parseKMLCoordinates(placemark.Point.Coordinates)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(vs) != 1 {
+			return nil, fmt.Errorf("expected 1 coordinate for Point but got %d", len(vs))
+		}
+
+		object.Point = &vs[0]
+	}
+
+	if placemark.Polygon != nil {
+		vs, err := parseKMLCoordinates(placemark.Polygon.Coordinates)
+		if err != nil {
+			return nil, err
+		}
+
+		// KML rings repeat the first coordinate at the end, whereas the polygon column doesn't
+		if len(vs) > 1 && vs[0] == vs[len(vs)-1] {
+			vs = vs[:len(vs)-1]
+		}
+
+		if len(vs) < 3 {
+			return nil, fmt.Errorf("expected at least 3 coordinates for Polygon but got %d", len(vs))
+		}
+
+		object.Polygon = &vs
+	}
+
+	return append(objects, object), nil
+}
+
+// parseKML reads the placemarks wherever they are (e.g. nested in folders)
+func parseKML(b []byte) ([]*djangolang_example.LocationHistory, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+
+	objects := make([]*djangolang_example.LocationHistory, 0)
+
+	i := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to unmarshal KML: %v", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Placemark" {
+			continue
+		}
+
+		placemark := &kmlPlacemark{}
+
+		err = decoder.DecodeElement(placemark, &start)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal KML placemark %d: %v", i, err)
+		}
+
+		placemarkObjects, err := getKMLPlacemarkObjects(placemark)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse KML placemark %d (%#+v): %v", i, placemark.Name, err)
+		}
+
+		objects = append(objects, placemarkObjects...)
+		i++
+	}
+
+	return objects, nil
+}
+
+// getLocationHistoryFileFormat identifies a GPX / KML file by its root element
+func getLocationHistoryFileFormat(b []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to find root element of GPX / KML file: %v", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "gpx":
+			return LocationHistoryFileFormatGPX, nil
+		case "kml":
+			return LocationHistoryFileFormatKML, nil
+		}
+
+		return "", fmt.Errorf("expected root element of gpx or kml but got %v", start.Name.Local)
+	}
+}
+
+// ParseLocationHistoryFile reads the timestamped points of a GPX file (track points, route points and waypoints) or a KML
+// file (placemarks with a TimeStamp / TimeSpan and a Point / Polygon, and gx:Tracks) as location history for the given
+// physical thing; every point must have a time
+func ParseLocationHistoryFile(b []byte, physicalThingID uuid.UUID) ([]*djangolang_example.LocationHistory, error) {
+	format, err := getLocationHistoryFileFormat(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*djangolang_example.LocationHistory
+
+	switch format {
+	case LocationHistoryFileFormatGPX:
+		objects, err = parseGPX(b)
+	case LocationHistoryFileFormatKML:
+		objects, err = parseKML(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		object.ParentPhysicalThingID = &physicalThingID
+	}
+
+	return objects, nil
+}
+
+// FormatLocationHistoryFile writes the objects (which should be in time order) out as a GPX track or as KML placemarks;
+// GPX has no polygons, so those are left out of it
+func FormatLocationHistoryFile(format string, physicalThing *djangolang_example.PhysicalThing, objects []*djangolang_example.LocationHistory) ([]byte, error) {
+	var f any
+
+	switch format {
+	case LocationHistoryFileFormatGPX:
+		segment := &gpxSegment{Points: make([]*gpxPoint, 0)}
+		for _, object := range objects {
+			if object.Point == nil {
+				continue
+			}
+
+			segment.Points = append(segment.Points, &gpxPoint{
+				Lat:  object.Point.Y,
+				Lon:  object.Point.X,
+				Time: formatFileTime(object.Timestamp),
+			})
+		}
+
+		f = &gpxFile{
+			Xmlns:   "http://www.topografix.com/GPX/1/1",
+			Version: "1.1",
+			Creator: "djangolang_example",
+			Tracks:  []*gpxTrack{{Name: physicalThing.Name, Segments: []*gpxSegment{segment}}},
+		}
+
+	case LocationHistoryFileFormatKML:
+		document := &kmlDocument{Name: physicalThing.Name, Placemarks: make([]*kmlPlacemark, 0)}
+		for _, object := range objects {
+			placemark := &kmlPlacemark{
+				Name:      object.ID.String(),
+				TimeStamp: &kmlTimeStamp{When: formatFileTime(object.Timestamp)},
+			}
+
+			if object.Point != nil {
+				placemark.Point = &kmlPoint{Coordinates: formatKMLCoordinates([]pgtype.Vec2{*object.Point})}
+			}
+
+			if object.Polygon != nil && len(*object.Polygon) > 0 {
+				vs := append(append([]pgtype.Vec2{}, *object.Polygon...), (*object.Polygon)[0])
+				placemark.Polygon = &kmlPolygon{Coordinates: formatKMLCoordinates(vs)}
+			}
+
+			if placemark.Point == nil && placemark.Polygon == nil {
+				continue
+			}
+
+			document.Placemarks = append(document.Placemarks, placemark)
+		}
+
+		f = &kmlFile{
+			Xmlns:    "http://www.opengis.net/kml/2.2",
+			Document: document,
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported format %#+v; must be one of %v or %v", format, LocationHistoryFileFormatGPX, LocationHistoryFileFormatKML)
+	}
+
+	b, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %v: %v", format, err)
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
+
+var errPhysicalThingNotFound = errors.New("physical thing not found")
+
+// lockPhysicalThing checks that the physical thing exists (and isn't soft-deleted) and stops it being deleted until the
+// end of the transaction
+func lockPhysicalThing(ctx context.Context, tx *sqlx.Tx, physicalThingID uuid.UUID) error {
+	var found bool
+
+	err := tx.QueryRowxContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT EXISTS (SELECT 1 FROM %s WHERE %s = $1 AND %s IS null FOR SHARE);",
+			query.FormatObjectName(djangolang_example.PhysicalThingTable),
+			formatColumn("", djangolang_example.PhysicalThingTablePrimaryKeyColumn),
+			formatColumn("", djangolang_example.PhysicalThingTableDeletedAtColumn),
+		),
+		physicalThingID,
+	).Scan(&found)
+	if err != nil {
+		return fmt.Errorf("failed to lock physical thing %v; err: %v", physicalThingID, err)
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %v", errPhysicalThingNotFound, physicalThingID)
+	}
+
+	return nil
+}
+
+// ImportLocationHistorys inserts the points of the given GPX / KML file (as per ParseLocationHistoryFile) as location
+// history for the given (non-deleted) physical thing via InsertLocationHistorys; the file is rejected as a whole if any
+// of its points are invalid, and (as long as the caller rolls back tx on error) none of it is kept if any of the inserts
+// fail
+func ImportLocationHistorys(ctx context.Context, tx *sqlx.Tx, physicalThingID uuid.UUID, b []byte) ([]*djangolang_example.LocationHistory, error) {
+	ctx = WithExpansionDepth(ctx, 0)
+
+	objects, err := ParseLocationHistoryFile(b, physicalThingID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockPhysicalThing(ctx, tx, physicalThingID)
+	if err != nil {
+		return nil, err
+	}
+
+	return InsertLocationHistorys(ctx, tx, objects)
+}
+
+// ExportLocationHistorys returns the location history of the given physical thing between from (inclusive) and to
+// (exclusive), either of which may be nil to leave that end of the window open, as a GPX / KML file
+func ExportLocationHistorys(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	physicalThingID uuid.UUID,
+	format string,
+	from *time.Time,
+	to *time.Time,
+) ([]byte, error) {
+	ctx = WithExpansionDepth(ctx, 0)
+
+	physicalThing, err := djangolang_example.SelectPhysicalThing(ctx, tx, fmt.Sprintf("%s = $$??", djangolang_example.PhysicalThingTablePrimaryKeyColumn), physicalThingID)
+	if err != nil {
+		return nil, err
+	}
+
+	wheres := []string{fmt.Sprintf("%s = $$??", formatColumn("", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn))}
+	values := []any{physicalThingID}
+
+	timeWheres, timeValues := getTimeWindowWheres("", from, to)
+	wheres = append(wheres, timeWheres...)
+	values = append(values, timeValues...)
+
+	orderBy := formatOrderBy(
+		[]orderByColumn{
+			{Column: djangolang_example.LocationHistoryTableTimestampColumn},
+			{Column: djangolang_example.LocationHistoryTablePrimaryKeyColumn},
+		},
+		false,
+		nil,
+	)
+
+	objects, err := SelectLocationHistorysWithOptions(ctx, tx, strings.Join(wheres, "\n    AND "), SelectOptions{OrderBy: &orderBy}, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return FormatLocationHistoryFile(format, physicalThing, objects)
+}
+
+func handlePostPhysicalThingLocationHistoryImport(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	physicalThingID, err := uuid.Parse(primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse primary key %s: %v", primaryKey, err))
+		return
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	objects, err := ImportLocationHistorys(r.Context(), tx, physicalThingID, b)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errPhysicalThingNotFound) {
+			status = http.StatusNotFound
+		}
+
+		helpers.HandleErrorResponse(w, status, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = invalidateLocationHistoryObjectTiles(redisConn, objects...)
+	if err != nil {
+		log.Printf("warning: failed to invalidate tiles: %v", err)
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusCreated, objects)
+}
+
+func handleGetPhysicalThingLocationHistoryExport(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	ctx := r.Context()
+
+	physicalThingID, err := uuid.Parse(primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse primary key %s: %v", primaryKey, err))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = LocationHistoryFileFormatGPX
+	}
+
+	contentType := contentTypeApplicationGPX
+	switch format {
+	case LocationHistoryFileFormatGPX:
+	case LocationHistoryFileFormatKML:
+		contentType = contentTypeApplicationKML
+	default:
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("failed to parse param format=%s: must be one of %v or %v", format, LocationHistoryFileFormatGPX, LocationHistoryFileFormatKML),
+		)
+		return
+	}
+
+	from, err := parseTrackTime(r, "from")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	to, err := parseTrackTime(r, "to")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	b, err := ExportLocationHistorys(ctx, tx, physicalThingID, format, from, to)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", physicalThingID, format))
+	helpers.WriteResponse(w, http.StatusOK, b)
+}
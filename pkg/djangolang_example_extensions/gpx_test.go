@@ -0,0 +1,272 @@
+package extensions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type fileTestObject struct {
+	timestamp time.Time
+	point     *pgtype.Vec2
+	polygon   *[]pgtype.Vec2
+}
+
+func getFileTestObjects(objects []*djangolang_example.LocationHistory) []fileTestObject {
+	fileTestObjects := make([]fileTestObject, 0)
+	for _, object := range objects {
+		fileTestObjects = append(fileTestObjects, fileTestObject{
+			timestamp: object.Timestamp.UTC(),
+			point:     object.Point,
+			polygon:   object.Polygon,
+		})
+	}
+
+	return fileTestObjects
+}
+
+func TestParseKMLCoordinates(t *testing.T) {
+	testCases := []struct {
+		name           string
+		rawCoordinates string
+		expected       []pgtype.Vec2
+		expectErr      bool
+	}{
+		{name: "lon,lat", rawCoordinates: "138.6,-34.9", expected: []pgtype.Vec2{{X: 138.6, Y: -34.9}}},
+		{name: "lon,lat,alt", rawCoordinates: "138.6,-34.9,50", expected: []pgtype.Vec2{{X: 138.6, Y: -34.9}}},
+		{
+			name:           "several tuples across lines",
+			rawCoordinates: "\n  1,2,0\n\t3,4 5,6  \n",
+			expected:       []pgtype.Vec2{{X: 1, Y: 2}, {X: 3, Y: 4}, {X: 5, Y: 6}},
+		},
+		{name: "empty", rawCoordinates: "  ", expected: []pgtype.Vec2{}},
+		{name: "one part", rawCoordinates: "138.6", expectErr: true},
+		{name: "too many parts", rawCoordinates: "1,2,3,4", expectErr: true},
+		{name: "not a number", rawCoordinates: "east,-34.9", expectErr: true},
+		{name: "lon out of range", rawCoordinates: "180.1,0", expectErr: true},
+		{name: "lat out of range", rawCoordinates: "0,-90.1", expectErr: true},
+		{name: "NaN", rawCoordinates: "NaN,0", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			vs, err := parseKMLCoordinates(testCase.rawCoordinates)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", vs)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(vs, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, vs)
+			}
+		})
+	}
+}
+
+func TestParseLocationHistoryFile(t *testing.T) {
+	at := func(second int) time.Time {
+		return time.Date(2024, 1, 2, 3, 4, second, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name      string
+		b         string
+		expected  []fileTestObject
+		expectErr bool
+	}{
+		{
+			name: "GPX track, route and waypoint",
+			b: `<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="3" lon="30"><time>2024-01-02T03:04:03Z</time></wpt>
+  <rte><rtept lat="2" lon="20"><time>2024-01-02T03:04:02Z</time></rtept></rte>
+  <trk><trkseg>
+    <trkpt lat="-34.9" lon="138.6"><ele>50</ele><time>2024-01-02T03:04:00Z</time></trkpt>
+    <trkpt lat="1" lon="10"><time>2024-01-02T13:34:01+10:30</time></trkpt>
+  </trkseg></trk>
+</gpx>`,
+			expected: []fileTestObject{
+				{timestamp: at(0), point: &pgtype.Vec2{X: 138.6, Y: -34.9}},
+				{timestamp: at(1), point: &pgtype.Vec2{X: 10, Y: 1}},
+				{timestamp: at(2), point: &pgtype.Vec2{X: 20, Y: 2}},
+				{timestamp: at(3), point: &pgtype.Vec2{X: 30, Y: 3}},
+			},
+		},
+		{
+			name:     "GPX time without a zone",
+			b:        `<gpx><trk><trkseg><trkpt lat="1" lon="2"><time>2024-01-02T03:04:05</time></trkpt></trkseg></trk></gpx>`,
+			expected: []fileTestObject{{timestamp: at(5), point: &pgtype.Vec2{X: 2, Y: 1}}},
+		},
+		{
+			name:      "GPX point without a time",
+			b:         `<gpx><trk><trkseg><trkpt lat="1" lon="2"></trkpt></trkseg></trk></gpx>`,
+			expectErr: true,
+		},
+		{
+			name:      "GPX point with a bad time",
+			b:         `<gpx><trk><trkseg><trkpt lat="1" lon="2"><time>yesterday</time></trkpt></trkseg></trk></gpx>`,
+			expectErr: true,
+		},
+		{
+			name:      "GPX point out of range",
+			b:         `<gpx><trk><trkseg><trkpt lat="91" lon="2"><time>2024-01-02T03:04:05Z</time></trkpt></trkseg></trk></gpx>`,
+			expectErr: true,
+		},
+		{
+			name: "KML point and polygon in a folder",
+			b: `<?xml version="1.0"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document><Folder>
+  <Placemark><TimeStamp><when>2024-01-02T03:04:00Z</when></TimeStamp><Point><coordinates>138.6,-34.9,50</coordinates></Point></Placemark>
+  <Placemark><TimeSpan><begin>2024-01-02T03:04:01Z</begin></TimeSpan><Polygon><outerBoundaryIs><LinearRing>
+    <coordinates>0,0 1,0 1,1 0,0</coordinates>
+  </LinearRing></outerBoundaryIs></Polygon></Placemark>
+</Folder></Document></kml>`,
+			expected: []fileTestObject{
+				{timestamp: at(0), point: &pgtype.Vec2{X: 138.6, Y: -34.9}},
+				{timestamp: at(1), polygon: &[]pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+			},
+		},
+		{
+			name: "KML gx:Track",
+			b: `<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2"><Document><Placemark><gx:Track>
+  <when>2024-01-02T03:04:00Z</when><when>2024-01-02T03:04:01Z</when>
+  <gx:coord>138.6 -34.9 50</gx:coord><gx:coord>138.7 -34.8</gx:coord>
+</gx:Track></Placemark></Document></kml>`,
+			expected: []fileTestObject{
+				{timestamp: at(0), point: &pgtype.Vec2{X: 138.6, Y: -34.9}},
+				{timestamp: at(1), point: &pgtype.Vec2{X: 138.7, Y: -34.8}},
+			},
+		},
+		{
+			name: "KML gx:Track with mismatched whens and coords",
+			b: `<kml xmlns:gx="http://www.google.com/kml/ext/2.2"><Placemark><gx:Track>
+  <when>2024-01-02T03:04:00Z</when><gx:coord>138.6 -34.9</gx:coord><gx:coord>138.7 -34.8</gx:coord>
+</gx:Track></Placemark></kml>`,
+			expectErr: true,
+		},
+		{
+			name:      "KML gx:coord with commas",
+			b:         `<kml xmlns:gx="http://www.google.com/kml/ext/2.2"><Placemark><gx:Track><when>2024-01-02T03:04:00Z</when><gx:coord>138.6,-34.9</gx:coord></gx:Track></Placemark></kml>`,
+			expectErr: true,
+		},
+		{
+			name:      "KML placemark without a time",
+			b:         `<kml><Placemark><Point><coordinates>1,2</coordinates></Point></Placemark></kml>`,
+			expectErr: true,
+		},
+		{
+			name:      "KML point with two coordinates",
+			b:         `<kml><Placemark><TimeStamp><when>2024-01-02T03:04:00Z</when></TimeStamp><Point><coordinates>1,2 3,4</coordinates></Point></Placemark></kml>`,
+			expectErr: true,
+		},
+		{
+			name:      "KML polygon with too few coordinates",
+			b:         `<kml><Placemark><TimeStamp><when>2024-01-02T03:04:00Z</when></TimeStamp><Polygon><outerBoundaryIs><LinearRing><coordinates>0,0 1,1 0,0</coordinates></LinearRing></outerBoundaryIs></Polygon></Placemark></kml>`,
+			expectErr: true,
+		},
+		{
+			name:      "KML point out of range",
+			b:         `<kml><Placemark><TimeStamp><when>2024-01-02T03:04:00Z</when></TimeStamp><Point><coordinates>181,2</coordinates></Point></Placemark></kml>`,
+			expectErr: true,
+		},
+		{
+			name:     "KML placemark without geometry is ignored",
+			b:        `<kml><Placemark><name>Nothing</name></Placemark></kml>`,
+			expected: []fileTestObject{},
+		},
+		{
+			name:      "other root element",
+			b:         `<svg></svg>`,
+			expectErr: true,
+		},
+		{
+			name:      "not XML",
+			b:         `{"type": "FeatureCollection"}`,
+			expectErr: true,
+		},
+	}
+
+	physicalThingID := uuid.New()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			objects, err := ParseLocationHistoryFile([]byte(testCase.b), physicalThingID)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", objects)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, object := range objects {
+				if object.ParentPhysicalThingID == nil || *object.ParentPhysicalThingID != physicalThingID {
+					t.Fatalf("expected parent physical thing %v but got %v", physicalThingID, object.ParentPhysicalThingID)
+				}
+			}
+
+			fileTestObjects := getFileTestObjects(objects)
+			if !reflect.DeepEqual(fileTestObjects, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, fileTestObjects)
+			}
+		})
+	}
+}
+
+func TestFormatLocationHistoryFile(t *testing.T) {
+	physicalThing := &djangolang_example.PhysicalThing{Name: "Thing"}
+
+	objects := []*djangolang_example.LocationHistory{
+		{ID: uuid.New(), Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC), Point: &pgtype.Vec2{X: 138.6, Y: -34.9}},
+		{ID: uuid.New(), Timestamp: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC), Polygon: &[]pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+	}
+
+	testCases := []struct {
+		name     string
+		format   string
+		expected []*djangolang_example.LocationHistory
+	}{
+		{name: "GPX leaves out polygons", format: LocationHistoryFileFormatGPX, expected: objects[:1]},
+		{name: "KML", format: LocationHistoryFileFormatKML, expected: objects},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			b, err := FormatLocationHistoryFile(testCase.format, physicalThing, objects)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			parsedObjects, err := ParseLocationHistoryFile(b, uuid.New())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := getFileTestObjects(testCase.expected)
+			parsed := getFileTestObjects(parsedObjects)
+			if !reflect.DeepEqual(parsed, expected) {
+				t.Fatalf("expected %#+v but got %#+v", expected, parsed)
+			}
+		})
+	}
+
+	_, err := FormatLocationHistoryFile("geojson", physicalThing, objects)
+	if err == nil {
+		t.Fatalf("expected an error for an unsupported format")
+	}
+}
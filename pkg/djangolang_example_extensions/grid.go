@@ -0,0 +1,239 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jmoiron/sqlx"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// maxGridCells is the most (non-empty) cells a grid may have, so that a tiny cell size can't be used to get the raw rows
+const maxGridCells = 100000
+
+// GridCell is a square of the grid with at least one point in it; column and row are the position of the cell (i.e. its
+// minimum corner divided by the cell size) and polygon is its corners
+type GridCell struct {
+	Column             int64         `json:"column"`
+	Row                int64         `json:"row"`
+	Polygon            []pgtype.Vec2 `json:"polygon"`
+	Count              int64         `json:"count"`
+	PhysicalThingCount int64         `json:"physical_thing_count"`
+}
+
+// getGridWheres returns the wheres (and their values) for the points that go in the grid; any params that aren't specific
+// to the grid are treated as filters, as per the list endpoint
+func getGridWheres(r *http.Request, from *time.Time, to *time.Time) ([]string, []any, error) {
+	wheres, values, err := getQueryFilterWheres(djangolang_example.LocationHistoryTable, r.URL.Query(), "cell", "from", "to")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wheres = append(wheres, fmt.Sprintf("%s IS NOT null", formatColumn("", djangolang_example.LocationHistoryTablePointColumn)))
+
+	timeWheres, timeValues := getTimeWindowWheres("", from, to)
+
+	return append(wheres, timeWheres...), append(values, timeValues...), nil
+}
+
+// getGridCellPolygon returns the corners of the cell at the given column and row of a grid of the given cell size
+func getGridCellPolygon(column int64, row int64, cell float64) []pgtype.Vec2 {
+	minX, minY := float64(column)*cell, float64(row)*cell
+	maxX, maxY := minX+cell, minY+cell
+
+	return []pgtype.Vec2{
+		{X: minX, Y: minY},
+		{X: maxX, Y: minY},
+		{X: maxX, Y: maxY},
+		{X: minX, Y: maxY},
+	}
+}
+
+// GetGrid buckets the points matching the wheres into a grid of squares of the given size (in coordinate units), returning
+// the cells that have points in them in column then row order
+func GetGrid(ctx context.Context, tx *sqlx.Tx, cell float64, wheres []string, values ...any) ([]*GridCell, error) {
+	if cell <= 0 {
+		return nil, fmt.Errorf("cell size must be positive but got %v", cell)
+	}
+
+	wheres = append(wheres, fmt.Sprintf("%s IS null", formatColumn("", djangolang_example.LocationHistoryTableDeletedAtColumn)))
+
+	sql := fmt.Sprintf(
+		`SELECT
+    floor((%s)[0] / $$??)::bigint,
+    floor((%s)[1] / $$??)::bigint,
+    count(*),
+    count(DISTINCT %s)
+FROM
+    %s
+WHERE
+    %s
+GROUP BY
+    1, 2
+ORDER BY
+    1 ASC, 2 ASC
+LIMIT %d;`,
+		formatColumn("", djangolang_example.LocationHistoryTablePointColumn),
+		formatColumn("", djangolang_example.LocationHistoryTablePointColumn),
+		formatColumn("", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+		query.FormatObjectName(djangolang_example.LocationHistoryTable),
+		strings.Join(wheres, "\n    AND "),
+		maxGridCells+1,
+	)
+
+	// the placeholders in the SELECT come before those in the WHERE
+	values = append([]any{cell, cell}, values...)
+
+	cells := make([]*GridCell, 0)
+
+	err := queryRows(ctx, tx, sql, values, func(rows *sqlx.Rows) error {
+		c := &GridCell{}
+
+		err := rows.Scan(&c.Column, &c.Row, &c.Count, &c.PhysicalThingCount)
+		if err != nil {
+			return err
+		}
+
+		c.Polygon = getGridCellPolygon(c.Column, c.Row, cell)
+
+		cells = append(cells, c)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cells) > maxGridCells {
+		return nil, fmt.Errorf("grid has more than %d cells; try a bigger cell size or a narrower filter", maxGridCells)
+	}
+
+	return cells, nil
+}
+
+// getGridFeatureCollection turns the cells into a GeoJSON FeatureCollection of polygons
+func getGridFeatureCollection(cells []*GridCell) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range cells {
+		feature := geojson.NewFeature(orb.Polygon{getRing(c.Polygon)})
+		feature.Properties["column"] = c.Column
+		feature.Properties["row"] = c.Row
+		feature.Properties["count"] = c.Count
+		feature.Properties["physical_thing_count"] = c.PhysicalThingCount
+
+		fc.Append(feature)
+	}
+
+	return fc
+}
+
+func handleGetLocationHistoryGrid(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware) {
+	ctx := r.Context()
+
+	cell, err := parseNonNegativeFloat(r, "cell", true)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cell == 0 {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse param cell=%s: must be positive", r.URL.Query().Get("cell")))
+		return
+	}
+
+	from, err := parseTrackTime(r, "from")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	to, err := parseTrackTime(r, "to")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	wheres, values, err := getGridWheres(r, from, to)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	geoJSON := acceptsGeoJSON(r)
+
+	requestHash, err := getDerivedRequestHash(
+		djangolang_example.LocationHistoryTable,
+		wheres,
+		values,
+		"GRID",
+		fmt.Sprintf("CELL %v", cell),
+		fmt.Sprintf("GEOJSON %v", geoJSON),
+	)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// a cache hit is written straight out, so it needs to be labelled up front
+	if geoJSON {
+		w.Header().Set("Content-Type", contentTypeApplicationGeoJSON)
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	w.Header().Del("Content-Type")
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	cells, err := GetGrid(ctx, tx, cell, wheres, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var returnedObjectsAsJSON []byte
+	if geoJSON {
+		returnedObjectsAsJSON = handleFeatureCollectionResponse(w, http.StatusOK, getGridFeatureCollection(cells), nil, nil, nil)
+	} else {
+		returnedObjectsAsJSON = helpers.HandleObjectsResponse(w, http.StatusOK, cells)
+	}
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package extensions
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+)
+
+func TestGetGridCellPolygon(t *testing.T) {
+	testCases := []struct {
+		name     string
+		column   int64
+		row      int64
+		cell     float64
+		expected []pgtype.Vec2
+	}{
+		{name: "origin", column: 0, row: 0, cell: 1, expected: []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}}},
+		{name: "negative", column: -3, row: 2, cell: 0.5, expected: []pgtype.Vec2{{X: -1.5, Y: 1}, {X: -1, Y: 1}, {X: -1, Y: 1.5}, {X: -1.5, Y: 1.5}}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			polygon := getGridCellPolygon(testCase.column, testCase.row, testCase.cell)
+			if !reflect.DeepEqual(polygon, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, polygon)
+			}
+		})
+	}
+}
+
+func TestGetGridFeatureCollection(t *testing.T) {
+	cells := []*GridCell{
+		{Column: -3, Row: 2, Polygon: getGridCellPolygon(-3, 2, 0.5), Count: 5, PhysicalThingCount: 2},
+	}
+
+	fc := getGridFeatureCollection(cells)
+
+	if len(fc.Features) != 1 {
+		t.Fatalf("expected 1 feature but got %d", len(fc.Features))
+	}
+
+	feature := fc.Features[0]
+
+	expectedGeometry := orb.Polygon{{{-1.5, 1}, {-1, 1}, {-1, 1.5}, {-1.5, 1.5}, {-1.5, 1}}}
+	if !reflect.DeepEqual(feature.Geometry, expectedGeometry) {
+		t.Fatalf("expected %#+v but got %#+v", expectedGeometry, feature.Geometry)
+	}
+
+	expectedProperties := map[string]any{"column": int64(-3), "row": int64(2), "count": int64(5), "physical_thing_count": int64(2)}
+	if !reflect.DeepEqual(map[string]any(feature.Properties), expectedProperties) {
+		t.Fatalf("expected %#+v but got %#+v", expectedProperties, feature.Properties)
+	}
+}
+
+func TestGetGridWheres(t *testing.T) {
+	from := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	r, err := http.NewRequest(http.MethodGet, "/location-histories/_grid?cell=0.1&from=2024-01-02T03:04:05Z&timestamp__lt=2025-01-01T00:00:00Z", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wheres, values, err := getGridWheres(r, &from, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedWheres := []string{`"timestamp" < $$??`, `"point" IS NOT null`, `"timestamp" >= $$??`}
+	if !reflect.DeepEqual(wheres, expectedWheres) {
+		t.Fatalf("expected %#+v but got %#+v", expectedWheres, wheres)
+	}
+
+	if len(values) != strings.Count(strings.Join(wheres, " "), "$$??") || values[1] != from {
+		t.Fatalf("expected values to line up with %#+v but got %#+v", wheres, values)
+	}
+
+	r, err = http.NewRequest(http.MethodGet, "/location-histories/_grid?cell=0.1&colour__eq=red", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _, err = getGridWheres(r, nil, nil)
+	if err == nil {
+		t.Fatalf("expected an error for an unrecognized filter")
+	}
+}
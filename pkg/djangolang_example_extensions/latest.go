@@ -0,0 +1,117 @@
+package extensions
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+// latestLocationHistoryWhere narrows location_history down to the most recent (by timestamp) row for each physical
+// thing; it's used with the list handler (for _latest) so that the usual filters and caching apply
+var latestLocationHistoryWhere = fmt.Sprintf(
+	`%s IN (
+    SELECT DISTINCT ON (%s) %s
+    FROM %s AS "__latest"
+    WHERE %s IS null
+        AND %s IS NOT null
+    ORDER BY %s, %s DESC, %s DESC
+)`,
+	formatColumn("", djangolang_example.LocationHistoryTableIDColumn),
+	formatColumn("__latest", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+	formatColumn("__latest", djangolang_example.LocationHistoryTableIDColumn),
+	query.FormatObjectName(djangolang_example.LocationHistoryTable),
+	formatColumn("__latest", djangolang_example.LocationHistoryTableDeletedAtColumn),
+	formatColumn("__latest", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+	formatColumn("__latest", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+	formatColumn("__latest", djangolang_example.LocationHistoryTableTimestampColumn),
+	formatColumn("__latest", djangolang_example.LocationHistoryTableIDColumn),
+)
+
+// handleGetPhysicalThingLocation responds with the latest location_history row of the given physical thing (in a list of
+// one, as for the item endpoints), or a 404 if it has none
+func handleGetPhysicalThingLocation(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	physicalThingID, err := uuid.Parse(primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse primary key %s: %v", primaryKey, err))
+		return
+	}
+
+	depth, err := parseDepthParam(r)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx := WithExpansionDepth(r.Context(), depth)
+
+	wheres := []string{
+		latestLocationHistoryWhere,
+		fmt.Sprintf("%s = $$??", formatColumn("", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)),
+	}
+
+	values := []any{physicalThingID}
+
+	requestHash, err := getDerivedRequestHash(djangolang_example.LocationHistoryTable, wheres, values, "LOCATION", fmt.Sprintf("DEPTH %v", depth))
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	objects, err := SelectLocationHistorysWithOptions(ctx, tx, strings.Join(wheres, "\n    AND "), SelectOptions{Limit: helpers.Ptr(2)}, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(objects) > 1 {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("attempt to select latest %s returned more than 1 row", djangolang_example.LocationHistoryTable))
+		return
+	}
+
+	if len(objects) < 1 {
+		helpers.HandleErrorResponse(w, http.StatusNotFound, fmt.Errorf("%s %v has no location", djangolang_example.PhysicalThingTable, physicalThingID))
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, objects)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
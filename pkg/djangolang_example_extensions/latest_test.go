@@ -0,0 +1,23 @@
+package extensions
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLatestLocationHistoryWhere(t *testing.T) {
+	// the soft-delete exclusion of the Select functions still has to apply to the outer rows, so the subquery mustn't
+	// be mistaken for it (see mentionsDeletedAt)
+	if mentionsDeletedAt(latestLocationHistoryWhere) {
+		t.Fatalf("expected %#+v not to mention deleted_at of location_history", latestLocationHistoryWhere)
+	}
+
+	if !strings.Contains(latestLocationHistoryWhere, `"__latest"."deleted_at" IS null`) {
+		t.Fatalf("expected %#+v to ignore soft-deleted rows", latestLocationHistoryWhere)
+	}
+
+	// it's used alongside the filters of the request, so it can't have placeholders of its own
+	if strings.Contains(latestLocationHistoryWhere, "$$??") {
+		t.Fatalf("expected %#+v not to have any placeholders", latestLocationHistoryWhere)
+	}
+}
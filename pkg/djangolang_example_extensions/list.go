@@ -0,0 +1,528 @@
+package extensions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+// selectFunc is the signature of the Select*WithOptions functions
+type selectFunc[T any] func(ctx context.Context, tx *sqlx.Tx, where string, options SelectOptions, values ...any) ([]*T, error)
+
+// parseDepthParam returns the expansion depth given by the depth param (or the default)
+func parseDepthParam(r *http.Request) (int, error) {
+	rawDepth := r.URL.Query().Get("depth")
+	if rawDepth == "" {
+		return defaultExpansionDepth, nil
+	}
+
+	depth, err := strconv.ParseInt(rawDepth, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse param depth=%s as int: %v", rawDepth, err)
+	}
+
+	if depth < 0 || depth > maxExpansionDepth {
+		return 0, fmt.Errorf("failed to parse param depth=%s: must be between 0 and %d", rawDepth, maxExpansionDepth)
+	}
+
+	return int(depth), nil
+}
+
+// handleGetList lists the objects of the given table (as selected by selectFn); parentWheres / parentValues (if any)
+// narrow the list further, e.g. to the children of a particular row for the nested routes
+func handleGetList[T any](w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, table string, selectFn selectFunc[T], parentWheres []string, parentValues []any) {
+	ctx := r.Context()
+
+	columnLookup := columnLookupByTable[table]
+	primaryKeyColumn := primaryKeyColumnByTable[table]
+
+	isLocationHistory := table == djangolang_example.LocationHistoryTable
+
+	extraKeys := make([]string, 0)
+	if isLocationHistory {
+		extraKeys = append(extraKeys, reductionQueryParams...)
+	}
+
+	unrecognizedParams := make([]string, 0)
+	hadUnrecognizedParams := false
+
+	unparseableParams := make([]string, 0)
+	hadUnparseableParams := false
+
+	values := slices.Clone(parentValues)
+
+	wheres := slices.Clone(parentWheres)
+	for rawKey, rawValues := range r.URL.Query() {
+		if isReservedQueryParam(rawKey, extraKeys...) {
+			continue
+		}
+
+		for _, rawValue := range rawValues {
+			where, whereValues, err := getFilterWhere(table, rawKey, rawValue)
+			if err != nil {
+				if errors.Is(err, errUnrecognizedFilter) {
+					unrecognizedParams = append(unrecognizedParams, fmt.Sprintf("%s=%s", rawKey, rawValue))
+					hadUnrecognizedParams = true
+				} else {
+					unparseableParams = append(unparseableParams, fmt.Sprintf("%s=%s", rawKey, rawValue))
+					hadUnparseableParams = true
+				}
+
+				continue
+			}
+
+			wheres = append(wheres, where)
+			values = append(values, whereValues...)
+		}
+	}
+
+	if hadUnrecognizedParams {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("unrecognized params %s", strings.Join(unrecognizedParams, ", ")),
+		)
+		return
+	}
+
+	if hadUnparseableParams {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("unparseable params %s", strings.Join(unparseableParams, ", ")),
+		)
+		return
+	}
+
+	for _, rawFilter := range r.URL.Query()["filter"] {
+		filterWhere, filterValues, err := getFilterExpressionWhere(table, rawFilter)
+		if err != nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param filter=%s: %v", rawFilter, err),
+			)
+			return
+		}
+
+		wheres = append(wheres, filterWhere)
+		values = append(values, filterValues...)
+	}
+
+	for _, q := range r.URL.Query()["q"] {
+		searchWhere, searchValues, err := getSearchWhere(table, q)
+		if err != nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param q=%s: %v", q, err),
+			)
+			return
+		}
+
+		wheres = append(wheres, searchWhere)
+		values = append(values, searchValues...)
+	}
+
+	deletedWhere, err := getDeletedWhere(r, table, wheres[len(parentWheres):])
+	if err != nil {
+		helpers.HandleErrorResponse(w, getDeletedRowsStatus(err), err)
+		return
+	}
+
+	if deletedWhere != "" {
+		wheres = append(wheres, deletedWhere)
+	}
+
+	limit := 2000
+	possibleLimit, err := parseNonNegativeInt(r, "limit")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if possibleLimit != nil {
+		limit = *possibleLimit
+	}
+
+	offset := 0
+	possibleOffset, err := parseNonNegativeInt(r, "offset")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if possibleOffset != nil {
+		offset = *possibleOffset
+	}
+
+	rawOrderBy := strings.Join(r.URL.Query()["order_by"], ",")
+	orderByColumns, err := parseOrderBy(rawOrderBy, columnLookup, columnKindsByTable[table], primaryKeyColumn)
+	if err != nil {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusBadRequest,
+			fmt.Errorf("failed to parse param order_by=%s: %v", rawOrderBy, err),
+		)
+		return
+	}
+
+	rawFields := strings.Join(r.URL.Query()["fields"], ",")
+	fields, err := parseFields(rawFields, columnLookup)
+	if err != nil {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("failed to parse param fields=%s: %v", rawFields, err),
+		)
+		return
+	}
+
+	// features always need a geometry, so the geometric columns can't be left out
+	geoJSON := slices.Contains(geoJSONTables, table) && acceptsGeoJSON(r)
+	if geoJSON && len(fields) > 0 {
+		for _, column := range getGeometricColumns(table) {
+			if !slices.Contains(fields, column) {
+				fields = append(fields, column)
+			}
+		}
+	}
+
+	var c *cursor
+	rawCursor := r.URL.Query().Get("cursor")
+	if rawCursor != "" {
+		if possibleOffset != nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("params cursor and offset are mutually exclusive"),
+			)
+			return
+		}
+
+		c, err = parseCursor(rawCursor, orderByColumns)
+		if err != nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param cursor=%s: %v", rawCursor, err),
+			)
+			return
+		}
+	}
+
+	depth, err := parseDepthParam(r)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx = WithExpansionDepth(ctx, depth)
+
+	rawCount := r.URL.Query().Get("count")
+	if rawCount != "" && rawCount != "exact" && rawCount != "estimate" {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("failed to parse param count=%s: must be one of exact, estimate", rawCount),
+		)
+		return
+	}
+
+	var trackReduction *reduction
+	if isLocationHistory {
+		trackReduction, err = parseReduction(r.URL.Query())
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	// the reductions work on the whole (time ordered) track of one physical thing, so they're applied after selecting all
+	// of it and before paginating
+	if trackReduction != nil {
+		if !isFilteredToSingleParent(r.URL.Query(), parentWheres, djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn) {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("params simplify and sample require filtering to a single %s (e.g. %s__eq)", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn, djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+			)
+			return
+		}
+
+		if c != nil {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("params simplify and sample can't be used with cursor"),
+			)
+			return
+		}
+
+		if rawOrderBy != "" && (orderByColumns[0].Column != djangolang_example.LocationHistoryTableTimestampColumn || orderByColumns[0].Descending) {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("params simplify and sample require order_by=%s", djangolang_example.LocationHistoryTableTimestampColumn),
+			)
+			return
+		}
+
+		orderByColumns, err = parseOrderBy(djangolang_example.LocationHistoryTableTimestampColumn, columnLookup, columnKindsByTable[table], primaryKeyColumn)
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if len(fields) > 0 && !slices.Contains(fields, djangolang_example.LocationHistoryTablePointColumn) {
+			fields = append(fields, djangolang_example.LocationHistoryTablePointColumn)
+		}
+
+		wheres = append(wheres, fmt.Sprintf("%s IS NOT null", djangolang_example.LocationHistoryTablePointColumn))
+	}
+
+	orderByExpressions, orderByValues, err := getOrderByExpressions(table, orderByColumns, r.URL.Query())
+	if err != nil {
+		helpers.HandleErrorResponse(
+			w,
+			http.StatusInternalServerError,
+			fmt.Errorf("failed to parse param order_by=%s: %v", rawOrderBy, err),
+		)
+		return
+	}
+
+	orderBy := formatOrderBy(orderByColumns, c != nil && c.Backward, orderByExpressions)
+
+	extras := []string{
+		fmt.Sprintf("ORDER BY %v %#+v", orderBy, orderByValues),
+		fmt.Sprintf("CURSOR %v", rawCursor),
+		fmt.Sprintf("COUNT %v", rawCount),
+		fmt.Sprintf("FIELDS %v", strings.Join(fields, ",")),
+		fmt.Sprintf("DEPTH %v", depth),
+	}
+
+	if isLocationHistory {
+		extras = append(
+			extras,
+			fmt.Sprintf("GEOJSON %v", geoJSON),
+			fmt.Sprintf("SAMPLE %v", r.URL.Query().Get("sample")),
+			fmt.Sprintf("SIMPLIFY %v", r.URL.Query().Get("simplify")),
+		)
+	}
+
+	requestHash, err := getRequestHash(
+		table,
+		wheres,
+		limit,
+		offset,
+		values,
+		nil,
+		extras...,
+	)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// a cache hit is written straight out, so it needs to be labelled up front
+	if geoJSON {
+		w.Header().Set("Content-Type", contentTypeApplicationGeoJSON)
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	w.Header().Del("Content-Type")
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var total *int64
+	if rawCount != "" {
+		count, err := Count(ctx, tx, table, strings.Join(wheres, "\n    AND "), rawCount == "estimate", values...)
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		total = &count
+	}
+
+	if c != nil {
+		cursorWhere, cursorValues := c.getWhere()
+		wheres = append(wheres, cursorWhere)
+		values = append(values, cursorValues...)
+	}
+
+	where := strings.Join(wheres, "\n    AND ")
+
+	// the placeholders in the ORDER BY come after those in the WHERE
+	values = append(values, orderByValues...)
+
+	// one extra row tells us if there's another page
+	limitPlusOne := limit + 1
+
+	options := SelectOptions{
+		OrderBy: &orderBy,
+		Columns: getColumnsToSelect(fields, orderByColumns, columnsByTable[table], columnsWithTypeCastsByTable[table]),
+		Limit:   &limitPlusOne,
+		Offset:  &offset,
+	}
+
+	if trackReduction != nil {
+		options.Limit, options.Offset = nil, nil
+	}
+
+	objects, err := selectFn(ctx, tx, where, options, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var nextCursor *string
+	var prevCursor *string
+
+	if trackReduction != nil {
+		// note: trackReduction is only ever set for location_history
+		locationHistorys := reduceLocationHistorys(any(objects).([]*djangolang_example.LocationHistory), trackReduction)
+		objects = paginate(any(locationHistorys).([]*T), limit, offset)
+	} else {
+		objects, nextCursor, prevCursor, err = getPage(objects, orderByColumns, limit, offset, c)
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	projectedObjects, err := projectFields(objects, fields)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var returnedObjectsAsJSON []byte
+	if geoJSON {
+		fc, err := getFeatureCollection(table, projectedObjects)
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		returnedObjectsAsJSON = handleFeatureCollectionResponse(w, http.StatusOK, fc, nextCursor, prevCursor, total)
+	} else {
+		returnedObjectsAsJSON = handleListResponse(w, http.StatusOK, projectedObjects, nextCursor, prevCursor, total)
+	}
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+// handleGetItem is the generated item endpoint for the include_deleted / only_deleted params; it returns false (having
+// written nothing) if neither param is given, in which case the generated endpoint should handle the request
+func handleGetItem[T any](w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, table string, selectFn selectFunc[T], primaryKey string) bool {
+	ctx := r.Context()
+
+	deletedWhere, err := getDeletedWhere(r, table, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, getDeletedRowsStatus(err), err)
+		return true
+	}
+
+	if deletedWhere == "" {
+		return false
+	}
+
+	wheres := []string{fmt.Sprintf("%s = $$??", primaryKeyColumnByTable[table]), deletedWhere}
+	values := []any{primaryKey}
+
+	requestHash, err := helpers.GetRequestHash(table, wheres, 2000, 0, values, primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return true
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return true
+	}
+
+	if cacheHit {
+		return true
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return true
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	where := strings.Join(wheres, "\n    AND ")
+
+	objects, err := selectFn(ctx, tx, where, SelectOptions{Limit: helpers.Ptr(2), Offset: helpers.Ptr(0)}, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return true
+	}
+
+	if len(objects) > 1 {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("attempt to select %s returned more than 1 row", table))
+		return true
+	}
+
+	if len(objects) < 1 {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("attempt to select %s returned no rows", table))
+		return true
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return true
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, objects)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+
+	return true
+}
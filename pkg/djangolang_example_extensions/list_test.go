@@ -0,0 +1,35 @@
+package extensions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+func TestHandleGetListLimitOffset(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rawQuery string
+	}{
+		{name: "negative limit", rawQuery: "limit=-1"},
+		{name: "negative offset", rawQuery: "offset=-1"},
+		{name: "limit not an int", rawQuery: "limit=a"},
+		{name: "offset not an int", rawQuery: "offset=1.5"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/physical-things?"+testCase.rawQuery, nil)
+
+			// the request is refused before the cache or the database are touched, so neither is needed
+			handleGetList(w, r, nil, nil, djangolang_example.PhysicalThingTable, SelectPhysicalThingsWithOptions, nil, nil)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected %d but got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,564 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang/pkg/types"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/maps"
+)
+
+// handlePostLocationHistorys is the generated create endpoint with support for GeoJSON (as per geoJSONTables) in and out
+// and with the new points inserted via InsertLocationHistorys (and their tiles invalidated after committing)
+func handlePostLocationHistorys(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var allItems []map[string]any
+	if isGeoJSON(r) {
+		allItems, err = getItemsFromFeatureCollection(djangolang_example.LocationHistoryTable, b)
+		if err != nil {
+			err = fmt.Errorf("failed to unmarshal %#+v as GeoJSON FeatureCollection: %v", string(b), err)
+			helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		err = json.Unmarshal(b, &allItems)
+		if err != nil {
+			err = fmt.Errorf("failed to unmarshal %#+v as JSON list of objects: %v", string(b), err)
+			helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	objects := make([]*djangolang_example.LocationHistory, 0)
+	for _, item := range allItems {
+		object := &djangolang_example.LocationHistory{}
+		err = object.FromItem(item)
+		if err != nil {
+			err = fmt.Errorf("failed to interpret %#+v as LocationHistory in item form: %v", item, err)
+			helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		objects = append(objects, object)
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	objects, err = InsertLocationHistorys(r.Context(), tx, objects)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = invalidateLocationHistoryObjectTiles(redisConn, objects...)
+	if err != nil {
+		log.Printf("warning: failed to invalidate tiles: %v", err)
+	}
+
+	if acceptsGeoJSON(r) {
+		fc, err := getFeatureCollection(djangolang_example.LocationHistoryTable, objects)
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		handleFeatureCollectionResponse(w, http.StatusCreated, fc, nil, nil, nil)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusCreated, objects)
+}
+
+// maxRowsPerLocationHistoryInsert is the most rows that go into a single INSERT (each takes up to 8 of the 65535
+// placeholders Postgres allows per statement)
+const maxRowsPerLocationHistoryInsert = 8000
+
+// locationHistoryInsertColumns are the columns that LocationHistory.Insert sets (when not setting the primary key); a
+// column is left to its default for an object with a zero value for it, though points and polygons are checked for nil
+// as types.IsZeroPoint / types.IsZeroPolygon don't recognise *pgtype.Vec2 / *[]pgtype.Vec2 (and so call them all zero)
+var locationHistoryInsertColumns = []struct {
+	column string
+	value  func(object *djangolang_example.LocationHistory) any
+	isZero func(v any) bool
+	format func(v any) (any, error)
+}{
+	{
+		column: djangolang_example.LocationHistoryTableCreatedAtColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.CreatedAt },
+		isZero: types.IsZeroTime,
+		format: types.FormatTime,
+	},
+	{
+		column: djangolang_example.LocationHistoryTableUpdatedAtColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.UpdatedAt },
+		isZero: types.IsZeroTime,
+		format: types.FormatTime,
+	},
+	{
+		column: djangolang_example.LocationHistoryTableDeletedAtColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.DeletedAt },
+		isZero: types.IsZeroTime,
+		format: types.FormatTime,
+	},
+	{
+		column: djangolang_example.LocationHistoryTableTimestampColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.Timestamp },
+		isZero: types.IsZeroTime,
+		format: types.FormatTime,
+	},
+	{
+		column: djangolang_example.LocationHistoryTablePointColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.Point },
+		isZero: func(v any) bool { return v.(*pgtype.Vec2) == nil },
+		format: types.FormatPoint,
+	},
+	{
+		column: djangolang_example.LocationHistoryTablePolygonColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.Polygon },
+		isZero: func(v any) bool { return v.(*[]pgtype.Vec2) == nil },
+		format: types.FormatPolygon,
+	},
+	{
+		column: djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.ParentPhysicalThingID },
+		isZero: types.IsZeroUUID,
+		format: types.FormatUUID,
+	},
+	{
+		column: djangolang_example.LocationHistoryTableDeletionBatchIDColumn,
+		value:  func(object *djangolang_example.LocationHistory) any { return object.DeletionBatchID },
+		isZero: types.IsZeroInt,
+		format: types.FormatInt,
+	},
+}
+
+// insertLocationHistorys inserts the objects with as few statements as possible (rather than one, plus a reload, per
+// object as per LocationHistory.Insert) and returns the inserted rows, in the order given (as Postgres inserts the rows
+// of a VALUES list in order) and without their foreign objects
+func insertLocationHistorys(ctx context.Context, tx *sqlx.Tx, objects []*djangolang_example.LocationHistory) ([]*djangolang_example.LocationHistory, error) {
+	columns := make([]string, 0, len(locationHistoryInsertColumns))
+	for _, insertColumn := range locationHistoryInsertColumns {
+		columns = append(columns, insertColumn.column)
+	}
+
+	insertedObjects := make([]*djangolang_example.LocationHistory, 0, len(objects))
+
+	for start := 0; start < len(objects); start += maxRowsPerLocationHistoryInsert {
+		batch := objects[start:min(start+maxRowsPerLocationHistoryInsert, len(objects))]
+
+		rows := make([]string, 0, len(batch))
+		values := make([]any, 0, len(batch)*len(columns))
+
+		for _, object := range batch {
+			placeholders := make([]string, 0, len(columns))
+
+			for _, insertColumn := range locationHistoryInsertColumns {
+				v := insertColumn.value(object)
+				if insertColumn.isZero(v) {
+					placeholders = append(placeholders, "DEFAULT")
+					continue
+				}
+
+				value, err := insertColumn.format(v)
+				if err != nil {
+					return nil, fmt.Errorf("failed to handle %s of %#+v: %v", insertColumn.column, object, err)
+				}
+
+				placeholders = append(placeholders, "$$??")
+				values = append(values, value)
+			}
+
+			rows = append(rows, fmt.Sprintf("(%s)", strings.Join(placeholders, ", ")))
+		}
+
+		sql := fmt.Sprintf(
+			"INSERT INTO %s (%s)\nVALUES\n    %s\nRETURNING %s;",
+			query.FormatObjectName(djangolang_example.LocationHistoryTable),
+			query.JoinObjectNames(query.FormatObjectNames(columns)),
+			strings.Join(rows, ",\n    "),
+			query.JoinObjectNames(query.FormatObjectNames(djangolang_example.LocationHistoryTableColumnsWithTypeCasts)),
+		)
+
+		batchInsertedObjects := make([]*djangolang_example.LocationHistory, 0, len(batch))
+
+		err := queryRows(ctx, tx, sql, values, func(r *sqlx.Rows) error {
+			item := make(map[string]any)
+
+			err := r.MapScan(item)
+			if err != nil {
+				return err
+			}
+
+			object := &djangolang_example.LocationHistory{}
+
+			err = object.FromItem(item)
+			if err != nil {
+				return err
+			}
+
+			batchInsertedObjects = append(batchInsertedObjects, object)
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert location history; err: %v", err)
+		}
+
+		if len(batchInsertedObjects) != len(batch) {
+			return nil, fmt.Errorf("failed to insert location history; insert returned %d rows but %d were inserted", len(batchInsertedObjects), len(batch))
+		}
+
+		insertedObjects = append(insertedObjects, batchInsertedObjects...)
+	}
+
+	return insertedObjects, nil
+}
+
+// InsertLocationHistorys is the one way location history gets inserted, by the create endpoint, the GPX / KML import
+// and any other Go code (which shouldn't use LocationHistory.Insert): it inserts the objects (as per
+// insertLocationHistorys) and then evaluates the geofences for them (as per evaluateGeofencesForInserts) in the same
+// transaction, so that no new point escapes geofence evaluation; it returns the inserted rows in the order given, with
+// their foreign objects loaded to the depth given by WithExpansionDepth
+func InsertLocationHistorys(ctx context.Context, tx *sqlx.Tx, objects []*djangolang_example.LocationHistory) ([]*djangolang_example.LocationHistory, error) {
+	insertedObjects, err := insertLocationHistorys(ctx, tx, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	err = evaluateGeofencesForInserts(ctx, tx, insertedObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadLocationHistoryForeignObjects(ctx, tx, insertedObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	return insertedObjects, nil
+}
+
+// lockLocationHistory locks the row with the given primary key (until the end of the transaction) and returns it as it
+// was before the caller changes it; nil if there's no such (non-deleted) row
+func lockLocationHistory(ctx context.Context, tx *sqlx.Tx, primaryKey string) (*djangolang_example.LocationHistory, error) {
+	_, err := tx.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT 1 FROM %s WHERE %s = $1 FOR UPDATE;",
+			query.FormatObjectName(djangolang_example.LocationHistoryTable),
+			formatColumn("", djangolang_example.LocationHistoryTablePrimaryKeyColumn),
+		),
+		primaryKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s %v; err: %v", djangolang_example.LocationHistoryTable, primaryKey, err)
+	}
+
+	objects, err := SelectLocationHistorysWithOptions(
+		WithExpansionDepth(ctx, 0),
+		tx,
+		fmt.Sprintf("%s = $$??", djangolang_example.LocationHistoryTablePrimaryKeyColumn),
+		SelectOptions{},
+		primaryKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objects) < 1 {
+		return nil, nil
+	}
+
+	return objects[0], nil
+}
+
+// handlePutLocationHistory is the generated replace endpoint, but invalidating the tiles that the row was in as well as
+// those it's now in
+func handlePutLocationHistory(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var item map[string]any
+	err = json.Unmarshal(b, &item)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON object: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	item[djangolang_example.LocationHistoryTablePrimaryKeyColumn] = primaryKey
+
+	object := &djangolang_example.LocationHistory{}
+	err = object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as LocationHistory in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	oldObject, err := lockLocationHistory(r.Context(), tx, primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = object.Update(r.Context(), tx, true)
+	if err != nil {
+		err = fmt.Errorf("failed to update %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = invalidateLocationHistoryObjectTiles(redisConn, oldObject, object)
+	if err != nil {
+		log.Printf("warning: failed to invalidate tiles: %v", err)
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusOK, []*djangolang_example.LocationHistory{object})
+}
+
+// handlePatchLocationHistory is the generated update endpoint, but invalidating the tiles as per handlePutLocationHistory
+func handlePatchLocationHistory(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("failed to read body of HTTP request: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var item map[string]any
+	err = json.Unmarshal(b, &item)
+	if err != nil {
+		err = fmt.Errorf("failed to unmarshal %#+v as JSON object: %v", string(b), err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	forceSetValuesForFields := make([]string, 0)
+	for _, possibleField := range maps.Keys(item) {
+		if !slices.Contains(djangolang_example.LocationHistoryTableColumns, possibleField) {
+			continue
+		}
+
+		forceSetValuesForFields = append(forceSetValuesForFields, possibleField)
+	}
+
+	item[djangolang_example.LocationHistoryTablePrimaryKeyColumn] = primaryKey
+
+	object := &djangolang_example.LocationHistory{}
+	err = object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as LocationHistory in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	oldObject, err := lockLocationHistory(r.Context(), tx, primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = object.Update(r.Context(), tx, false, forceSetValuesForFields...)
+	if err != nil {
+		err = fmt.Errorf("failed to update %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = invalidateLocationHistoryObjectTiles(redisConn, oldObject, object)
+	if err != nil {
+		log.Printf("warning: failed to invalidate tiles: %v", err)
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusOK, []*djangolang_example.LocationHistory{object})
+}
+
+// handleDeleteLocationHistory is the generated delete endpoint, but invalidating the tiles that the row was in
+func handleDeleteLocationHistory(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	var item = make(map[string]any)
+
+	item[djangolang_example.LocationHistoryTablePrimaryKeyColumn] = primaryKey
+
+	object := &djangolang_example.LocationHistory{}
+	err := object.FromItem(item)
+	if err != nil {
+		err = fmt.Errorf("failed to interpret %#+v as LocationHistory in item form: %v", item, err)
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	oldObject, err := lockLocationHistory(r.Context(), tx, primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = object.Delete(r.Context(), tx)
+	if err != nil {
+		err = fmt.Errorf("failed to delete %#+v: %v", object, err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = invalidateLocationHistoryObjectTiles(redisConn, oldObject)
+	if err != nil {
+		log.Printf("warning: failed to invalidate tiles: %v", err)
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusNoContent, nil)
+}
+
+func getLocationHistoryRouter(db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, fallback http.HandlerFunc) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.LocationHistoryTable, SelectLocationHistorysWithOptions, nil, nil)
+	})
+
+	r.Get("/_aggregate", func(w http.ResponseWriter, r *http.Request) {
+		handleGetAggregates(w, r, db, redisConn, modelMiddlewares, djangolang_example.LocationHistoryTable)
+	})
+
+	r.Get("/_series", func(w http.ResponseWriter, r *http.Request) {
+		handleGetSeries(w, r, db, redisConn, modelMiddlewares, djangolang_example.LocationHistoryTable)
+	})
+
+	r.Get("/_distinct/{column}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetDistinctValues(w, r, db, redisConn, modelMiddlewares, djangolang_example.LocationHistoryTable, chi.URLParam(r, "column"))
+	})
+
+	r.Get("/_latest", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.LocationHistoryTable, SelectLocationHistorysWithOptions, []string{latestLocationHistoryWhere}, nil)
+	})
+
+	r.Get("/_proximity", func(w http.ResponseWriter, r *http.Request) {
+		handleGetLocationHistoryProximity(w, r, db, redisConn, modelMiddlewares)
+	})
+
+	r.Get("/_grid", func(w http.ResponseWriter, r *http.Request) {
+		handleGetLocationHistoryGrid(w, r, db, redisConn, modelMiddlewares)
+	})
+
+	r.Get("/tiles/{z}/{x}/{y}.mvt", func(w http.ResponseWriter, r *http.Request) {
+		handleGetLocationHistoryTile(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "z"), chi.URLParam(r, "x"), chi.URLParam(r, "y"))
+	})
+
+	r.Get("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		if !handleGetItem(w, r, db, redisConn, djangolang_example.LocationHistoryTable, SelectLocationHistorysWithOptions, chi.URLParam(r, "primaryKey")) {
+			fallback(w, r)
+		}
+	})
+
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		handlePostLocationHistorys(w, r, db, redisConn, modelMiddlewares)
+	})
+
+	r.Put("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handlePutLocationHistory(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Patch("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handlePatchLocationHistory(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Delete("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteLocationHistory(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	return r
+}
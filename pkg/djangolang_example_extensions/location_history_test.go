@@ -0,0 +1,61 @@
+package extensions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestLocationHistoryInsertColumns(t *testing.T) {
+	// every column but the primary key, as per LocationHistory.Insert
+	expected := make([]string, 0)
+	for _, column := range djangolang_example.LocationHistoryTableColumns {
+		if column != djangolang_example.LocationHistoryTablePrimaryKeyColumn {
+			expected = append(expected, column)
+		}
+	}
+
+	columns := make([]string, 0)
+	for _, insertColumn := range locationHistoryInsertColumns {
+		columns = append(columns, insertColumn.column)
+	}
+
+	if !reflect.DeepEqual(columns, expected) {
+		t.Fatalf("expected %#+v but got %#+v", expected, columns)
+	}
+
+	object := &djangolang_example.LocationHistory{
+		CreatedAt:             time.Now(),
+		UpdatedAt:             time.Now(),
+		DeletedAt:             helpers.Ptr(time.Now()),
+		Timestamp:             time.Now(),
+		Point:                 &pgtype.Vec2{X: 1, Y: 2},
+		Polygon:               &[]pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}},
+		ParentPhysicalThingID: helpers.Ptr(uuid.New()),
+		DeletionBatchID:       helpers.Ptr(int64(1)),
+	}
+
+	for _, insertColumn := range locationHistoryInsertColumns {
+		t.Run(insertColumn.column, func(t *testing.T) {
+			v := insertColumn.value(&djangolang_example.LocationHistory{})
+			if !insertColumn.isZero(v) {
+				t.Fatalf("expected %#+v to be zero", v)
+			}
+
+			v = insertColumn.value(object)
+			if insertColumn.isZero(v) {
+				t.Fatalf("expected %#+v not to be zero", v)
+			}
+
+			_, err := insertColumn.format(v)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
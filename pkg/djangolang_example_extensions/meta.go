@@ -0,0 +1,232 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/openapi"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang/pkg/types"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/yaml.v2"
+)
+
+var mu = new(sync.Mutex)
+var openApi *types.OpenAPI
+
+// allObjects are the objects of the generated tables, in the order that the generated package registers them (so that
+// the OpenAPI schema comes out the same)
+var allObjects = []any{
+	djangolang_example.Fuzz{},
+	djangolang_example.GeofenceEvent{},
+	djangolang_example.Geofence{},
+	djangolang_example.LocationHistory{},
+	djangolang_example.LogicalThing{},
+	djangolang_example.PhysicalThing{},
+}
+
+var objectByTableName = map[string]any{
+	djangolang_example.FuzzTable:            djangolang_example.Fuzz{},
+	djangolang_example.GeofenceEventTable:   djangolang_example.GeofenceEvent{},
+	djangolang_example.GeofenceTable:        djangolang_example.Geofence{},
+	djangolang_example.LocationHistoryTable: djangolang_example.LocationHistory{},
+	djangolang_example.LogicalThingTable:    djangolang_example.LogicalThing{},
+	djangolang_example.PhysicalThingTable:   djangolang_example.PhysicalThing{},
+}
+
+// tableNameByPattern is where the generated router mounts each table
+var tableNameByPattern = map[string]string{
+	"/fuzzes":             djangolang_example.FuzzTable,
+	"/geofence-events":    djangolang_example.GeofenceEventTable,
+	"/geofences":          djangolang_example.GeofenceTable,
+	"/location-histories": djangolang_example.LocationHistoryTable,
+	"/logical-things":     djangolang_example.LogicalThingTable,
+	"/physical-things":    djangolang_example.PhysicalThingTable,
+}
+
+var getRouterFnByPattern = map[string]func(*sqlx.DB, redis.Conn, []server.ModelMiddleware, http.HandlerFunc) chi.Router{
+	"/fuzzes":             getFuzzRouter,
+	"/geofence-events":    getGeofenceEventRouter,
+	"/geofences":          getGeofenceRouter,
+	"/location-histories": getLocationHistoryRouter,
+	"/logical-things":     getLogicalThingRouter,
+	"/physical-things":    getPhysicalThingRouter,
+}
+
+var columnsByTable = map[string][]string{
+	djangolang_example.PhysicalThingTable:   djangolang_example.PhysicalThingTableColumns,
+	djangolang_example.LogicalThingTable:    djangolang_example.LogicalThingTableColumns,
+	djangolang_example.LocationHistoryTable: djangolang_example.LocationHistoryTableColumns,
+	djangolang_example.FuzzTable:            djangolang_example.FuzzTableColumns,
+	djangolang_example.GeofenceTable:        djangolang_example.GeofenceTableColumns,
+	djangolang_example.GeofenceEventTable:   djangolang_example.GeofenceEventTableColumns,
+}
+
+var columnsWithTypeCastsByTable = map[string][]string{
+	djangolang_example.PhysicalThingTable:   djangolang_example.PhysicalThingTableColumnsWithTypeCasts,
+	djangolang_example.LogicalThingTable:    djangolang_example.LogicalThingTableColumnsWithTypeCasts,
+	djangolang_example.LocationHistoryTable: djangolang_example.LocationHistoryTableColumnsWithTypeCasts,
+	djangolang_example.FuzzTable:            djangolang_example.FuzzTableColumnsWithTypeCasts,
+	djangolang_example.GeofenceTable:        djangolang_example.GeofenceTableColumnsWithTypeCasts,
+	djangolang_example.GeofenceEventTable:   djangolang_example.GeofenceEventTableColumnsWithTypeCasts,
+}
+
+var primaryKeyColumnByTable = map[string]string{
+	djangolang_example.PhysicalThingTable:   djangolang_example.PhysicalThingTablePrimaryKeyColumn,
+	djangolang_example.LogicalThingTable:    djangolang_example.LogicalThingTablePrimaryKeyColumn,
+	djangolang_example.LocationHistoryTable: djangolang_example.LocationHistoryTablePrimaryKeyColumn,
+	djangolang_example.FuzzTable:            djangolang_example.FuzzTablePrimaryKeyColumn,
+	djangolang_example.GeofenceTable:        djangolang_example.GeofenceTablePrimaryKeyColumn,
+	djangolang_example.GeofenceEventTable:   djangolang_example.GeofenceEventTablePrimaryKeyColumn,
+}
+
+// GetOpenAPI is djangolang_example.GetOpenAPI plus the things the handlers here add
+func GetOpenAPI() (*types.OpenAPI, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if openApi != nil {
+		return openApi, nil
+	}
+
+	// note: built afresh rather than from djangolang_example.GetOpenAPI, as that one is cached (and shared) by the
+	// generated package
+	o, err := openapi.NewFromIntrospectedSchema(allObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	err = extendOpenAPI(o)
+	if err != nil {
+		return nil, err
+	}
+
+	openApi = o
+
+	return openApi, nil
+}
+
+// withoutRouteContext drops the chi routing state from a request that's being handed from (a sub-router of) the router
+// here to the generated router, so that the generated router routes it from the top
+func withoutRouteContext(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, nil))
+}
+
+// GetRouter is djangolang_example.GetRouter with the routes here in front of it; a request that doesn't match any of
+// them (or that a handler here passes on) is handled by the generated router
+func GetRouter(db *sqlx.DB, redisConn redis.Conn, httpMiddlewares []server.HTTPMiddleware, modelMiddlewares []server.ModelMiddleware) chi.Router {
+	r := chi.NewRouter()
+
+	for _, m := range httpMiddlewares {
+		r.Use(m)
+	}
+
+	// note: the middlewares have already run by the time a request falls through
+	generatedRouter := djangolang_example.GetRouter(db, redisConn, nil, modelMiddlewares)
+
+	fallback := func(w http.ResponseWriter, r *http.Request) {
+		generatedRouter.ServeHTTP(w, withoutRouteContext(r))
+	}
+
+	// note: set before mounting anything so that the sub-routers inherit them
+	r.NotFound(fallback)
+	r.MethodNotAllowed(fallback)
+
+	for pattern, getRouterFn := range getRouterFnByPattern {
+		r.Mount(pattern, getRouterFn(db, redisConn, modelMiddlewares, fallback))
+	}
+
+	r.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-type", "application/json")
+
+		openApi, err := GetOpenAPI()
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to get OpenAPI schema: %v", err))
+			return
+		}
+
+		b, err := json.MarshalIndent(openApi, "", "  ")
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to get OpenAPI schema: %v", err))
+			return
+		}
+
+		helpers.WriteResponse(w, http.StatusOK, b)
+	})
+
+	r.Get("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-type", "application/yaml")
+
+		openApi, err := GetOpenAPI()
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to get OpenAPI schema: %v", err))
+			return
+		}
+
+		b, err := yaml.Marshal(openApi)
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to get OpenAPI schema: %v", err))
+			return
+		}
+
+		helpers.WriteResponse(w, http.StatusOK, b)
+	})
+
+	return r
+}
+
+// changeHooksBufferSize is how many changes can be waiting for the change hooks before the server starts dropping them
+// (as it does for any slow consumer of the change channel)
+const changeHooksBufferSize = 1024
+
+// runChangeHooks invalidates the cached tiles for each location_history change that comes through changes and then hands
+// the change on to outerChanges (if any, and without blocking, as per server.RunServer)
+func runChangeHooks(ctx context.Context, redisConn redis.Conn, changes chan server.Change, outerChanges chan server.Change) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change := <-changes:
+			err := invalidateLocationHistoryTiles(redisConn, change)
+			if err != nil {
+				log.Printf("warning: failed to invalidate tiles for %s: %v", change.String(), err)
+			}
+
+			if outerChanges != nil {
+				select {
+				case outerChanges <- change:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// RunServer is djangolang_example.RunServer serving GetRouter
+func RunServer(
+	ctx context.Context,
+	changes chan server.Change,
+	addr string,
+	db *sqlx.DB,
+	redisConn redis.Conn,
+	httpMiddlewares []server.HTTPMiddleware,
+	modelMiddlewares []server.ModelMiddleware,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// note: the changes pass through the change hooks on their way to the given channel
+	hookChanges := make(chan server.Change, changeHooksBufferSize)
+
+	go runChangeHooks(ctx, redisConn, hookChanges, changes)
+
+	return server.RunServer(ctx, hookChanges, addr, djangolang_example.NewFromItem, GetRouter, db, redisConn, httpMiddlewares, modelMiddlewares)
+}
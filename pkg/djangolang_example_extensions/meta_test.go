@@ -0,0 +1,69 @@
+package extensions
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/initialed85/djangolang/pkg/types"
+)
+
+func getOperation(path *types.Path, method string) *types.Operation {
+	switch method {
+	case http.MethodGet:
+		return path.Get
+	case http.MethodPost:
+		return path.Post
+	case http.MethodPut:
+		return path.Put
+	case http.MethodPatch:
+		return path.Patch
+	case http.MethodDelete:
+		return path.Delete
+	}
+
+	return nil
+}
+
+// TestGetRouterRoutesAreDocumented checks that each of the routes here is in the OpenAPI schema, so that the client can
+// call it
+func TestGetRouterRoutesAreDocumented(t *testing.T) {
+	o, err := GetOpenAPI()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := GetRouter(nil, nil, nil, nil)
+
+	routes := 0
+
+	err = chi.Walk(r, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if route == "/openapi.json" || route == "/openapi.yaml" {
+			return nil
+		}
+
+		routes++
+
+		pattern := strings.TrimSuffix(route, "/")
+
+		path, ok := o.Paths[pattern]
+		if !ok {
+			t.Errorf("expected %s to be in the OpenAPI schema", pattern)
+			return nil
+		}
+
+		if getOperation(path, method) == nil {
+			t.Errorf("expected %s %s to be in the OpenAPI schema", method, pattern)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if routes < len(getRouterFnByPattern) {
+		t.Fatalf("expected at least %d routes but got %d", len(getRouterFnByPattern), routes)
+	}
+}
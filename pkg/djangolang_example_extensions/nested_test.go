@@ -0,0 +1,30 @@
+package extensions
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestGetRouterNestedRoutes(t *testing.T) {
+	r := GetRouter(nil, nil, nil, nil)
+
+	paths := []string{
+		"/physical-things/9b6c1d5e-0f2a-4c3b-8d7e-6a5b4c3d2e1f/logical-things",
+		"/physical-things/9b6c1d5e-0f2a-4c3b-8d7e-6a5b4c3d2e1f/location-histories",
+		"/logical-things/9b6c1d5e-0f2a-4c3b-8d7e-6a5b4c3d2e1f/children",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			if !r.Match(chi.NewRouteContext(), http.MethodGet, path) {
+				t.Fatalf("expected GET %s to be routed", path)
+			}
+
+			if r.Match(chi.NewRouteContext(), http.MethodPost, path) {
+				t.Fatalf("expected POST %s not to be routed", path)
+			}
+		})
+	}
+}
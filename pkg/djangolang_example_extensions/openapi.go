@@ -0,0 +1,1273 @@
+package extensions
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/initialed85/djangolang/pkg/types"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	contentTypeApplicationJSON = "application/json"
+)
+
+var listParameters = []*types.Parameter{
+	{
+		Name:        "limit",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		Description: "SQL LIMIT operator, defaults to 2000",
+	},
+	{
+		Name:        "offset",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		Description: "SQL OFFSET operator, mutually exclusive with cursor",
+	},
+	{
+		Name:        "order_by",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "SQL ORDER BY clause, permits comma-separated scalar columns (prefix with - for descending); primary key is implicitly appended as a tiebreaker; rank orders by relevance to q / column__search and distance orders by distance from distance_from (neither can be used with cursor)",
+	},
+	{
+		Name:        "cursor",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Opaque cursor for keyset pagination, as returned in next_cursor / prev_cursor; must be used with the same order_by",
+	},
+	{
+		Name:        "count",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Include the number of rows matching the filters (ignoring limit / offset / cursor) as total; one of exact (SQL COUNT) or estimate (query planner estimate)",
+	},
+	{
+		Name:        "fields",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Comma-separated columns to return (defaults to all of them); unselected fields are omitted from the objects",
+	},
+	{
+		Name:        "filter",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Boolean filter expression, ANDed with any other filters; groups are and(...), or(...) and not(...) and filters are column__operator:value, e.g. or(type__eq:A,not(name__ilike:b)); quote values containing , or ) as JSON strings",
+	},
+	depthParameter,
+}
+
+var depthParameter = &types.Parameter{
+	Name:        "depth",
+	In:          types.InQuery,
+	Required:    false,
+	Schema:      &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+	Description: "How many levels of foreign objects (the _object fields) to load, defaults to 1; 0 disables loading them",
+}
+
+var searchParameter = &types.Parameter{
+	Name:        "q",
+	In:          types.InQuery,
+	Required:    false,
+	Schema:      &types.Schema{Type: types.TypeOfString},
+	Description: "Full-text search across the text columns (name and external_id), using search engine syntax (unquoted words, \"quoted phrases\", or, -)",
+}
+
+var distanceFromParameter = &types.Parameter{
+	Name:        "distance_from",
+	In:          types.InQuery,
+	Required:    false,
+	Schema:      &types.Schema{Type: types.TypeOfString},
+	Description: "Reference point given as x,y for order_by=distance (SQL <-> operator)",
+}
+
+// reductionParameters are the params for the location_history list endpoint that downsample / simplify the track of a
+// single physical thing
+var reductionParameters = []*types.Parameter{
+	{
+		Name:        "sample",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Keep only the first point in each time bucket of this size, as a Go duration (e.g. 30s, 5m); requires filtering to a single parent_physical_thing_id, orders by timestamp and can't be used with cursor",
+	},
+	{
+		Name:        "simplify",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfNumber, Format: types.FormatOfDouble},
+		Description: "Douglas-Peucker tolerance (in coordinate units) to simplify the track with, applied after sample; requires filtering to a single parent_physical_thing_id, orders by timestamp and can't be used with cursor",
+	},
+}
+
+var listResponseProperties = map[string]*types.Schema{
+	"next_cursor": {
+		Type:     types.TypeOfString,
+		Nullable: true,
+	},
+	"prev_cursor": {
+		Type:     types.TypeOfString,
+		Nullable: true,
+	},
+	"total": {
+		Type:     types.TypeOfInteger,
+		Format:   types.FormatOfInt64,
+		Nullable: true,
+	},
+}
+
+type nestedListEndpoint struct {
+	parentPattern string
+	pattern       string
+	childPattern  string
+	operationID   string
+	column        string
+}
+
+// nestedListEndpoints are the routes that list the rows referring to a particular row (e.g. the logical things of a
+// physical thing) or some other subset of the rows of the child table (no parentPattern, e.g. the latest location of
+// each physical thing); they take the same params as the list endpoint of the child table
+var nestedListEndpoints = []nestedListEndpoint{
+	{
+		parentPattern: "/physical-things",
+		pattern:       "/physical-things/{primaryKey}/logical-things",
+		childPattern:  "/logical-things",
+		operationID:   "GetPhysicalThingLogicalThings",
+		column:        djangolang_example.LogicalThingTableParentPhysicalThingIDColumn,
+	},
+	{
+		parentPattern: "/physical-things",
+		pattern:       "/physical-things/{primaryKey}/location-histories",
+		childPattern:  "/location-histories",
+		operationID:   "GetPhysicalThingLocationHistories",
+		column:        djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn,
+	},
+	{
+		pattern:      "/location-histories/_latest",
+		childPattern: "/location-histories",
+		operationID:  "GetLatestLocationHistories",
+	},
+	{
+		parentPattern: "/logical-things",
+		pattern:       "/logical-things/{primaryKey}/children",
+		childPattern:  "/logical-things",
+		operationID:   "GetLogicalThingChildren",
+		column:        djangolang_example.LogicalThingTableParentLogicalThingIDColumn,
+	},
+	{
+		parentPattern: "/physical-things",
+		pattern:       "/physical-things/{primaryKey}/geofence-events",
+		childPattern:  "/geofence-events",
+		operationID:   "GetPhysicalThingGeofenceEvents",
+		column:        djangolang_example.GeofenceEventTablePhysicalThingIDColumn,
+	},
+	{
+		parentPattern: "/geofences",
+		pattern:       "/geofences/{primaryKey}/events",
+		childPattern:  "/geofence-events",
+		operationID:   "GetGeofenceGeofenceEvents",
+		column:        djangolang_example.GeofenceEventTableGeofenceIDColumn,
+	},
+}
+
+// getTypedFilterParameters returns the filter parameters for the operators that only apply to particular kinds of column
+func getTypedFilterParameters(table string) []*types.Parameter {
+	parameters := make([]*types.Parameter, 0)
+
+	columns := maps.Keys(columnKindsByTable[table])
+	slices.Sort(columns)
+
+	for _, column := range columns {
+		operators := typedFilterOperators[columnKindsByTable[table][column]]
+
+		names := maps.Keys(operators)
+		slices.Sort(names)
+
+		for _, name := range names {
+			parameters = append(parameters, &types.Parameter{
+				Name:        fmt.Sprintf("%v__%v", column, name),
+				In:          types.InQuery,
+				Required:    false,
+				Schema:      &types.Schema{Type: types.TypeOfString},
+				Description: fmt.Sprintf("%v (for %v columns)", operators[name].description, columnKindsByTable[table][column]),
+			})
+		}
+	}
+
+	return parameters
+}
+
+// getForeignKeyParameters returns the filter parameters for the foreign tables of the given table (as taken from the list
+// endpoints of the foreign tables), prefixed by the foreign key column, e.g. parent_physical_thing_id__type__eq
+func getForeignKeyParameters(table string, filterParametersByTable map[string][]*types.Parameter) []*types.Parameter {
+	parameters := make([]*types.Parameter, 0)
+
+	columns := maps.Keys(foreignKeysByTable[table])
+	slices.Sort(columns)
+
+	for _, column := range columns {
+		fk := foreignKeysByTable[table][column]
+
+		for _, parameter := range filterParametersByTable[fk.table] {
+			parameters = append(parameters, &types.Parameter{
+				Name:        fmt.Sprintf("%v__%v", column, parameter.Name),
+				In:          parameter.In,
+				Required:    parameter.Required,
+				Schema:      parameter.Schema,
+				Description: fmt.Sprintf("%v, applied to the %v row referred to by %v", parameter.Description, fk.table, column),
+			})
+		}
+	}
+
+	return parameters
+}
+
+var geoJSONGeometrySchema = &types.Schema{
+	Type: types.TypeOfObject,
+	Properties: map[string]*types.Schema{
+		"type": {
+			Type: types.TypeOfString,
+		},
+		"coordinates": {
+			Type:  types.TypeOfArray,
+			Items: &types.Schema{},
+		},
+	},
+	Required: []string{"type", "coordinates"},
+}
+
+// addGeoJSONContent documents the application/geo+json variants of the list and create endpoints at the given pattern,
+// as a FeatureCollection of features with the usual objects (less the geometric columns) as their properties
+func addGeoJSONContent(o *types.OpenAPI, pattern string) error {
+	path := o.Paths[pattern]
+
+	getResponse := path.Get.Responses[fmt.Sprintf("%v", http.StatusOK)]
+	objects := getResponse.Content[contentTypeApplicationJSON].Schema.Properties["objects"]
+	if objects == nil || objects.Items == nil || objects.Items.Ref == "" {
+		return fmt.Errorf("failed to find objects schema for %v in OpenAPI schema", pattern)
+	}
+
+	name := strings.TrimPrefix(objects.Items.Ref, "#/components/schemas/")
+
+	featureCollectionProperties := map[string]*types.Schema{
+		"type": {
+			Type: types.TypeOfString,
+		},
+		"features": {
+			Type: types.TypeOfArray,
+			Items: &types.Schema{
+				Ref: fmt.Sprintf("#/components/schemas/%vFeature", name),
+			},
+		},
+	}
+
+	for property, schema := range listResponseProperties {
+		featureCollectionProperties[property] = schema
+	}
+
+	o.Components.Schemas["GeoJSONGeometry"] = geoJSONGeometrySchema
+
+	o.Components.Schemas[fmt.Sprintf("%vFeature", name)] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"type": {
+				Type: types.TypeOfString,
+			},
+			"id": {
+				Type:   types.TypeOfString,
+				Format: types.FormatOfUUID,
+			},
+			"geometry": {
+				Ref: "#/components/schemas/GeoJSONGeometry",
+			},
+			"properties": {
+				Ref: objects.Items.Ref,
+			},
+		},
+		Required: []string{"type", "geometry", "properties"},
+	}
+
+	o.Components.Schemas[fmt.Sprintf("%vFeatureCollection", name)] = &types.Schema{
+		Type:       types.TypeOfObject,
+		Properties: featureCollectionProperties,
+		Required:   []string{"type", "features"},
+	}
+
+	featureCollection := &types.MediaType{
+		Schema: &types.Schema{
+			Ref: fmt.Sprintf("#/components/schemas/%vFeatureCollection", name),
+		},
+	}
+
+	getResponse.Content[contentTypeApplicationGeoJSON] = featureCollection
+
+	if path.Post == nil || path.Post.RequestBody == nil {
+		return fmt.Errorf("failed to find create endpoint for %v in OpenAPI schema", pattern)
+	}
+
+	path.Post.RequestBody.Content[contentTypeApplicationGeoJSON] = featureCollection
+
+	for status, response := range path.Post.Responses {
+		if status == "default" || response.Content == nil {
+			continue
+		}
+
+		response.Content[contentTypeApplicationGeoJSON] = featureCollection
+	}
+
+	return nil
+}
+
+// getObjectsResponses returns the responses for an endpoint that responds (like the generated ones) with objects of the
+// given schema, e.g. "Location Fetch for PhysicalThings"
+func getObjectsResponses(description string, itemSchema *types.Schema) map[string]*types.Response {
+	properties := map[string]*types.Schema{
+		"error": {
+			Type: types.TypeOfString,
+		},
+		"status": {
+			Type:   types.TypeOfInteger,
+			Format: types.FormatOfInt32,
+		},
+		"success": {
+			Type: types.TypeOfBoolean,
+		},
+	}
+
+	objectsProperties := maps.Clone(properties)
+	objectsProperties["objects"] = &types.Schema{
+		Type:  types.TypeOfArray,
+		Items: itemSchema,
+	}
+
+	return map[string]*types.Response{
+		fmt.Sprintf("%v", http.StatusOK): {
+			Description: fmt.Sprintf("Successful %v", description),
+			Content: map[string]*types.MediaType{
+				contentTypeApplicationJSON: {
+					Schema: &types.Schema{
+						Type:       types.TypeOfObject,
+						Properties: objectsProperties,
+						Required:   []string{"status", "success"},
+					},
+				},
+			},
+		},
+		"default": {
+			Description: fmt.Sprintf("Failed %v", description),
+			Content: map[string]*types.MediaType{
+				contentTypeApplicationJSON: {
+					Schema: &types.Schema{
+						Type:       types.TypeOfObject,
+						Properties: properties,
+						Required:   []string{"status", "success"},
+					},
+				},
+			},
+		},
+	}
+}
+
+var trackParameters = []*types.Parameter{
+	{
+		Name:        "from",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "Start of the time window (inclusive), RFC3339; defaults to the first point",
+	},
+	{
+		Name:        "to",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "End of the time window (exclusive), RFC3339; defaults to the last point",
+	},
+	{
+		Name:        "stop_speed",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfNumber, Format: types.FormatOfDouble},
+		Description: "Speed (metres per second) at or below which the thing is considered stopped, defaults to 0.5",
+	},
+	{
+		Name:        "stop_duration",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "How long the thing has to be stopped for it to count as a dwell, as a Go duration (e.g. 90s, 5m), defaults to 5m",
+	},
+}
+
+// addLocationEndpoint documents GET /physical-things/{primaryKey}/location
+func addLocationEndpoint(o *types.OpenAPI) error {
+	parentPath := o.Paths["/physical-things/{primaryKey}"]
+	if parentPath == nil || parentPath.Get == nil || len(parentPath.Get.Parameters) == 0 {
+		return fmt.Errorf("failed to find item endpoint for /physical-things in OpenAPI schema")
+	}
+
+	primaryKeyParameter := *parentPath.Get.Parameters[0]
+	primaryKeyParameter.Description = fmt.Sprintf("%v (matched against %v)", primaryKeyParameter.Description, djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)
+
+	o.Paths["/physical-things/{primaryKey}/location"] = &types.Path{
+		Get: &types.Operation{
+			Tags:        parentPath.Get.Tags,
+			OperationID: "GetPhysicalThingLocation",
+			Parameters:  []*types.Parameter{&primaryKeyParameter, depthParameter},
+			Responses:   getObjectsResponses("Location Fetch for PhysicalThings", &types.Schema{Ref: "#/components/schemas/LocationHistory"}),
+		},
+	}
+
+	return nil
+}
+
+// addTrackEndpoint documents GET /physical-things/{primaryKey}/track
+func addTrackEndpoint(o *types.OpenAPI) error {
+	parentPath := o.Paths["/physical-things/{primaryKey}"]
+	if parentPath == nil || parentPath.Get == nil || len(parentPath.Get.Parameters) == 0 {
+		return fmt.Errorf("failed to find item endpoint for /physical-things in OpenAPI schema")
+	}
+
+	primaryKeyParameter := *parentPath.Get.Parameters[0]
+	primaryKeyParameter.Description = fmt.Sprintf("%v (matched against %v)", primaryKeyParameter.Description, djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)
+
+	timeSchema := &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime}
+	numberSchema := &types.Schema{Type: types.TypeOfNumber, Format: types.FormatOfDouble}
+
+	o.Components.Schemas["TrackSegment"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"from":     timeSchema,
+			"to":       timeSchema,
+			"distance": numberSchema,
+			"speed":    numberSchema,
+		},
+	}
+
+	o.Components.Schemas["TrackDwell"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"from":     timeSchema,
+			"to":       timeSchema,
+			"duration": numberSchema,
+			"point":    {Ref: "#/components/schemas/Vec2"},
+		},
+	}
+
+	o.Components.Schemas["Track"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"points": {
+				Type:  types.TypeOfArray,
+				Items: &types.Schema{Ref: "#/components/schemas/LocationHistory"},
+			},
+			"segments": {
+				Type:  types.TypeOfArray,
+				Items: &types.Schema{Ref: "#/components/schemas/TrackSegment"},
+			},
+			"dwells": {
+				Type:  types.TypeOfArray,
+				Items: &types.Schema{Ref: "#/components/schemas/TrackDwell"},
+			},
+			"total_distance": numberSchema,
+			"max_speed":      numberSchema,
+		},
+	}
+
+	o.Paths["/physical-things/{primaryKey}/track"] = &types.Path{
+		Get: &types.Operation{
+			Tags:        parentPath.Get.Tags,
+			OperationID: "GetPhysicalThingTrack",
+			Parameters:  append([]*types.Parameter{&primaryKeyParameter}, trackParameters...),
+			Responses:   getObjectsResponses("Track Fetch for PhysicalThings", &types.Schema{Ref: "#/components/schemas/Track"}),
+		},
+	}
+
+	return nil
+}
+
+var exportParameters = []*types.Parameter{
+	{
+		Name:        "format",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Format of the file, one of gpx or kml; defaults to gpx (which leaves out polygons)",
+	},
+	{
+		Name:        "from",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "Start of the time window (inclusive), RFC3339; defaults to the first point",
+	},
+	{
+		Name:        "to",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "End of the time window (exclusive), RFC3339; defaults to the last point",
+	},
+}
+
+// addLocationHistoryFileEndpoints documents the GPX / KML import and export endpoints for the location history of a
+// physical thing
+func addLocationHistoryFileEndpoints(o *types.OpenAPI) error {
+	parentPath := o.Paths["/physical-things/{primaryKey}"]
+	if parentPath == nil || parentPath.Get == nil || len(parentPath.Get.Parameters) == 0 {
+		return fmt.Errorf("failed to find item endpoint for /physical-things in OpenAPI schema")
+	}
+
+	primaryKeyParameter := *parentPath.Get.Parameters[0]
+	primaryKeyParameter.Description = fmt.Sprintf("%v (matched against %v)", primaryKeyParameter.Description, djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)
+
+	fileContent := map[string]*types.MediaType{
+		contentTypeApplicationGPX: {
+			Schema: &types.Schema{Type: types.TypeOfString},
+		},
+		contentTypeApplicationKML: {
+			Schema: &types.Schema{Type: types.TypeOfString},
+		},
+	}
+
+	exportResponses := getObjectsResponses("Export for LocationHistories", &types.Schema{Ref: "#/components/schemas/LocationHistory"})
+	exportResponses[fmt.Sprintf("%v", http.StatusOK)].Content = fileContent
+
+	o.Paths["/physical-things/{primaryKey}/location-histories/export"] = &types.Path{
+		Get: &types.Operation{
+			Tags:        parentPath.Get.Tags,
+			OperationID: "GetPhysicalThingLocationHistoryExport",
+			Parameters:  append([]*types.Parameter{&primaryKeyParameter}, exportParameters...),
+			Responses:   exportResponses,
+		},
+	}
+
+	o.Paths["/physical-things/{primaryKey}/location-histories/import"] = &types.Path{
+		Post: &types.Operation{
+			Tags:        parentPath.Get.Tags,
+			OperationID: "PostPhysicalThingLocationHistoryImport",
+			Parameters:  []*types.Parameter{&primaryKeyParameter},
+			RequestBody: &types.RequestBody{
+				Content:  fileContent,
+				Required: true,
+			},
+			Responses: getObjectsResponses("Import for LocationHistories", &types.Schema{Ref: "#/components/schemas/LocationHistory"}),
+		},
+	}
+
+	return nil
+}
+
+var deletedParameters = []*types.Parameter{
+	{
+		Name:        "include_deleted",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfBoolean},
+		Description: "Include soft-deleted rows (subject to authorization), mutually exclusive with only_deleted",
+	},
+	{
+		Name:        "only_deleted",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfBoolean},
+		Description: "Only soft-deleted rows (subject to authorization), mutually exclusive with include_deleted",
+	},
+}
+
+var hardDeleteParameter = &types.Parameter{
+	Name:        "hard",
+	In:          types.InQuery,
+	Required:    false,
+	Schema:      &types.Schema{Type: types.TypeOfBoolean},
+	Description: "Permanently delete the row and its dependents (subject to authorization) rather than soft-deleting it",
+}
+
+// addRestoreEndpoint documents POST /physical-things/{primaryKey}/restore, which undoes the soft-delete of a physical
+// thing (and of the rows it cascaded to), and the hard param of DELETE /physical-things/{primaryKey}
+func addRestoreEndpoint(o *types.OpenAPI) error {
+	parentPath := o.Paths["/physical-things/{primaryKey}"]
+	if parentPath == nil || parentPath.Get == nil || len(parentPath.Get.Parameters) == 0 {
+		return fmt.Errorf("failed to find item endpoint for /physical-things in OpenAPI schema")
+	}
+
+	if parentPath.Delete == nil {
+		return fmt.Errorf("failed to find delete endpoint for /physical-things in OpenAPI schema")
+	}
+
+	parentPath.Delete.Parameters = append(parentPath.Delete.Parameters, hardDeleteParameter)
+
+	o.Paths["/physical-things/{primaryKey}/restore"] = &types.Path{
+		Post: &types.Operation{
+			Tags:        parentPath.Get.Tags,
+			OperationID: "PostPhysicalThingRestore",
+			Parameters:  []*types.Parameter{parentPath.Get.Parameters[0]},
+			Responses:   getObjectsResponses("Restore for PhysicalThing", &types.Schema{Ref: "#/components/schemas/PhysicalThing"}),
+		},
+	}
+
+	return nil
+}
+
+var contactParameters = []*types.Parameter{
+	{
+		Name:        "radius",
+		In:          types.InQuery,
+		Required:    true,
+		Schema:      &types.Schema{Type: types.TypeOfNumber, Format: types.FormatOfDouble},
+		Description: "How close (in metres, with points taken to be longitude / latitude) counts as a contact",
+	},
+	{
+		Name:        "from",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "Start of the time window (inclusive), RFC3339; defaults to the first point",
+	},
+	{
+		Name:        "to",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "End of the time window (exclusive), RFC3339; defaults to the last point",
+	},
+}
+
+var proximityParameters = []*types.Parameter{
+	{
+		Name:        "point",
+		In:          types.InQuery,
+		Required:    true,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "The point to find contacts near, as longitude,latitude",
+	},
+}
+
+var coLocationParameters = []*types.Parameter{
+	{
+		Name:        "window",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "How far apart in time two points can be and still count as together, as a Go duration (e.g. 30s, 1m), defaults to 1m",
+	},
+	{
+		Name:        "gap",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "How long a contact can go without any points together before it counts as a new contact, as a Go duration, defaults to 5m",
+	},
+	{
+		Name:        "min_duration",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "How long the longest unbroken contact has to last for the physical thing to be included, as a Go duration, defaults to 0s",
+	},
+}
+
+// addContactEndpoints documents GET /location-histories/_proximity and GET /physical-things/{primaryKey}/contacts
+func addContactEndpoints(o *types.OpenAPI) error {
+	parentPath := o.Paths["/physical-things/{primaryKey}"]
+	if parentPath == nil || parentPath.Get == nil || len(parentPath.Get.Parameters) == 0 {
+		return fmt.Errorf("failed to find item endpoint for /physical-things in OpenAPI schema")
+	}
+
+	childPath := o.Paths["/location-histories"]
+	if childPath == nil || childPath.Get == nil {
+		return fmt.Errorf("failed to find list endpoint for /location-histories in OpenAPI schema")
+	}
+
+	primaryKeyParameter := *parentPath.Get.Parameters[0]
+	primaryKeyParameter.Description = fmt.Sprintf("%v (matched against %v)", primaryKeyParameter.Description, djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)
+
+	timeSchema := &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime}
+
+	o.Components.Schemas["Contact"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"physical_thing_id":        {Type: types.TypeOfString, Format: types.FormatOfUUID},
+			"physical_thing_id_object": {Ref: "#/components/schemas/PhysicalThing"},
+			"first_contact":            timeSchema,
+			"last_contact":             timeSchema,
+			"duration":                 {Type: types.TypeOfNumber, Format: types.FormatOfDouble},
+			"points":                   {Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		},
+	}
+
+	o.Paths["/location-histories/_proximity"] = &types.Path{
+		Get: &types.Operation{
+			Tags:        childPath.Get.Tags,
+			OperationID: "GetLocationHistoryProximity",
+			Parameters:  append(slices.Clone(proximityParameters), contactParameters...),
+			Responses:   getObjectsResponses("Proximity Fetch for LocationHistories", &types.Schema{Ref: "#/components/schemas/Contact"}),
+		},
+	}
+
+	parameters := append([]*types.Parameter{&primaryKeyParameter}, contactParameters...)
+
+	o.Paths["/physical-things/{primaryKey}/contacts"] = &types.Path{
+		Get: &types.Operation{
+			Tags:        parentPath.Get.Tags,
+			OperationID: "GetPhysicalThingContacts",
+			Parameters:  append(parameters, coLocationParameters...),
+			Responses:   getObjectsResponses("Contacts Fetch for PhysicalThings", &types.Schema{Ref: "#/components/schemas/Contact"}),
+		},
+	}
+
+	return nil
+}
+
+var gridParameters = []*types.Parameter{
+	{
+		Name:        "cell",
+		In:          types.InQuery,
+		Required:    true,
+		Schema:      &types.Schema{Type: types.TypeOfNumber, Format: types.FormatOfDouble},
+		Description: "The size (in coordinate units) of the squares of the grid",
+	},
+	{
+		Name:        "from",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "Start of the time window (inclusive), RFC3339; defaults to the first point",
+	},
+	{
+		Name:        "to",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "End of the time window (exclusive), RFC3339; defaults to the last point",
+	},
+}
+
+// addGridEndpoint documents GET /location-histories/_grid, which takes the same filters as the list endpoint
+func addGridEndpoint(o *types.OpenAPI) error {
+	childPath := o.Paths["/location-histories"]
+	if childPath == nil || childPath.Get == nil {
+		return fmt.Errorf("failed to find list endpoint for /location-histories in OpenAPI schema")
+	}
+
+	parameters := slices.Clone(gridParameters)
+	for _, parameter := range childPath.Get.Parameters {
+		if parameter.In != types.InQuery || !strings.Contains(parameter.Name, "__") {
+			continue
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	for _, parameter := range listParameters {
+		if parameter.Name == "filter" {
+			parameters = append(parameters, parameter)
+		}
+	}
+
+	integerSchema := &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64}
+
+	gridCellProperties := map[string]*types.Schema{
+		"column":               integerSchema,
+		"row":                  integerSchema,
+		"count":                integerSchema,
+		"physical_thing_count": integerSchema,
+	}
+
+	o.Components.Schemas["GridCell"] = &types.Schema{
+		Type:       types.TypeOfObject,
+		Properties: maps.Clone(gridCellProperties),
+	}
+
+	o.Components.Schemas["GridCell"].Properties["polygon"] = &types.Schema{
+		Type:  types.TypeOfArray,
+		Items: &types.Schema{Ref: "#/components/schemas/Vec2"},
+	}
+
+	o.Components.Schemas["GeoJSONGeometry"] = geoJSONGeometrySchema
+
+	o.Components.Schemas["GridCellFeature"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"type": {
+				Type: types.TypeOfString,
+			},
+			"geometry": {
+				Ref: "#/components/schemas/GeoJSONGeometry",
+			},
+			"properties": {
+				Type:       types.TypeOfObject,
+				Properties: gridCellProperties,
+			},
+		},
+		Required: []string{"type", "geometry", "properties"},
+	}
+
+	o.Components.Schemas["GridCellFeatureCollection"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"type": {
+				Type: types.TypeOfString,
+			},
+			"features": {
+				Type: types.TypeOfArray,
+				Items: &types.Schema{
+					Ref: "#/components/schemas/GridCellFeature",
+				},
+			},
+		},
+		Required: []string{"type", "features"},
+	}
+
+	responses := getObjectsResponses("Grid Fetch for LocationHistories", &types.Schema{Ref: "#/components/schemas/GridCell"})
+
+	responses[fmt.Sprintf("%v", http.StatusOK)].Content[contentTypeApplicationGeoJSON] = &types.MediaType{
+		Schema: &types.Schema{
+			Ref: "#/components/schemas/GridCellFeatureCollection",
+		},
+	}
+
+	o.Paths["/location-histories/_grid"] = &types.Path{
+		Get: &types.Operation{
+			Tags:        childPath.Get.Tags,
+			OperationID: "GetLocationHistoryGrid",
+			Parameters:  parameters,
+			Responses:   responses,
+		},
+	}
+
+	return nil
+}
+
+// addTileEndpoint documents GET /location-histories/tiles/{z}/{x}/{y}.mvt, which takes the same filters as the list
+// endpoint
+func addTileEndpoint(o *types.OpenAPI) error {
+	childPath := o.Paths["/location-histories"]
+	if childPath == nil || childPath.Get == nil {
+		return fmt.Errorf("failed to find list endpoint for /location-histories in OpenAPI schema")
+	}
+
+	integerSchema := &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt32}
+
+	parameters := []*types.Parameter{
+		{
+			Name:        "z",
+			In:          types.InPath,
+			Required:    true,
+			Schema:      integerSchema,
+			Description: fmt.Sprintf("Zoom level of the tile, from 0 to %d", maxTileZoom),
+		},
+		{
+			Name:        "x",
+			In:          types.InPath,
+			Required:    true,
+			Schema:      integerSchema,
+			Description: "Column of the tile",
+		},
+		{
+			Name:        "y",
+			In:          types.InPath,
+			Required:    true,
+			Schema:      integerSchema,
+			Description: "Row of the tile (counting down from the north)",
+		},
+	}
+
+	for _, parameter := range childPath.Get.Parameters {
+		if parameter.In != types.InQuery || !strings.Contains(parameter.Name, "__") {
+			continue
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	for _, parameter := range listParameters {
+		if parameter.Name == "filter" {
+			parameters = append(parameters, parameter)
+		}
+	}
+
+	parameters = append(parameters, deletedParameters...)
+
+	responses := getObjectsResponses("Tile Fetch for LocationHistories", &types.Schema{Ref: "#/components/schemas/LocationHistory"})
+
+	responses[fmt.Sprintf("%v", http.StatusOK)].Content = map[string]*types.MediaType{
+		contentTypeApplicationMVT: {
+			Schema: &types.Schema{
+				Type:   types.TypeOfString,
+				Format: types.FormatOfBinary,
+			},
+		},
+	}
+
+	o.Paths["/location-histories/tiles/{z}/{x}/{y}.mvt"] = &types.Path{
+		Get: &types.Operation{
+			Tags:        childPath.Get.Tags,
+			OperationID: "GetLocationHistoryTile",
+			Parameters:  parameters,
+			Responses:   responses,
+		},
+	}
+
+	return nil
+}
+
+var aggregateParameters = []*types.Parameter{
+	{
+		Name:        "group_by",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Comma-separated columns to group by; defaults to a single group of all the rows",
+	},
+	{
+		Name:        "agg",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Comma-separated aggregates (count, min, max, sum or avg of a column, or count(*)), e.g. count(*),max(timestamp); each is keyed by function_column (or just count for count(*)); defaults to count(*)",
+	},
+	{
+		Name:        "limit",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		Description: fmt.Sprintf("SQL LIMIT operator for the groups, defaults to all of them (up to %d)", maxAggregateGroups),
+	},
+	{
+		Name:        "offset",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		Description: "SQL OFFSET operator for the groups, defaults to 0",
+	},
+}
+
+// addAggregateEndpoint documents GET [pattern]/_aggregate, which takes the same filters as the list endpoint at pattern
+func addAggregateEndpoint(o *types.OpenAPI, pattern string) error {
+	childPath := o.Paths[pattern]
+	if childPath == nil || childPath.Get == nil || len(childPath.Get.Tags) == 0 {
+		return fmt.Errorf("failed to find list endpoint for %v in OpenAPI schema", pattern)
+	}
+
+	parameters := slices.Clone(aggregateParameters)
+	for _, parameter := range childPath.Get.Parameters {
+		if parameter.In != types.InQuery || (!strings.Contains(parameter.Name, "__") && parameter.Name != "filter") {
+			continue
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	o.Paths[fmt.Sprintf("%v/_aggregate", pattern)] = &types.Path{
+		Get: &types.Operation{
+			Tags:        childPath.Get.Tags,
+			OperationID: fmt.Sprintf("Get%vAggregate", childPath.Get.Tags[0]),
+			Parameters:  parameters,
+			Responses: getObjectsResponses(
+				fmt.Sprintf("Aggregate for %v", childPath.Get.Tags[0]),
+				&types.Schema{Type: types.TypeOfObject, AdditionalProperties: &types.Schema{}},
+			),
+		},
+	}
+
+	return nil
+}
+
+var seriesParameters = []*types.Parameter{
+	{
+		Name:        "bucket",
+		In:          types.InQuery,
+		Required:    true,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Size of the time buckets as a Go duration of whole seconds (e.g. 15m or 1h); buckets are aligned to the Unix epoch",
+	},
+	{
+		Name:        "column",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Time column to bucket by; defaults to timestamp (if the table has one) or created_at",
+	},
+	{
+		Name:        "group_by",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Comma-separated columns to group by; defaults to a single group of all the rows",
+	},
+	{
+		Name:        "from",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "Start of the time window (inclusive), RFC3339; defaults to the first bucket with rows in it",
+	},
+	{
+		Name:        "to",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfString, Format: types.FormatOfDateTime},
+		Description: "End of the time window (exclusive), RFC3339; defaults to the end of the last bucket with rows in it",
+	},
+}
+
+// addSeriesEndpoint documents GET [pattern]/_series, which takes the same filters as the list endpoint at pattern
+func addSeriesEndpoint(o *types.OpenAPI, pattern string) error {
+	childPath := o.Paths[pattern]
+	if childPath == nil || childPath.Get == nil || len(childPath.Get.Tags) == 0 {
+		return fmt.Errorf("failed to find list endpoint for %v in OpenAPI schema", pattern)
+	}
+
+	parameters := slices.Clone(seriesParameters)
+	for _, parameter := range childPath.Get.Parameters {
+		if parameter.In != types.InQuery || (!strings.Contains(parameter.Name, "__") && parameter.Name != "filter") {
+			continue
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	o.Components.Schemas["SeriesBucket"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"bucket": {Type: types.TypeOfString, Format: types.FormatOfDateTime},
+			"group":  {Type: types.TypeOfObject, AdditionalProperties: &types.Schema{}},
+			"count":  {Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		},
+	}
+
+	o.Paths[fmt.Sprintf("%v/_series", pattern)] = &types.Path{
+		Get: &types.Operation{
+			Tags:        childPath.Get.Tags,
+			OperationID: fmt.Sprintf("Get%vSeries", childPath.Get.Tags[0]),
+			Parameters:  parameters,
+			Responses: getObjectsResponses(
+				fmt.Sprintf("Series for %v", childPath.Get.Tags[0]),
+				&types.Schema{Ref: "#/components/schemas/SeriesBucket"},
+			),
+		},
+	}
+
+	return nil
+}
+
+var distinctParameters = []*types.Parameter{
+	{
+		Name:        "column",
+		In:          types.InPath,
+		Required:    true,
+		Schema:      &types.Schema{Type: types.TypeOfString},
+		Description: "Column to get the distinct values of; the elements of array columns are counted individually",
+	},
+	{
+		Name:        "limit",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		Description: fmt.Sprintf("SQL LIMIT operator for the values, defaults to %d", defaultDistinctLimit),
+	},
+	{
+		Name:        "offset",
+		In:          types.InQuery,
+		Required:    false,
+		Schema:      &types.Schema{Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		Description: "SQL OFFSET operator for the values, defaults to 0",
+	},
+}
+
+// addDistinctEndpoint documents GET [pattern]/_distinct/{column}, which takes the same filters as the list endpoint at
+// pattern
+func addDistinctEndpoint(o *types.OpenAPI, pattern string) error {
+	childPath := o.Paths[pattern]
+	if childPath == nil || childPath.Get == nil || len(childPath.Get.Tags) == 0 {
+		return fmt.Errorf("failed to find list endpoint for %v in OpenAPI schema", pattern)
+	}
+
+	parameters := slices.Clone(distinctParameters)
+	for _, parameter := range childPath.Get.Parameters {
+		if parameter.In != types.InQuery || (!strings.Contains(parameter.Name, "__") && parameter.Name != "filter") {
+			continue
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	o.Components.Schemas["DistinctValue"] = &types.Schema{
+		Type: types.TypeOfObject,
+		Properties: map[string]*types.Schema{
+			"value": {},
+			"count": {Type: types.TypeOfInteger, Format: types.FormatOfInt64},
+		},
+	}
+
+	o.Paths[fmt.Sprintf("%v/_distinct/{column}", pattern)] = &types.Path{
+		Get: &types.Operation{
+			Tags:        childPath.Get.Tags,
+			OperationID: fmt.Sprintf("Get%vDistinctValues", childPath.Get.Tags[0]),
+			Parameters:  parameters,
+			Responses: getObjectsResponses(
+				fmt.Sprintf("Distinct Values for %v", childPath.Get.Tags[0]),
+				&types.Schema{Ref: "#/components/schemas/DistinctValue"},
+			),
+		},
+	}
+
+	return nil
+}
+
+// extendOpenAPI documents the things the handlers support that openapi.NewFromIntrospectedSchema doesn't know about
+func extendOpenAPI(o *types.OpenAPI) error {
+	patterns := maps.Keys(getRouterFnByPattern)
+	slices.Sort(patterns)
+
+	filterParametersByTable := make(map[string][]*types.Parameter)
+
+	for _, pattern := range patterns {
+		path := o.Paths[pattern]
+		if path == nil || path.Get == nil {
+			return fmt.Errorf("failed to find list endpoint for %v in OpenAPI schema", pattern)
+		}
+
+		path.Get.Parameters = append(path.Get.Parameters, getTypedFilterParameters(tableNameByPattern[pattern])...)
+
+		filterParametersByTable[tableNameByPattern[pattern]] = slices.Clone(path.Get.Parameters)
+	}
+
+	for _, pattern := range patterns {
+		path := o.Paths[pattern]
+
+		path.Get.Parameters = append(path.Get.Parameters, getForeignKeyParameters(tableNameByPattern[pattern], filterParametersByTable)...)
+		path.Get.Parameters = append(path.Get.Parameters, listParameters...)
+
+		_, ok := searchColumnsByTable[tableNameByPattern[pattern]]
+		if ok {
+			path.Get.Parameters = append(path.Get.Parameters, searchParameter)
+		}
+
+		if hasGeometricColumns(tableNameByPattern[pattern]) {
+			path.Get.Parameters = append(path.Get.Parameters, distanceFromParameter)
+		}
+
+		if tableNameByPattern[pattern] == djangolang_example.LocationHistoryTable {
+			path.Get.Parameters = append(path.Get.Parameters, reductionParameters...)
+		}
+
+		response := path.Get.Responses[fmt.Sprintf("%v", http.StatusOK)]
+		if response == nil || response.Content[contentTypeApplicationJSON] == nil {
+			return fmt.Errorf("failed to find list response for %v in OpenAPI schema", pattern)
+		}
+
+		for name, schema := range listResponseProperties {
+			response.Content[contentTypeApplicationJSON].Schema.Properties[name] = schema
+		}
+
+		if slices.Contains(geoJSONTables, tableNameByPattern[pattern]) {
+			err := addGeoJSONContent(o, pattern)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := addAggregateEndpoint(o, pattern)
+		if err != nil {
+			return err
+		}
+
+		err = addSeriesEndpoint(o, pattern)
+		if err != nil {
+			return err
+		}
+
+		err = addDistinctEndpoint(o, pattern)
+		if err != nil {
+			return err
+		}
+
+		if hasDeletedAt(tableNameByPattern[pattern]) {
+			path.Get.Parameters = append(path.Get.Parameters, deletedParameters...)
+
+			itemPath := o.Paths[fmt.Sprintf("%v/{primaryKey}", pattern)]
+			if itemPath == nil || itemPath.Get == nil {
+				return fmt.Errorf("failed to find item endpoint for %v in OpenAPI schema", pattern)
+			}
+
+			itemPath.Get.Parameters = append(itemPath.Get.Parameters, deletedParameters...)
+		}
+	}
+
+	for _, endpoint := range nestedListEndpoints {
+		childOperation := o.Paths[endpoint.childPattern].Get
+
+		parameters := slices.Clone(childOperation.Parameters)
+
+		if endpoint.parentPattern != "" {
+			parentPath := o.Paths[fmt.Sprintf("%v/{primaryKey}", endpoint.parentPattern)]
+			if parentPath == nil || parentPath.Get == nil || len(parentPath.Get.Parameters) == 0 {
+				return fmt.Errorf("failed to find item endpoint for %v in OpenAPI schema", endpoint.parentPattern)
+			}
+
+			primaryKeyParameter := *parentPath.Get.Parameters[0]
+			primaryKeyParameter.Description = fmt.Sprintf("%v (matched against %v)", primaryKeyParameter.Description, endpoint.column)
+
+			parameters = append([]*types.Parameter{&primaryKeyParameter}, parameters...)
+		}
+
+		o.Paths[endpoint.pattern] = &types.Path{
+			Get: &types.Operation{
+				Tags:        childOperation.Tags,
+				OperationID: endpoint.operationID,
+				Parameters:  parameters,
+				Responses:   childOperation.Responses,
+			},
+		}
+	}
+
+	err := addLocationEndpoint(o)
+	if err != nil {
+		return err
+	}
+
+	err = addTrackEndpoint(o)
+	if err != nil {
+		return err
+	}
+
+	err = addContactEndpoints(o)
+	if err != nil {
+		return err
+	}
+
+	err = addGridEndpoint(o)
+	if err != nil {
+		return err
+	}
+
+	err = addTileEndpoint(o)
+	if err != nil {
+		return err
+	}
+
+	err = addLocationHistoryFileEndpoints(o)
+	if err != nil {
+		return err
+	}
+
+	err = addRestoreEndpoint(o)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
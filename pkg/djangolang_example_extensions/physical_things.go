@@ -0,0 +1,92 @@
+package extensions
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+func getPhysicalThingRouter(db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, fallback http.HandlerFunc) chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.PhysicalThingTable, SelectPhysicalThingsWithOptions, nil, nil)
+	})
+
+	r.Get("/_aggregate", func(w http.ResponseWriter, r *http.Request) {
+		handleGetAggregates(w, r, db, redisConn, modelMiddlewares, djangolang_example.PhysicalThingTable)
+	})
+
+	r.Get("/_series", func(w http.ResponseWriter, r *http.Request) {
+		handleGetSeries(w, r, db, redisConn, modelMiddlewares, djangolang_example.PhysicalThingTable)
+	})
+
+	r.Get("/_distinct/{column}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetDistinctValues(w, r, db, redisConn, modelMiddlewares, djangolang_example.PhysicalThingTable, chi.URLParam(r, "column"))
+	})
+
+	r.Get("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		if !handleGetItem(w, r, db, redisConn, djangolang_example.PhysicalThingTable, SelectPhysicalThingsWithOptions, chi.URLParam(r, "primaryKey")) {
+			fallback(w, r)
+		}
+	})
+
+	r.Get("/{primaryKey}/logical-things", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.LogicalThingTable, SelectLogicalThingsWithOptions, []string{fmt.Sprintf("%s = $$??", djangolang_example.LogicalThingTableParentPhysicalThingIDColumn)}, []any{chi.URLParam(r, "primaryKey")})
+	})
+
+	r.Get("/{primaryKey}/location-histories", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.LocationHistoryTable, SelectLocationHistorysWithOptions, []string{fmt.Sprintf("%s = $$??", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)}, []any{chi.URLParam(r, "primaryKey")})
+	})
+
+	r.Get("/{primaryKey}/location-histories/export", func(w http.ResponseWriter, r *http.Request) {
+		handleGetPhysicalThingLocationHistoryExport(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Get("/{primaryKey}/track", func(w http.ResponseWriter, r *http.Request) {
+		handleGetPhysicalThingTrack(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Get("/{primaryKey}/contacts", func(w http.ResponseWriter, r *http.Request) {
+		handleGetPhysicalThingContacts(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Get("/{primaryKey}/location", func(w http.ResponseWriter, r *http.Request) {
+		handleGetPhysicalThingLocation(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Get("/{primaryKey}/geofence-events", func(w http.ResponseWriter, r *http.Request) {
+		handleGetList(w, r, db, redisConn, djangolang_example.GeofenceEventTable, SelectGeofenceEventsWithOptions, []string{fmt.Sprintf("%s = $$??", djangolang_example.GeofenceEventTablePhysicalThingIDColumn)}, []any{chi.URLParam(r, "primaryKey")})
+	})
+
+	r.Post("/{primaryKey}/location-histories/import", func(w http.ResponseWriter, r *http.Request) {
+		handlePostPhysicalThingLocationHistoryImport(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Post("/{primaryKey}/restore", func(w http.ResponseWriter, r *http.Request) {
+		handlePostPhysicalThingRestore(w, r, db, redisConn, modelMiddlewares, chi.URLParam(r, "primaryKey"))
+	})
+
+	r.Delete("/{primaryKey}", func(w http.ResponseWriter, r *http.Request) {
+		hardDelete, err := parseBoolParam(r, "hard")
+		if err != nil {
+			helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !hardDelete {
+			fallback(w, r)
+			return
+		}
+
+		handleDeletePhysicalThingHard(w, r, db, chi.URLParam(r, "primaryKey"))
+	})
+
+	return r
+}
@@ -0,0 +1,511 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultContactWindow is how far apart in time two points can be and still count as the physical things being together,
+// unless otherwise specified by the window param
+const defaultContactWindow = time.Minute
+
+// defaultContactGap is how long a contact can go without any points together before it counts as a new contact, unless
+// otherwise specified by the gap param
+const defaultContactGap = time.Minute * 5
+
+// Contact summarises the time a physical thing spent near a point (or near another physical thing); duration is in
+// seconds and points is how many location_history rows were in contact
+type Contact struct {
+	PhysicalThingID       uuid.UUID                         `json:"physical_thing_id"`
+	PhysicalThingIDObject *djangolang_example.PhysicalThing `json:"physical_thing_id_object"`
+	FirstContact          time.Time                         `json:"first_contact"`
+	LastContact           time.Time                         `json:"last_contact"`
+	Duration              float64                           `json:"duration"`
+	Points                int64                             `json:"points"`
+}
+
+// ContactOptions controls what counts as two physical things being together; Radius is in metres (with the points taken
+// to be longitude / latitude) and a contact has to last at least MinDuration (without a break of more than Gap) to be returned
+type ContactOptions struct {
+	Radius      float64
+	Window      time.Duration
+	Gap         time.Duration
+	MinDuration time.Duration
+}
+
+// loadContactPhysicalThings sets the PhysicalThingIDObject of each of the contacts (using one query)
+func loadContactPhysicalThings(ctx context.Context, tx *sqlx.Tx, contacts []*Contact) error {
+	physicalThingIDs := make([]uuid.UUID, 0)
+	for _, contact := range contacts {
+		physicalThingIDs = append(physicalThingIDs, contact.PhysicalThingID)
+	}
+
+	physicalThingByID, err := loadForeignObjects(
+		physicalThingIDs,
+		djangolang_example.PhysicalThingTablePrimaryKeyColumn,
+		func(where string, values ...any) ([]*djangolang_example.PhysicalThing, error) {
+			return SelectPhysicalThingsWithOptions(ctx, tx, where, SelectOptions{}, values...)
+		},
+		func(object *djangolang_example.PhysicalThing) uuid.UUID {
+			return object.ID
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load PhysicalThingIDObject for contacts; err: %v", err)
+	}
+
+	for _, contact := range contacts {
+		contact.PhysicalThingIDObject = physicalThingByID[contact.PhysicalThingID]
+	}
+
+	return nil
+}
+
+func getTimeWindowWheres(qualifier string, from *time.Time, to *time.Time) ([]string, []any) {
+	wheres := make([]string, 0)
+	values := make([]any, 0)
+
+	if from != nil {
+		wheres = append(wheres, fmt.Sprintf("%s >= $$??", formatColumn(qualifier, djangolang_example.LocationHistoryTableTimestampColumn)))
+		values = append(values, *from)
+	}
+
+	if to != nil {
+		wheres = append(wheres, fmt.Sprintf("%s < $$??", formatColumn(qualifier, djangolang_example.LocationHistoryTableTimestampColumn)))
+		values = append(values, *to)
+	}
+
+	return wheres, values
+}
+
+func getProximityWheres(point pgtype.Vec2, radius float64, from *time.Time, to *time.Time) ([]string, []any) {
+	wheres := []string{
+		fmt.Sprintf("%s IS NOT null", formatColumn("", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)),
+		formatWithinMetresSQL(
+			formatColumn("", djangolang_example.LocationHistoryTablePointColumn),
+			fmt.Sprintf("point(%s, %s)", formatFloat(point.X), formatFloat(point.Y)),
+			radius,
+		),
+	}
+
+	values := make([]any, 0)
+
+	timeWheres, timeValues := getTimeWindowWheres("", from, to)
+
+	return append(wheres, timeWheres...), append(values, timeValues...)
+}
+
+// GetProximityContacts returns a Contact for each physical thing that had a point within radius metres of the given point
+// between from (inclusive) and to (exclusive), either of which may be nil to leave that end of the window open; the
+// contacts are in order of first contact and their duration is from the first to the last point in range
+func GetProximityContacts(ctx context.Context, tx *sqlx.Tx, point pgtype.Vec2, radius float64, from *time.Time, to *time.Time) ([]*Contact, error) {
+	wheres, values := getProximityWheres(point, radius, from, to)
+
+	wheres = append(wheres, fmt.Sprintf("%s IS null", formatColumn("", djangolang_example.LocationHistoryTableDeletedAtColumn)))
+
+	sql := fmt.Sprintf(
+		`SELECT
+    %s,
+    min(%s),
+    max(%s),
+    count(*)
+FROM
+    %s
+WHERE
+    %s
+GROUP BY
+    %s
+ORDER BY
+    2 ASC, 1 ASC;`,
+		formatColumn("", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+		formatColumn("", djangolang_example.LocationHistoryTableTimestampColumn),
+		formatColumn("", djangolang_example.LocationHistoryTableTimestampColumn),
+		query.FormatObjectName(djangolang_example.LocationHistoryTable),
+		strings.Join(wheres, "\n    AND "),
+		formatColumn("", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+	)
+
+	contacts := make([]*Contact, 0)
+
+	err := queryRows(ctx, tx, sql, values, func(rows *sqlx.Rows) error {
+		contact := &Contact{}
+
+		err := rows.Scan(&contact.PhysicalThingID, &contact.FirstContact, &contact.LastContact, &contact.Points)
+		if err != nil {
+			return err
+		}
+
+		contact.Duration = contact.LastContact.Sub(contact.FirstContact).Seconds()
+		contacts = append(contacts, contact)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadContactPhysicalThings(ctx, tx, contacts)
+	if err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+func getContactWheres(physicalThingID any, from *time.Time, to *time.Time) ([]string, []any) {
+	wheres := []string{
+		fmt.Sprintf("%s = $$??", formatColumn("__self", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)),
+		fmt.Sprintf("%s IS NOT null", formatColumn("__self", djangolang_example.LocationHistoryTablePointColumn)),
+	}
+
+	values := []any{physicalThingID}
+
+	timeWheres, timeValues := getTimeWindowWheres("__self", from, to)
+
+	return append(wheres, timeWheres...), append(values, timeValues...)
+}
+
+// getContactEpisodes splits times (which must be in order) wherever consecutive times are more than gap apart
+func getContactEpisodes(times []time.Time, gap time.Duration) [][]time.Time {
+	episodes := make([][]time.Time, 0)
+
+	start := 0
+	for i := 1; i <= len(times); i++ {
+		if i < len(times) && times[i].Sub(times[i-1]) <= gap {
+			continue
+		}
+
+		episodes = append(episodes, times[start:i])
+		start = i
+	}
+
+	return episodes
+}
+
+// getContacts turns the times (in order) that each of the other physical things was near the physical thing into a
+// Contact for each of them that lasted at least options.MinDuration (in order of first contact)
+func getContacts(physicalThingIDs []uuid.UUID, timesByPhysicalThingID map[uuid.UUID][]time.Time, options ContactOptions) []*Contact {
+	contacts := make([]*Contact, 0)
+
+	for _, otherPhysicalThingID := range physicalThingIDs {
+		times := timesByPhysicalThingID[otherPhysicalThingID]
+
+		longest := time.Duration(0)
+		for _, episode := range getContactEpisodes(times, options.Gap) {
+			longest = max(longest, episode[len(episode)-1].Sub(episode[0]))
+		}
+
+		if longest < options.MinDuration {
+			continue
+		}
+
+		contacts = append(contacts, &Contact{
+			PhysicalThingID: otherPhysicalThingID,
+			FirstContact:    times[0],
+			LastContact:     times[len(times)-1],
+			Duration:        longest.Seconds(),
+			Points:          int64(len(times)),
+		})
+	}
+
+	slices.SortStableFunc(contacts, func(a *Contact, b *Contact) int {
+		return a.FirstContact.Compare(b.FirstContact)
+	})
+
+	return contacts
+}
+
+// GetContacts returns a Contact for each physical thing that was co-located with the given physical thing (as per
+// options) between from (inclusive) and to (exclusive), either of which may be nil to leave that end of the window open;
+// the contacts are in order of first contact and their duration is that of the longest unbroken period together
+func GetContacts(ctx context.Context, tx *sqlx.Tx, physicalThingID uuid.UUID, options ContactOptions, from *time.Time, to *time.Time) ([]*Contact, error) {
+	wheres, values := getContactWheres(physicalThingID, from, to)
+
+	wheres = append(
+		wheres,
+		fmt.Sprintf("%s IS null", formatColumn("__self", djangolang_example.LocationHistoryTableDeletedAtColumn)),
+		fmt.Sprintf("%s IS null", formatColumn("__other", djangolang_example.LocationHistoryTableDeletedAtColumn)),
+		fmt.Sprintf("%s IS NOT null", formatColumn("__other", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn)),
+	)
+
+	// note: the join conditions are written so that the point and timestamp indexes on location_history can be used to
+	// find the other points near each of the points of the physical thing
+	sql := fmt.Sprintf(
+		`SELECT DISTINCT
+    %s,
+    %s
+FROM
+    %s AS "__self"
+    JOIN %s AS "__other" ON %s != %s
+        AND %s BETWEEN %s - make_interval(secs => $$??) AND %s + make_interval(secs => $$??)
+        AND %s
+WHERE
+    %s
+ORDER BY
+    1 ASC, 2 ASC;`,
+		formatColumn("__other", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+		formatColumn("__self", djangolang_example.LocationHistoryTableTimestampColumn),
+		query.FormatObjectName(djangolang_example.LocationHistoryTable),
+		query.FormatObjectName(djangolang_example.LocationHistoryTable),
+		formatColumn("__other", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+		formatColumn("__self", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+		formatColumn("__other", djangolang_example.LocationHistoryTableTimestampColumn),
+		formatColumn("__self", djangolang_example.LocationHistoryTableTimestampColumn),
+		formatColumn("__self", djangolang_example.LocationHistoryTableTimestampColumn),
+		formatWithinMetresSQL(
+			formatColumn("__other", djangolang_example.LocationHistoryTablePointColumn),
+			formatColumn("__self", djangolang_example.LocationHistoryTablePointColumn),
+			options.Radius,
+		),
+		strings.Join(wheres, "\n    AND "),
+	)
+
+	// the placeholders in the JOIN come before those in the WHERE
+	values = append([]any{options.Window.Seconds(), options.Window.Seconds()}, values...)
+
+	timesByPhysicalThingID := make(map[uuid.UUID][]time.Time)
+	physicalThingIDs := make([]uuid.UUID, 0)
+
+	err := queryRows(ctx, tx, sql, values, func(rows *sqlx.Rows) error {
+		var otherPhysicalThingID uuid.UUID
+		var timestamp time.Time
+
+		err := rows.Scan(&otherPhysicalThingID, &timestamp)
+		if err != nil {
+			return err
+		}
+
+		_, ok := timesByPhysicalThingID[otherPhysicalThingID]
+		if !ok {
+			physicalThingIDs = append(physicalThingIDs, otherPhysicalThingID)
+		}
+
+		timesByPhysicalThingID[otherPhysicalThingID] = append(timesByPhysicalThingID[otherPhysicalThingID], timestamp)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contacts := getContacts(physicalThingIDs, timesByPhysicalThingID, options)
+
+	err = loadContactPhysicalThings(ctx, tx, contacts)
+	if err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+func parseNonNegativeFloat(r *http.Request, param string, required bool) (float64, error) {
+	rawValue := r.URL.Query().Get(param)
+	if rawValue == "" {
+		if required {
+			return 0, fmt.Errorf("param %s is required", param)
+		}
+
+		return 0, nil
+	}
+
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("failed to parse param %s=%s as non-negative number: %v", param, rawValue, err)
+	}
+
+	return value, nil
+}
+
+func parseNonNegativeDuration(r *http.Request, param string, defaultValue time.Duration) (time.Duration, error) {
+	rawValue := r.URL.Query().Get(param)
+	if rawValue == "" {
+		return defaultValue, nil
+	}
+
+	value, err := time.ParseDuration(rawValue)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("failed to parse param %s=%s as non-negative duration: %v", param, rawValue, err)
+	}
+
+	return value, nil
+}
+
+// handleContactsResponse serves the contacts from getContacts, caching them under the given request hash (as per
+// getDerivedRequestHash)
+func handleContactsResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	db *sqlx.DB,
+	redisConn redis.Conn,
+	requestHash string,
+	getContacts func(ctx context.Context, tx *sqlx.Tx) ([]*Contact, error),
+) {
+	ctx := r.Context()
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	contacts, err := getContacts(WithExpansionDepth(ctx, 0), tx)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, contacts)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+func handleGetLocationHistoryProximity(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware) {
+	rawPoint := r.URL.Query().Get("point")
+	if rawPoint == "" {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("param point is required"))
+		return
+	}
+
+	vs, err := parseFloats(rawPoint, 2)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse param point=%s: %v", rawPoint, err))
+		return
+	}
+
+	point := pgtype.Vec2{X: vs[0], Y: vs[1]}
+
+	radius, err := parseNonNegativeFloat(r, "radius", true)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	from, err := parseTrackTime(r, "from")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	to, err := parseTrackTime(r, "to")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	wheres, values := getProximityWheres(point, radius, from, to)
+
+	requestHash, err := getDerivedRequestHash(djangolang_example.LocationHistoryTable, wheres, values, "PROXIMITY")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handleContactsResponse(w, r, db, redisConn, requestHash, func(ctx context.Context, tx *sqlx.Tx) ([]*Contact, error) {
+		return GetProximityContacts(ctx, tx, point, radius, from, to)
+	})
+}
+
+func handleGetPhysicalThingContacts(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	physicalThingID, err := uuid.Parse(primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse primary key %s: %v", primaryKey, err))
+		return
+	}
+
+	options := ContactOptions{}
+
+	options.Radius, err = parseNonNegativeFloat(r, "radius", true)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	options.Window, err = parseNonNegativeDuration(r, "window", defaultContactWindow)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	options.Gap, err = parseNonNegativeDuration(r, "gap", defaultContactGap)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	options.MinDuration, err = parseNonNegativeDuration(r, "min_duration", 0)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	from, err := parseTrackTime(r, "from")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	to, err := parseTrackTime(r, "to")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	wheres, values := getContactWheres(physicalThingID, from, to)
+
+	requestHash, err := getDerivedRequestHash(
+		djangolang_example.LocationHistoryTable,
+		wheres,
+		values,
+		"CONTACTS",
+		fmt.Sprintf("RADIUS %v", options.Radius),
+		fmt.Sprintf("WINDOW %v", options.Window),
+		fmt.Sprintf("GAP %v", options.Gap),
+		fmt.Sprintf("MIN DURATION %v", options.MinDuration),
+	)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handleContactsResponse(w, r, db, redisConn, requestHash, func(ctx context.Context, tx *sqlx.Tx) ([]*Contact, error) {
+		return GetContacts(ctx, tx, physicalThingID, options, from, to)
+	})
+}
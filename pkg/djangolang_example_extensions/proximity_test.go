@@ -0,0 +1,138 @@
+package extensions
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGetContactEpisodes(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2024, 1, 2, 3, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name     string
+		times    []time.Time
+		expected [][]time.Time
+	}{
+		{name: "none", times: []time.Time{}, expected: [][]time.Time{}},
+		{name: "one", times: []time.Time{at(0)}, expected: [][]time.Time{{at(0)}}},
+		{name: "gap at the limit", times: []time.Time{at(0), at(5), at(10)}, expected: [][]time.Time{{at(0), at(5), at(10)}}},
+		{name: "gap over the limit", times: []time.Time{at(0), at(1), at(7), at(8), at(20)}, expected: [][]time.Time{{at(0), at(1)}, {at(7), at(8)}, {at(20)}}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			episodes := getContactEpisodes(testCase.times, time.Minute*5)
+			if !reflect.DeepEqual(episodes, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, episodes)
+			}
+		})
+	}
+}
+
+func TestGetContacts(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2024, 1, 2, 3, minute, 0, 0, time.UTC)
+	}
+
+	a := uuid.New()
+	b := uuid.New()
+	c := uuid.New()
+
+	timesByPhysicalThingID := map[uuid.UUID][]time.Time{
+		// two short episodes that are each under the minimum but together would be over it
+		a: {at(0), at(1), at(2), at(10), at(11), at(12)},
+		// one long episode that starts before that of c
+		b: {at(5), at(6), at(7), at(8)},
+		// the same first contact as b, but sorted after it because it was found after it
+		c: {at(5), at(9)},
+	}
+
+	testCases := []struct {
+		name        string
+		minDuration time.Duration
+		expected    []*Contact
+	}{
+		{
+			name: "no minimum",
+			expected: []*Contact{
+				{PhysicalThingID: a, FirstContact: at(0), LastContact: at(12), Duration: 120, Points: 6},
+				{PhysicalThingID: b, FirstContact: at(5), LastContact: at(8), Duration: 180, Points: 4},
+				{PhysicalThingID: c, FirstContact: at(5), LastContact: at(9), Duration: 240, Points: 2},
+			},
+		},
+		{
+			name:        "minimum is of the longest episode",
+			minDuration: time.Minute * 3,
+			expected: []*Contact{
+				{PhysicalThingID: b, FirstContact: at(5), LastContact: at(8), Duration: 180, Points: 4},
+				{PhysicalThingID: c, FirstContact: at(5), LastContact: at(9), Duration: 240, Points: 2},
+			},
+		},
+		{
+			name:        "minimum over all of them",
+			minDuration: time.Hour,
+			expected:    []*Contact{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			contacts := getContacts(
+				[]uuid.UUID{b, c, a},
+				timesByPhysicalThingID,
+				ContactOptions{Gap: defaultContactGap, MinDuration: testCase.minDuration},
+			)
+
+			if !reflect.DeepEqual(contacts, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, contacts)
+			}
+		})
+	}
+}
+
+func TestParseNonNegativeDuration(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rawQuery  string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{name: "default", rawQuery: "", expected: defaultContactGap},
+		{name: "given", rawQuery: "gap=90s", expected: time.Second * 90},
+		{name: "zero", rawQuery: "gap=0s", expected: 0},
+		{name: "negative", rawQuery: "gap=-1m", expectErr: true},
+		{name: "not a duration", rawQuery: "gap=soon", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/physical-things/x/contacts?"+testCase.rawQuery, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			d, err := parseNonNegativeDuration(r, "gap", defaultContactGap)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %v", d)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if d != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, d)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+// HardDeleteAuthorizer decides whether the given request may permanently delete rows of the given table, returning an
+// error if not
+type HardDeleteAuthorizer func(r *http.Request, table string) error
+
+var hardDeleteAuthorizer HardDeleteAuthorizer
+
+// SetHardDeleteAuthorizer sets the hook that gates DELETE ?hard=true; until it's set, no request may hard-delete
+func SetHardDeleteAuthorizer(authorizer HardDeleteAuthorizer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	hardDeleteAuthorizer = authorizer
+}
+
+func authorizeHardDelete(r *http.Request, table string) error {
+	mu.Lock()
+	authorizer := hardDeleteAuthorizer
+	mu.Unlock()
+
+	if authorizer == nil {
+		return fmt.Errorf("not authorized to hard-delete %s; no authorizer is set", table)
+	}
+
+	err := authorizer(r, table)
+	if err != nil {
+		return fmt.Errorf("not authorized to hard-delete %s: %v", table, err)
+	}
+
+	return nil
+}
+
+// hardDeleteDependent is a foreign key to a table that a hard delete has to deal with before it can remove the rows it
+// refers to; the referring rows are either hard-deleted too or (if detach) have the reference nulled
+type hardDeleteDependent struct {
+	foreignKey
+	detach bool
+}
+
+// hardDeleteDependentsByTable are the dependents of each table, in the order they're dealt with; the deletes match what
+// the delete_*_cascade_* rules do for a soft delete
+var hardDeleteDependentsByTable = map[string][]hardDeleteDependent{
+	djangolang_example.PhysicalThingTable: {
+		{foreignKey: foreignKey{table: djangolang_example.GeofenceEventTable, column: djangolang_example.GeofenceEventTablePhysicalThingIDColumn}},
+		{foreignKey: foreignKey{table: djangolang_example.LogicalThingTable, column: djangolang_example.LogicalThingTableParentPhysicalThingIDColumn}},
+		{foreignKey: foreignKey{table: djangolang_example.LocationHistoryTable, column: djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn}},
+	},
+	djangolang_example.LogicalThingTable: {
+		{foreignKey: foreignKey{table: djangolang_example.LogicalThingTable, column: djangolang_example.LogicalThingTableParentLogicalThingIDColumn}, detach: true},
+	},
+	djangolang_example.LocationHistoryTable: {
+		{foreignKey: foreignKey{table: djangolang_example.GeofenceEventTable, column: djangolang_example.GeofenceEventTableLocationHistoryIDColumn}, detach: true},
+	},
+	djangolang_example.GeofenceTable: {
+		{foreignKey: foreignKey{table: djangolang_example.GeofenceEventTable, column: djangolang_example.GeofenceEventTableGeofenceIDColumn}},
+	},
+}
+
+// PurgeReport is how many rows (by table) a hard delete / purge removed, and how many had a reference to a removed row
+// nulled; for a dry run, how many it would have (where the detached rows may include some that are removed anyway)
+type PurgeReport struct {
+	DeletedByTable  map[string]int64 `json:"deleted_by_table"`
+	DetachedByTable map[string]int64 `json:"detached_by_table"`
+}
+
+func newPurgeReport() *PurgeReport {
+	return &PurgeReport{
+		DeletedByTable:  make(map[string]int64),
+		DetachedByTable: make(map[string]int64),
+	}
+}
+
+// enableHardDelete turns off the soft-delete rules (see migration 00005) for the rest of the transaction, so that a
+// DELETE really deletes; it's only ever SET LOCAL (and only on a transaction), so that it ends with the transaction
+// rather than staying on the connection for whatever the pool uses it for next
+func enableHardDelete(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "SET LOCAL djangolang.hard_delete = 'on';")
+	if err != nil {
+		return fmt.Errorf("failed to enable hard delete: %v", err)
+	}
+
+	return nil
+}
+
+func execCount(ctx context.Context, tx *sqlx.Tx, sql string, values []any, dryRun bool) (int64, error) {
+	sql = formatPlaceholders(sql)
+
+	logQuery(sql, values)
+
+	if dryRun {
+		var count int64
+
+		err := tx.QueryRowxContext(ctx, sql, values...).Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+
+		return count, nil
+	}
+
+	result, err := tx.ExecContext(ctx, sql, values...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// hardDeleteRows removes the rows of the given table matching the where, along with their dependents (depth-first); for a
+// dry run it only counts them
+func hardDeleteRows(ctx context.Context, tx *sqlx.Tx, table string, where string, values []any, dryRun bool, report *PurgeReport) error {
+	for _, dependent := range hardDeleteDependentsByTable[table] {
+		referenced := foreignKeysByTable[dependent.table][dependent.column]
+
+		dependentWhere := fmt.Sprintf(
+			"%s IN (SELECT %s FROM %s WHERE %s)",
+			formatColumn("", dependent.column),
+			formatColumn("", referenced.column),
+			query.FormatObjectName(table),
+			where,
+		)
+
+		if !dependent.detach {
+			err := hardDeleteRows(ctx, tx, dependent.table, dependentWhere, values, dryRun, report)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		sql := fmt.Sprintf("UPDATE %s SET %s = null WHERE %s;", query.FormatObjectName(dependent.table), formatColumn("", dependent.column), dependentWhere)
+		if dryRun {
+			sql = fmt.Sprintf("SELECT count(*) FROM %s WHERE %s;", query.FormatObjectName(dependent.table), dependentWhere)
+		}
+
+		count, err := execCount(ctx, tx, sql, values, dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to detach %s.%s: %v", dependent.table, dependent.column, err)
+		}
+
+		report.DetachedByTable[dependent.table] += count
+	}
+
+	sql := fmt.Sprintf("DELETE FROM %s WHERE %s;", query.FormatObjectName(table), where)
+	if dryRun {
+		sql = fmt.Sprintf("SELECT count(*) FROM %s WHERE %s;", query.FormatObjectName(table), where)
+	}
+
+	count, err := execCount(ctx, tx, sql, values, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to hard-delete from %s: %v", table, err)
+	}
+
+	report.DeletedByTable[table] += count
+
+	return nil
+}
+
+// HardDeletePhysicalThing permanently removes the given physical thing (whether or not it's soft-deleted), along with its
+// logical things, location history and geofence events
+func HardDeletePhysicalThing(ctx context.Context, tx *sqlx.Tx, physicalThingID uuid.UUID) (*PurgeReport, error) {
+	err := enableHardDelete(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newPurgeReport()
+
+	err = hardDeleteRows(
+		ctx,
+		tx,
+		djangolang_example.PhysicalThingTable,
+		fmt.Sprintf("%s = $$??", formatColumn("", djangolang_example.PhysicalThingTablePrimaryKeyColumn)),
+		[]any{physicalThingID},
+		false,
+		report,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.DeletedByTable[djangolang_example.PhysicalThingTable] == 0 {
+		return nil, fmt.Errorf("failed to find %s %v", djangolang_example.PhysicalThingTable, physicalThingID)
+	}
+
+	return report, nil
+}
+
+// PurgeDeleted permanently removes (up to limit of) the rows of the given table that were soft-deleted before the given
+// time, oldest first, along with their dependents; for a dry run it counts everything that was soft-deleted before the
+// given time (ignoring limit) and removes nothing
+func PurgeDeleted(ctx context.Context, tx *sqlx.Tx, table string, before time.Time, limit int, dryRun bool) (*PurgeReport, error) {
+	_, ok := objectByTableName[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %#+v", table)
+	}
+
+	if !hasDeletedAt(table) {
+		return nil, fmt.Errorf("%s has no soft-deleted rows", table)
+	}
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive but got %v", limit)
+	}
+
+	where := fmt.Sprintf("%s < $$??", formatColumn("", "deleted_at"))
+
+	if !dryRun {
+		err := enableHardDelete(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		// note: ordered by id as well so that each of the statements of the purge sees the same batch
+		where = fmt.Sprintf(
+			"%s IN (SELECT %s FROM %s WHERE %s ORDER BY %s, %s LIMIT %d)",
+			formatColumn("", "id"),
+			formatColumn("", "id"),
+			query.FormatObjectName(table),
+			where,
+			formatColumn("", "deleted_at"),
+			formatColumn("", "id"),
+			limit,
+		)
+	}
+
+	report := newPurgeReport()
+
+	err := hardDeleteRows(ctx, tx, table, where, []any{before}, dryRun, report)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func handleDeletePhysicalThingHard(w http.ResponseWriter, r *http.Request, db *sqlx.DB, primaryKey string) {
+	physicalThingID, err := uuid.Parse(primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse primary key %s: %v", primaryKey, err))
+		return
+	}
+
+	err = authorizeHardDelete(r, djangolang_example.PhysicalThingTable)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusForbidden, err)
+		return
+	}
+
+	tx, err := db.BeginTxx(r.Context(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to begin DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = HardDeletePhysicalThing(r.Context(), tx, physicalThingID)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit DB transaction: %v", err)
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	helpers.HandleObjectsResponse(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,71 @@
+package extensions
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+func TestAuthorizeHardDelete(t *testing.T) {
+	t.Cleanup(func() {
+		SetHardDeleteAuthorizer(nil)
+	})
+
+	testCases := []struct {
+		name       string
+		authorizer HardDeleteAuthorizer
+		expectErr  bool
+	}{
+		{name: "no authorizer", expectErr: true},
+		{name: "failing authorizer", authorizer: func(r *http.Request, table string) error { return fmt.Errorf("denied") }, expectErr: true},
+		{name: "authorizer", authorizer: func(r *http.Request, table string) error { return nil }},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			SetHardDeleteAuthorizer(testCase.authorizer)
+
+			r, err := http.NewRequest(http.MethodDelete, "/physical-things/x?hard=true", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = authorizeHardDelete(r, djangolang_example.PhysicalThingTable)
+			if testCase.expectErr != (err != nil) {
+				t.Fatalf("expected error %v but got %v", testCase.expectErr, err)
+			}
+		})
+	}
+}
+
+// TestHardDeleteDependentsByTable checks that the dependents line up with the foreign keys, so that a hard delete can't
+// leave rows referring to the rows it removes
+func TestHardDeleteDependentsByTable(t *testing.T) {
+	for referringTable, foreignKeyByColumn := range foreignKeysByTable {
+		for column, fk := range foreignKeyByColumn {
+			found := false
+
+			for _, dependent := range hardDeleteDependentsByTable[fk.table] {
+				if dependent.table == referringTable && dependent.column == column {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				t.Errorf("expected %s.%s (referring to %s) to be a dependent of %s", referringTable, column, fk.table, fk.table)
+			}
+		}
+	}
+
+	for table, dependents := range hardDeleteDependentsByTable {
+		for _, dependent := range dependents {
+			fk, ok := foreignKeysByTable[dependent.table][dependent.column]
+			if !ok || fk.table != table {
+				t.Errorf("expected %s.%s to be a foreign key to %s", dependent.table, dependent.column, table)
+			}
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/introspect"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/jmoiron/sqlx"
+)
+
+// reservedQueryParams are the list params that aren't column filters
+var reservedQueryParams = map[string]struct{}{
+	"limit":           {},
+	"offset":          {},
+	"order_by":        {},
+	"cursor":          {},
+	"count":           {},
+	"fields":          {},
+	"filter":          {},
+	"depth":           {},
+	"q":               {},
+	"distance_from":   {},
+	"include_deleted": {},
+	"only_deleted":    {},
+}
+
+// isReservedQueryParam is true if rawKey is one of reservedQueryParams or one of the given table-specific params
+func isReservedQueryParam(rawKey string, extraKeys ...string) bool {
+	_, ok := reservedQueryParams[rawKey]
+	if ok {
+		return true
+	}
+
+	return slices.Contains(extraKeys, rawKey)
+}
+
+func getOrderBy(orderBy *string) string {
+	if orderBy == nil || strings.TrimSpace(*orderBy) == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("\nORDER BY\n    %v", strings.TrimSpace(*orderBy))
+}
+
+type orderByColumn struct {
+	Column     string `json:"column"`
+	Descending bool   `json:"descending"`
+}
+
+// parseOrderBy turns something like "-updated_at,name" into columns to order by for the given table; the primary key is
+// always appended as a tiebreaker (unless it's already mentioned) so that pagination is stable, and only scalar columns
+// (i.e. those not in columnKinds) can be given, as cursors can't hold the others
+func parseOrderBy(rawOrderBy string, columnLookup map[string]*introspect.Column, columnKinds map[string]columnKind, primaryKeyColumn string) ([]orderByColumn, error) {
+	orderByColumns := make([]orderByColumn, 0)
+	columns := make([]string, 0)
+
+	for _, rawPart := range strings.Split(rawOrderBy, ",") {
+		// note: a leading + will have come through URL decoding as a space
+		part := strings.TrimSpace(rawPart)
+		if part == "" {
+			continue
+		}
+
+		descending := false
+		if strings.HasPrefix(part, "-") {
+			descending = true
+			part = strings.TrimPrefix(part, "-")
+		} else if strings.HasPrefix(part, "+") {
+			part = strings.TrimPrefix(part, "+")
+		}
+
+		_, ok := columnLookup[part]
+		if !ok && !isPseudoColumn(part) {
+			return nil, fmt.Errorf("unrecognized column %#+v", part)
+		}
+
+		kind, ok := columnKinds[part]
+		if ok {
+			return nil, fmt.Errorf("can't order by %v column %#+v", kind, part)
+		}
+
+		if slices.Contains(columns, part) {
+			return nil, fmt.Errorf("column %#+v given more than once", part)
+		}
+
+		columns = append(columns, part)
+		orderByColumns = append(orderByColumns, orderByColumn{Column: part, Descending: descending})
+	}
+
+	if !slices.Contains(columns, primaryKeyColumn) {
+		orderByColumns = append(orderByColumns, orderByColumn{Column: primaryKeyColumn})
+	}
+
+	return orderByColumns, nil
+}
+
+// formatOrderBy renders the columns as the body of an ORDER BY clause; reverse flips every direction (used to walk
+// backwards from a cursor, relying on Postgres defaulting to NULLS LAST for ASC and NULLS FIRST for DESC) and
+// expressions are used in place of any pseudo-columns (see getOrderByExpressions)
+func formatOrderBy(orderByColumns []orderByColumn, reverse bool, expressions map[string]string) string {
+	orderBys := make([]string, 0)
+
+	for _, orderByColumn := range orderByColumns {
+		direction := "ASC"
+		if orderByColumn.Descending != reverse {
+			direction = "DESC"
+		}
+
+		expression, ok := expressions[orderByColumn.Column]
+		if !ok {
+			expression = query.FormatObjectName(orderByColumn.Column)
+		}
+
+		orderBys = append(orderBys, fmt.Sprintf("%v %v", expression, direction))
+	}
+
+	return strings.Join(orderBys, ", ")
+}
+
+// pseudoColumns are the things that may be given in order_by despite not being columns, along with the function that
+// builds the expression (and its values) for each from the request
+var pseudoColumns = map[string]func(table string, query url.Values) (string, []any, error){
+	rankOrderByColumn:     getRankExpression,
+	distanceOrderByColumn: getDistanceExpression,
+}
+
+func isPseudoColumn(column string) bool {
+	_, ok := pseudoColumns[column]
+	return ok
+}
+
+// getOrderByExpressions returns the expressions for any pseudo-columns in the ordering, along with their values (in the
+// order that their placeholders appear in the ORDER BY)
+func getOrderByExpressions(table string, orderByColumns []orderByColumn, query url.Values) (map[string]string, []any, error) {
+	expressions := make(map[string]string)
+	values := make([]any, 0)
+
+	for _, orderByColumn := range orderByColumns {
+		getExpression, ok := pseudoColumns[orderByColumn.Column]
+		if !ok {
+			continue
+		}
+
+		expression, expressionValues, err := getExpression(table, query)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		expressions[orderByColumn.Column] = expression
+		values = append(values, expressionValues...)
+	}
+
+	return expressions, values, nil
+}
+
+// getRequestHash is helpers.GetRequestHash with the values substituted into the wheres (helpers.GetRequestHash assumes
+// one value per where, which isn't true for IN or filter expressions) plus any request params that don't end up in the
+// WHERE clause (e.g. ordering), which should be labelled so that they can't be confused for one another
+func getRequestHash(tableName string, wheres []string, limit int, offset int, values []any, primaryKey any, extras ...string) (string, error) {
+	keys := make([]string, 0)
+
+	i := 0
+	for _, where := range wheres {
+		parts := strings.Split(where, "$$??")
+		key := parts[0]
+
+		for _, part := range parts[1:] {
+			if i >= len(values) {
+				return "", fmt.Errorf("failed to build request hash; not enough values %#+v for wheres %#+v", values, wheres)
+			}
+
+			b, err := json.Marshal(values[i])
+			if err != nil {
+				return "", fmt.Errorf("failed to build request hash; failed to marshal %#+v to JSON: %v", values[i], err)
+			}
+
+			// escaped so that a value can't look like a placeholder to helpers.GetRequestHash
+			key += strings.ReplaceAll(string(b), "$", `\u0024`) + part
+			i++
+		}
+
+		keys = append(keys, key)
+	}
+
+	for _, extra := range extras {
+		if extra == "" {
+			continue
+		}
+
+		keys = append(keys, extra)
+	}
+
+	return helpers.GetRequestHash(tableName, keys, limit, offset, nil, primaryKey)
+}
+
+// getDerivedRequestHash is getRequestHash for a response that's derived from the rows of table (e.g. an aggregate or a
+// track) rather than made up of them; it's cached under table so that the CDC change stream, which invalidates the cache
+// entries of a table whenever one of its rows changes, invalidates it too
+func getDerivedRequestHash(table string, wheres []string, values []any, extras ...string) (string, error) {
+	return getRequestHash(table, wheres, 0, 0, values, nil, extras...)
+}
+
+var qualifiedDeletedAtPattern = regexp.MustCompile(`"[^"]+"\."deleted_at"`)
+
+// mentionsDeletedAt is true if where refers to the deleted_at column of the table being selected from (in which case the
+// caller is taken to be handling soft-deletion themselves); references qualified by table or alias (e.g. from foreign
+// key filters) don't count
+func mentionsDeletedAt(where string) bool {
+	return strings.Contains(qualifiedDeletedAtPattern.ReplaceAllString(where, ""), "deleted_at")
+}
+
+// formatPlaceholders numbers the $$?? placeholders in sql
+func formatPlaceholders(sql string) string {
+	i := 1
+	for strings.Contains(sql, "$$??") {
+		sql = strings.Replace(sql, "$$??", fmt.Sprintf("$%d", i), 1)
+		i++
+	}
+
+	return sql
+}
+
+func logQuery(sql string, values []any) {
+	if !helpers.IsDebug() {
+		return
+	}
+
+	rawValues := ""
+
+	for i, v := range values {
+		rawValues += fmt.Sprintf("$%d = %#+v\n", i+1, v)
+	}
+
+	log.Printf("\n\n%s\n\n%s\n", sql, rawValues)
+}
+
+// selectItems is query.Select with support for ORDER BY; any placeholders in orderBy take their values from after those
+// of where
+func selectItems(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	columns []string,
+	table string,
+	where string,
+	orderBy *string,
+	limit *int,
+	offset *int,
+	values ...any,
+) ([]map[string]any, error) {
+	sql := strings.TrimSpace(fmt.Sprintf(
+		"SELECT\n    %v\nFROM\n    %v%v%v%v;",
+		query.JoinObjectNames(query.FormatObjectNames(columns)),
+		query.FormatObjectName(table),
+		query.GetWhere(where),
+		getOrderBy(orderBy),
+		query.GetLimitAndOffset(limit, offset),
+	))
+
+	items := make([]map[string]any, 0)
+
+	err := queryRows(ctx, tx, sql, values, func(rows *sqlx.Rows) error {
+		item := make(map[string]any)
+
+		err := rows.MapScan(item)
+		if err != nil {
+			return err
+		}
+
+		items = append(items, item)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// queryRows runs sql (with $$?? placeholders) and calls scan for each of the rows
+func queryRows(ctx context.Context, tx *sqlx.Tx, sql string, values []any, scan func(*sqlx.Rows) error) error {
+	sql = formatPlaceholders(sql)
+
+	logQuery(sql, values)
+
+	rows, err := tx.QueryxContext(ctx, sql, values...)
+	if err != nil {
+		return fmt.Errorf("failed to call tx.QueryxContext during query; err: %v, sql: %#+v", err, sql)
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return fmt.Errorf("failed to call rows.Scan during query; err: %v, sql: %#+v", err, sql)
+		}
+	}
+
+	return rows.Err()
+}
+
+// countItems returns the number of rows matching where; if estimate is set it returns the planner's estimate instead,
+// which is much cheaper for large tables but may be well off (particularly if the table hasn't been analyzed recently)
+func countItems(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table string,
+	where string,
+	estimate bool,
+	values ...any,
+) (int64, error) {
+	where = formatPlaceholders(where)
+
+	selectExpression := "count(*)"
+	if estimate {
+		selectExpression = "1"
+	}
+
+	sql := strings.TrimSpace(fmt.Sprintf(
+		"SELECT\n    %v\nFROM\n    %v%v;",
+		selectExpression,
+		query.FormatObjectName(table),
+		query.GetWhere(where),
+	))
+
+	if estimate {
+		sql = fmt.Sprintf("EXPLAIN (FORMAT JSON)\n%v", sql)
+	}
+
+	logQuery(sql, values)
+
+	if !estimate {
+		var count int64
+
+		err := tx.QueryRowxContext(ctx, sql, values...).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf(
+				"failed to call tx.QueryRowxContext during Count; err: %v, sql: %#+v",
+				err, sql,
+			)
+		}
+
+		return count, nil
+	}
+
+	var rawPlan []byte
+
+	err := tx.QueryRowxContext(ctx, sql, values...).Scan(&rawPlan)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"failed to call tx.QueryRowxContext during Count; err: %v, sql: %#+v",
+			err, sql,
+		)
+	}
+
+	var plans []struct {
+		Plan struct {
+			PlanRows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+
+	err = json.Unmarshal(rawPlan, &plans)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal plan %#+v during Count; err: %v", string(rawPlan), err)
+	}
+
+	if len(plans) != 1 {
+		return 0, fmt.Errorf("failed to find plan in %#+v during Count", string(rawPlan))
+	}
+
+	return int64(plans[0].Plan.PlanRows), nil
+}
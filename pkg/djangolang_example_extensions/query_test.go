@@ -0,0 +1,171 @@
+package extensions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/initialed85/djangolang/pkg/introspect"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	columnLookup := map[string]*introspect.Column{
+		"id":         {Name: "id"},
+		"name":       {Name: "name"},
+		"updated_at": {Name: "updated_at"},
+		"tags":       {Name: "tags"},
+		"metadata":   {Name: "metadata"},
+		"point":      {Name: "point"},
+	}
+
+	columnKinds := map[string]columnKind{
+		"tags":     columnKindArray,
+		"metadata": columnKindHstore,
+		"point":    columnKindPoint,
+	}
+
+	testCases := []struct {
+		name       string
+		rawOrderBy string
+		expected   []orderByColumn
+		expectErr  bool
+	}{
+		{
+			name:       "empty",
+			rawOrderBy: "",
+			expected:   []orderByColumn{{Column: "id"}},
+		},
+		{
+			name:       "ascending and descending",
+			rawOrderBy: "-updated_at,+name",
+			expected:   []orderByColumn{{Column: "updated_at", Descending: true}, {Column: "name"}, {Column: "id"}},
+		},
+		{
+			name:       "plus decoded as a space",
+			rawOrderBy: " name",
+			expected:   []orderByColumn{{Column: "name"}, {Column: "id"}},
+		},
+		{
+			name:       "primary key already given",
+			rawOrderBy: "-id,name",
+			expected:   []orderByColumn{{Column: "id", Descending: true}, {Column: "name"}},
+		},
+		{
+			name:       "empty parts",
+			rawOrderBy: ",name,,",
+			expected:   []orderByColumn{{Column: "name"}, {Column: "id"}},
+		},
+		{
+			name:       "unrecognized column",
+			rawOrderBy: "colour",
+			expectErr:  true,
+		},
+		{
+			name:       "quoted column",
+			rawOrderBy: `"name"`,
+			expectErr:  true,
+		},
+		{
+			name:       "column given more than once",
+			rawOrderBy: "name,-name",
+			expectErr:  true,
+		},
+		{
+			name:       "array column",
+			rawOrderBy: "tags",
+			expectErr:  true,
+		},
+		{
+			name:       "hstore column",
+			rawOrderBy: "-metadata",
+			expectErr:  true,
+		},
+		{
+			name:       "point column",
+			rawOrderBy: "name,point",
+			expectErr:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			orderByColumns, err := parseOrderBy(testCase.rawOrderBy, columnLookup, columnKinds, "id")
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", orderByColumns)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(orderByColumns, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, orderByColumns)
+			}
+		})
+	}
+}
+
+func TestFormatOrderBy(t *testing.T) {
+	orderByColumns := []orderByColumn{{Column: "updated_at", Descending: true}, {Column: "id"}}
+
+	testCases := []struct {
+		name        string
+		reverse     bool
+		expressions map[string]string
+		expected    string
+	}{
+		{name: "forward", expected: `"updated_at" DESC, "id" ASC`},
+		{name: "reversed", reverse: true, expected: `"updated_at" ASC, "id" DESC`},
+		{
+			name:        "expression in place of a column",
+			expressions: map[string]string{"updated_at": "coalesce(updated_at, created_at)"},
+			expected:    `coalesce(updated_at, created_at) DESC, "id" ASC`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			orderBy := formatOrderBy(orderByColumns, testCase.reverse, testCase.expressions)
+			if orderBy != testCase.expected {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, orderBy)
+			}
+		})
+	}
+}
+
+func TestGetDerivedRequestHash(t *testing.T) {
+	wheres := []string{`"name" = $$??`}
+	values := []any{"a"}
+
+	hash, err := getDerivedRequestHash("physical_things", wheres, values, "AGGREGATE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, err := getRequestHash("physical_things", wheres, 0, 0, values, nil, "AGGREGATE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hash != expected {
+		t.Fatalf("expected %#+v but got %#+v", expected, hash)
+	}
+
+	// the CDC change stream invalidates the cache entries of a table by prefix
+	if !strings.HasPrefix(hash, "physical_things") {
+		t.Fatalf("expected %#+v to be cached under physical_things", hash)
+	}
+
+	other, err := getDerivedRequestHash("physical_things", wheres, values, "SERIES")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if other == hash {
+		t.Fatalf("expected %#+v to differ from %#+v", other, hash)
+	}
+}
@@ -0,0 +1,265 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/initialed85/djangolang/pkg/introspect"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+type foreignKey struct {
+	table  string
+	column string
+}
+
+var columnLookupByTable = map[string]map[string]*introspect.Column{
+	djangolang_example.PhysicalThingTable:   djangolang_example.PhysicalThingTableColumnLookup,
+	djangolang_example.LogicalThingTable:    djangolang_example.LogicalThingTableColumnLookup,
+	djangolang_example.LocationHistoryTable: djangolang_example.LocationHistoryTableColumnLookup,
+	djangolang_example.FuzzTable:            djangolang_example.FuzzTableColumnLookup,
+	djangolang_example.GeofenceTable:        djangolang_example.GeofenceTableColumnLookup,
+	djangolang_example.GeofenceEventTable:   djangolang_example.GeofenceEventTableColumnLookup,
+}
+
+// dataTypeByTable is (for each table) the Postgres data type of each of its columns, as introspected; the generated
+// *TableColumnLookup only knows the names of the columns
+var dataTypeByTable = map[string]map[string]string{
+	djangolang_example.PhysicalThingTable: {
+		djangolang_example.PhysicalThingTableIDColumn:              "uuid",
+		djangolang_example.PhysicalThingTableCreatedAtColumn:       "timestamp with time zone",
+		djangolang_example.PhysicalThingTableUpdatedAtColumn:       "timestamp with time zone",
+		djangolang_example.PhysicalThingTableDeletedAtColumn:       "timestamp with time zone",
+		djangolang_example.PhysicalThingTableExternalIDColumn:      "text",
+		djangolang_example.PhysicalThingTableNameColumn:            "text",
+		djangolang_example.PhysicalThingTableTypeColumn:            "text",
+		djangolang_example.PhysicalThingTableTagsColumn:            "text[]",
+		djangolang_example.PhysicalThingTableMetadataColumn:        "hstore",
+		djangolang_example.PhysicalThingTableRawDataColumn:         "jsonb",
+		djangolang_example.PhysicalThingTableDeletionBatchIDColumn: "bigint",
+	},
+	djangolang_example.LogicalThingTable: {
+		djangolang_example.LogicalThingTableIDColumn:                    "uuid",
+		djangolang_example.LogicalThingTableCreatedAtColumn:             "timestamp with time zone",
+		djangolang_example.LogicalThingTableUpdatedAtColumn:             "timestamp with time zone",
+		djangolang_example.LogicalThingTableDeletedAtColumn:             "timestamp with time zone",
+		djangolang_example.LogicalThingTableExternalIDColumn:            "text",
+		djangolang_example.LogicalThingTableNameColumn:                  "text",
+		djangolang_example.LogicalThingTableTypeColumn:                  "text",
+		djangolang_example.LogicalThingTableTagsColumn:                  "text[]",
+		djangolang_example.LogicalThingTableMetadataColumn:              "hstore",
+		djangolang_example.LogicalThingTableRawDataColumn:               "jsonb",
+		djangolang_example.LogicalThingTableParentPhysicalThingIDColumn: "uuid",
+		djangolang_example.LogicalThingTableParentLogicalThingIDColumn:  "uuid",
+		djangolang_example.LogicalThingTableDeletionBatchIDColumn:       "bigint",
+	},
+	djangolang_example.LocationHistoryTable: {
+		djangolang_example.LocationHistoryTableIDColumn:                    "uuid",
+		djangolang_example.LocationHistoryTableCreatedAtColumn:             "timestamp with time zone",
+		djangolang_example.LocationHistoryTableUpdatedAtColumn:             "timestamp with time zone",
+		djangolang_example.LocationHistoryTableDeletedAtColumn:             "timestamp with time zone",
+		djangolang_example.LocationHistoryTableTimestampColumn:             "timestamp with time zone",
+		djangolang_example.LocationHistoryTablePointColumn:                 "point",
+		djangolang_example.LocationHistoryTablePolygonColumn:               "polygon",
+		djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn: "uuid",
+		djangolang_example.LocationHistoryTableDeletionBatchIDColumn:       "bigint",
+	},
+	djangolang_example.FuzzTable: {
+		djangolang_example.FuzzTableIDColumn:       "uuid",
+		djangolang_example.FuzzTableColumn1Column:  "timestamp without time zone",
+		djangolang_example.FuzzTableColumn2Column:  "timestamp with time zone",
+		djangolang_example.FuzzTableColumn3Column:  "json",
+		djangolang_example.FuzzTableColumn4Column:  "jsonb",
+		djangolang_example.FuzzTableColumn5Column:  "character varying[]",
+		djangolang_example.FuzzTableColumn6Column:  "text[]",
+		djangolang_example.FuzzTableColumn7Column:  "character varying",
+		djangolang_example.FuzzTableColumn8Column:  "text",
+		djangolang_example.FuzzTableColumn9Column:  "smallint[]",
+		djangolang_example.FuzzTableColumn10Column: "integer[]",
+		djangolang_example.FuzzTableColumn11Column: "bigint[]",
+		djangolang_example.FuzzTableColumn12Column: "smallint",
+		djangolang_example.FuzzTableColumn13Column: "integer",
+		djangolang_example.FuzzTableColumn14Column: "bigint",
+		djangolang_example.FuzzTableColumn15Column: "real[]",
+		djangolang_example.FuzzTableColumn16Column: "double precision[]",
+		djangolang_example.FuzzTableColumn17Column: "numeric[]",
+		djangolang_example.FuzzTableColumn18Column: "double precision[]",
+		djangolang_example.FuzzTableColumn19Column: "double precision",
+		djangolang_example.FuzzTableColumn20Column: "real",
+		djangolang_example.FuzzTableColumn21Column: "numeric",
+		djangolang_example.FuzzTableColumn22Column: "double precision",
+		djangolang_example.FuzzTableColumn23Column: "boolean[]",
+		djangolang_example.FuzzTableColumn24Column: "boolean",
+		djangolang_example.FuzzTableColumn25Column: "tsvector",
+		djangolang_example.FuzzTableColumn26Column: "uuid",
+		djangolang_example.FuzzTableColumn27Column: "hstore",
+		djangolang_example.FuzzTableColumn28Column: "point",
+		djangolang_example.FuzzTableColumn29Column: "polygon",
+		djangolang_example.FuzzTableColumn30Column: "geometry",
+		djangolang_example.FuzzTableColumn31Column: "geometry(PointZ)",
+		djangolang_example.FuzzTableColumn32Column: "inet",
+		djangolang_example.FuzzTableColumn33Column: "bytea",
+	},
+	djangolang_example.GeofenceTable: {
+		djangolang_example.GeofenceTableIDColumn:              "uuid",
+		djangolang_example.GeofenceTableCreatedAtColumn:       "timestamp with time zone",
+		djangolang_example.GeofenceTableUpdatedAtColumn:       "timestamp with time zone",
+		djangolang_example.GeofenceTableDeletedAtColumn:       "timestamp with time zone",
+		djangolang_example.GeofenceTableNameColumn:            "text",
+		djangolang_example.GeofenceTablePolygonColumn:         "polygon",
+		djangolang_example.GeofenceTableDeletionBatchIDColumn: "bigint",
+	},
+	djangolang_example.GeofenceEventTable: {
+		djangolang_example.GeofenceEventTableIDColumn:                "uuid",
+		djangolang_example.GeofenceEventTableCreatedAtColumn:         "timestamp with time zone",
+		djangolang_example.GeofenceEventTableUpdatedAtColumn:         "timestamp with time zone",
+		djangolang_example.GeofenceEventTableDeletedAtColumn:         "timestamp with time zone",
+		djangolang_example.GeofenceEventTableTimestampColumn:         "timestamp with time zone",
+		djangolang_example.GeofenceEventTableTypeColumn:              "text",
+		djangolang_example.GeofenceEventTableGeofenceIDColumn:        "uuid",
+		djangolang_example.GeofenceEventTablePhysicalThingIDColumn:   "uuid",
+		djangolang_example.GeofenceEventTableLocationHistoryIDColumn: "uuid",
+		djangolang_example.GeofenceEventTableDeletionBatchIDColumn:   "bigint",
+	},
+}
+
+// foreignKeysByTable is (for each table) the foreign table and column referred to by each of its foreign key columns
+var foreignKeysByTable = map[string]map[string]foreignKey{
+	djangolang_example.LogicalThingTable: {
+		djangolang_example.LogicalThingTableParentPhysicalThingIDColumn: {table: djangolang_example.PhysicalThingTable, column: djangolang_example.PhysicalThingTableIDColumn},
+		djangolang_example.LogicalThingTableParentLogicalThingIDColumn:  {table: djangolang_example.LogicalThingTable, column: djangolang_example.LogicalThingTableIDColumn},
+	},
+	djangolang_example.LocationHistoryTable: {
+		djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn: {table: djangolang_example.PhysicalThingTable, column: djangolang_example.PhysicalThingTableIDColumn},
+	},
+	djangolang_example.GeofenceEventTable: {
+		djangolang_example.GeofenceEventTableGeofenceIDColumn:        {table: djangolang_example.GeofenceTable, column: djangolang_example.GeofenceTableIDColumn},
+		djangolang_example.GeofenceEventTablePhysicalThingIDColumn:   {table: djangolang_example.PhysicalThingTable, column: djangolang_example.PhysicalThingTableIDColumn},
+		djangolang_example.GeofenceEventTableLocationHistoryIDColumn: {table: djangolang_example.LocationHistoryTable, column: djangolang_example.LocationHistoryTableIDColumn},
+	},
+}
+
+type columnKind string
+
+const (
+	columnKindArray    columnKind = "array"
+	columnKindHstore   columnKind = "hstore"
+	columnKindJSONB    columnKind = "jsonb"
+	columnKindTSVector columnKind = "tsvector"
+	columnKindPoint    columnKind = "point"
+	columnKindPolygon  columnKind = "polygon"
+	columnKindJSON     columnKind = "json"
+	columnKindGeometry columnKind = "geometry"
+)
+
+// columnKindsByTable is (for each table) the columns that aren't scalars, i.e. that can't be ordered by and that may
+// support more than the scalar filter operators
+var columnKindsByTable = map[string]map[string]columnKind{
+	djangolang_example.PhysicalThingTable: {
+		djangolang_example.PhysicalThingTableTagsColumn:     columnKindArray,
+		djangolang_example.PhysicalThingTableMetadataColumn: columnKindHstore,
+		djangolang_example.PhysicalThingTableRawDataColumn:  columnKindJSONB,
+	},
+	djangolang_example.LogicalThingTable: {
+		djangolang_example.LogicalThingTableTagsColumn:     columnKindArray,
+		djangolang_example.LogicalThingTableMetadataColumn: columnKindHstore,
+		djangolang_example.LogicalThingTableRawDataColumn:  columnKindJSONB,
+	},
+	djangolang_example.LocationHistoryTable: {
+		djangolang_example.LocationHistoryTablePointColumn:   columnKindPoint,
+		djangolang_example.LocationHistoryTablePolygonColumn: columnKindPolygon,
+	},
+	djangolang_example.FuzzTable: {
+		djangolang_example.FuzzTableColumn3Column:  columnKindJSON,
+		djangolang_example.FuzzTableColumn4Column:  columnKindJSONB,
+		djangolang_example.FuzzTableColumn5Column:  columnKindArray,
+		djangolang_example.FuzzTableColumn6Column:  columnKindArray,
+		djangolang_example.FuzzTableColumn9Column:  columnKindArray,
+		djangolang_example.FuzzTableColumn10Column: columnKindArray,
+		djangolang_example.FuzzTableColumn11Column: columnKindArray,
+		djangolang_example.FuzzTableColumn15Column: columnKindArray,
+		djangolang_example.FuzzTableColumn16Column: columnKindArray,
+		djangolang_example.FuzzTableColumn17Column: columnKindArray,
+		djangolang_example.FuzzTableColumn18Column: columnKindArray,
+		djangolang_example.FuzzTableColumn23Column: columnKindArray,
+		djangolang_example.FuzzTableColumn25Column: columnKindTSVector,
+		djangolang_example.FuzzTableColumn27Column: columnKindHstore,
+		djangolang_example.FuzzTableColumn28Column: columnKindPoint,
+		djangolang_example.FuzzTableColumn29Column: columnKindPolygon,
+		djangolang_example.FuzzTableColumn30Column: columnKindGeometry,
+		djangolang_example.FuzzTableColumn31Column: columnKindGeometry,
+	},
+	djangolang_example.GeofenceTable: {
+		djangolang_example.GeofenceTablePolygonColumn: columnKindPolygon,
+	},
+}
+
+func hasDeletedAt(table string) bool {
+	_, ok := columnLookupByTable[table]["deleted_at"]
+	return ok
+}
+
+type expansionDepthKey struct{}
+
+// defaultExpansionDepth is how many levels of foreign objects (e.g. ParentPhysicalThingIDObject) are loaded by default
+const defaultExpansionDepth = 1
+
+// maxExpansionDepth is the most levels of foreign objects that may be requested via the depth param
+const maxExpansionDepth = 8
+
+// WithExpansionDepth returns a context that causes the Select functions to load foreign objects (e.g.
+// ParentPhysicalThingIDObject) to the given depth; 0 disables loading them entirely
+func WithExpansionDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, expansionDepthKey{}, depth)
+}
+
+func getExpansionDepth(ctx context.Context) int {
+	depth, ok := ctx.Value(expansionDepthKey{}).(int)
+	if !ok {
+		return defaultExpansionDepth
+	}
+
+	return depth
+}
+
+// loadForeignObjects selects the foreign objects with the given primary keys (using one query, via selectFn) and returns
+// them by primary key; keys that don't match a row (e.g. because it's soft-deleted) are absent from the result
+func loadForeignObjects[K comparable, T any](
+	keys []K,
+	primaryKeyColumn string,
+	selectFn func(where string, values ...any) ([]T, error),
+	getKey func(T) K,
+) (map[K]T, error) {
+	objectByKey := make(map[K]T)
+
+	placeholders := make([]string, 0)
+	values := make([]any, 0)
+	seen := make(map[K]struct{})
+
+	for _, key := range keys {
+		_, ok := seen[key]
+		if ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		placeholders = append(placeholders, "$$??")
+		values = append(values, key)
+	}
+
+	if len(values) == 0 {
+		return objectByKey, nil
+	}
+
+	objects, err := selectFn(fmt.Sprintf("%s IN (%s)", primaryKeyColumn, strings.Join(placeholders, ", ")), values...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		objectByKey[getKey(object)] = object
+	}
+
+	return objectByKey, nil
+}
@@ -0,0 +1,161 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type relationsTestObject struct {
+	ID   string
+	Name string
+}
+
+func TestLoadForeignObjects(t *testing.T) {
+	testCases := []struct {
+		name           string
+		keys           []string
+		rows           []*relationsTestObject
+		selectErr      error
+		expectedWhere  string
+		expectedValues []any
+		expectedKeys   []string
+		expectErr      bool
+	}{
+		{
+			name:           "duplicate keys are selected once",
+			keys:           []string{"a", "b", "a"},
+			rows:           []*relationsTestObject{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}},
+			expectedWhere:  "id IN ($$??, $$??)",
+			expectedValues: []any{"a", "b"},
+			expectedKeys:   []string{"a", "b"},
+		},
+		{
+			name:           "missing rows are absent",
+			keys:           []string{"a", "c"},
+			rows:           []*relationsTestObject{{ID: "a", Name: "A"}},
+			expectedWhere:  "id IN ($$??, $$??)",
+			expectedValues: []any{"a", "c"},
+			expectedKeys:   []string{"a"},
+		},
+		{
+			name:         "no keys, no query",
+			keys:         []string{},
+			expectedKeys: []string{},
+		},
+		{
+			name:           "failed select",
+			keys:           []string{"a"},
+			selectErr:      fmt.Errorf("failed"),
+			expectedWhere:  "id IN ($$??)",
+			expectedValues: []any{"a"},
+			expectErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			selects := 0
+
+			selectFn := func(where string, values ...any) ([]*relationsTestObject, error) {
+				selects++
+
+				if where != testCase.expectedWhere {
+					t.Fatalf("expected where %#+v but got %#+v", testCase.expectedWhere, where)
+				}
+
+				if !reflect.DeepEqual(values, testCase.expectedValues) {
+					t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+				}
+
+				return testCase.rows, testCase.selectErr
+			}
+
+			getKey := func(object *relationsTestObject) string {
+				return object.ID
+			}
+
+			objectByKey, err := loadForeignObjects(testCase.keys, "id", selectFn, getKey)
+
+			expectedSelects := 1
+			if testCase.expectedWhere == "" {
+				expectedSelects = 0
+			}
+
+			if selects != expectedSelects {
+				t.Fatalf("expected %d selects but got %d", expectedSelects, selects)
+			}
+
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", objectByKey)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(objectByKey) != len(testCase.expectedKeys) {
+				t.Fatalf("expected %d objects but got %#+v", len(testCase.expectedKeys), objectByKey)
+			}
+
+			for _, key := range testCase.expectedKeys {
+				object, ok := objectByKey[key]
+				if !ok || object.ID != key {
+					t.Fatalf("expected an object for %v but got %#+v", key, objectByKey)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDepthParam(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rawQuery  string
+		expected  int
+		expectErr bool
+	}{
+		{name: "default", rawQuery: "", expected: defaultExpansionDepth},
+		{name: "none", rawQuery: "depth=0", expected: 0},
+		{name: "deepest", rawQuery: fmt.Sprintf("depth=%d", maxExpansionDepth), expected: maxExpansionDepth},
+		{name: "too deep", rawQuery: fmt.Sprintf("depth=%d", maxExpansionDepth+1), expectErr: true},
+		{name: "negative", rawQuery: "depth=-1", expectErr: true},
+		{name: "not a number", rawQuery: "depth=all", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/logical-things?"+testCase.rawQuery, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			depth, err := parseDepthParam(r)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", depth)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if depth != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, depth)
+			}
+
+			if getExpansionDepth(WithExpansionDepth(context.Background(), depth)) != depth {
+				t.Fatalf("expected the depth to make it through the context")
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package extensions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/initialed85/djangolang/pkg/helpers"
+)
+
+// ListResponse is the envelope for the list endpoints; it's helpers.Response plus some pagination info
+type ListResponse struct {
+	helpers.Response
+	NextCursor *string `json:"next_cursor,omitempty"`
+	PrevCursor *string `json:"prev_cursor,omitempty"`
+	Total      *int64  `json:"total,omitempty"`
+}
+
+func handleListResponse(w http.ResponseWriter, status int, objects any, nextCursor *string, prevCursor *string, total *int64) []byte {
+	status, response, b, err := helpers.GetResponse(status, nil, objects)
+	if err == nil {
+		b, err = json.Marshal(ListResponse{
+			Response:   response,
+			NextCursor: nextCursor,
+			PrevCursor: prevCursor,
+			Total:      total,
+		})
+		if err != nil {
+			status, _, b, _ = helpers.GetResponse(
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to marshal %#+v to JSON: %v", response, err),
+				nil,
+			)
+		}
+	}
+
+	helpers.WriteResponse(w, status, b)
+
+	return b
+}
@@ -0,0 +1,59 @@
+package extensions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/initialed85/djangolang/pkg/helpers"
+)
+
+func TestHandleListResponse(t *testing.T) {
+	testCases := []struct {
+		name       string
+		nextCursor *string
+		prevCursor *string
+		total      *int64
+		expected   map[string]any
+	}{
+		{
+			name:     "no pagination info",
+			expected: map[string]any{"status": 200.0, "success": true, "objects": []any{"a"}},
+		},
+		{
+			name:       "cursors",
+			nextCursor: helpers.Ptr("next"),
+			prevCursor: helpers.Ptr("prev"),
+			expected:   map[string]any{"status": 200.0, "success": true, "objects": []any{"a"}, "next_cursor": "next", "prev_cursor": "prev"},
+		},
+		{
+			name:     "total",
+			total:    helpers.Ptr(int64(0)),
+			expected: map[string]any{"status": 200.0, "success": true, "objects": []any{"a"}, "total": 0.0},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			handleListResponse(w, http.StatusOK, []string{"a"}, testCase.nextCursor, testCase.prevCursor, testCase.total)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected %d but got %d", http.StatusOK, w.Code)
+			}
+
+			var response map[string]any
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(response, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, response)
+			}
+		})
+	}
+}
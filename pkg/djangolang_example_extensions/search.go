@@ -0,0 +1,89 @@
+package extensions
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"golang.org/x/exp/maps"
+)
+
+// rankOrderByColumn is the pseudo-column that orders by search relevance (order_by=-rank)
+const rankOrderByColumn = "rank"
+
+// searchColumnsByTable is (for each table) the text columns that the q param searches across
+var searchColumnsByTable = map[string][]string{
+	djangolang_example.PhysicalThingTable: {
+		djangolang_example.PhysicalThingTableNameColumn,
+		djangolang_example.PhysicalThingTableExternalIDColumn,
+	},
+	djangolang_example.LogicalThingTable: {
+		djangolang_example.LogicalThingTableNameColumn,
+		djangolang_example.LogicalThingTableExternalIDColumn,
+	},
+}
+
+func getSearchVector(table string) (string, error) {
+	columns, ok := searchColumnsByTable[table]
+	if !ok {
+		return "", fmt.Errorf("%v doesn't support q", table)
+	}
+
+	parts := make([]string, 0)
+	for _, column := range columns {
+		parts = append(parts, fmt.Sprintf("coalesce(%s, '')", formatColumn("", column)))
+	}
+
+	return fmt.Sprintf("to_tsvector(%s)", strings.Join(parts, " || ' ' || ")), nil
+}
+
+// getSearchWhere returns the where (and its values) for an ad-hoc search (the q param) across the text columns of the
+// table, using search engine syntax as per websearch_to_tsquery
+func getSearchWhere(table string, q string) (string, []any, error) {
+	vector, err := getSearchVector(table)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s @@ websearch_to_tsquery($$??)", vector), []any{q}, nil
+}
+
+// getRankExpression returns an expression (and its values) for the relevance of a row to the searches in the request
+// (the q param and any column__search params on tsvector columns), summed if there's more than one
+func getRankExpression(table string, query url.Values) (string, []any, error) {
+	ranks := make([]string, 0)
+	values := make([]any, 0)
+
+	for _, q := range query["q"] {
+		vector, err := getSearchVector(table)
+		if err != nil {
+			return "", nil, err
+		}
+
+		ranks = append(ranks, fmt.Sprintf("ts_rank(%s, websearch_to_tsquery($$??))", vector))
+		values = append(values, q)
+	}
+
+	keys := maps.Keys(query)
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		column, found := strings.CutSuffix(key, "__search")
+		if !found || columnKindsByTable[table][column] != columnKindTSVector {
+			continue
+		}
+
+		for _, rawValue := range query[key] {
+			ranks = append(ranks, fmt.Sprintf("ts_rank(%s, websearch_to_tsquery($$??))", formatColumn("", column)))
+			values = append(values, rawValue)
+		}
+	}
+
+	if len(ranks) == 0 {
+		return "", nil, fmt.Errorf("%v requires q or a column__search param", rankOrderByColumn)
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(ranks, " + ")), values, nil
+}
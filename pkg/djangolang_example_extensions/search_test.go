@@ -0,0 +1,130 @@
+package extensions
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+)
+
+func TestGetSearchWhere(t *testing.T) {
+	where, values, err := getSearchWhere(djangolang_example.PhysicalThingTable, `"big thing" -small`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedWhere := `to_tsvector(coalesce("name", '') || ' ' || coalesce("external_id", '')) @@ websearch_to_tsquery($$??)`
+	if where != expectedWhere {
+		t.Fatalf("expected where %#+v but got %#+v", expectedWhere, where)
+	}
+
+	if !reflect.DeepEqual(values, []any{`"big thing" -small`}) {
+		t.Fatalf("expected values %#+v but got %#+v", []any{`"big thing" -small`}, values)
+	}
+
+	_, _, err = getSearchWhere(djangolang_example.LocationHistoryTable, "thing")
+	if err == nil {
+		t.Fatalf("expected an error for a table without search columns")
+	}
+}
+
+func TestGetSearchFilterWhere(t *testing.T) {
+	where, values, err := getFilterWhere(djangolang_example.FuzzTable, "column25__search", "thing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedWhere := `"column25" @@ websearch_to_tsquery($$??)`
+	if where != expectedWhere {
+		t.Fatalf("expected where %#+v but got %#+v", expectedWhere, where)
+	}
+
+	if !reflect.DeepEqual(values, []any{"thing"}) {
+		t.Fatalf("expected values %#+v but got %#+v", []any{"thing"}, values)
+	}
+
+	_, _, err = getFilterWhere(djangolang_example.PhysicalThingTable, "name__search", "thing")
+	if err == nil {
+		t.Fatalf("expected an error for search on a text column")
+	}
+}
+
+func TestGetRankExpression(t *testing.T) {
+	testCases := []struct {
+		name               string
+		table              string
+		query              url.Values
+		expectedExpression string
+		expectedValues     []any
+		expectErr          bool
+	}{
+		{
+			name:               "q",
+			table:              djangolang_example.PhysicalThingTable,
+			query:              url.Values{"q": {"thing"}},
+			expectedExpression: `(ts_rank(to_tsvector(coalesce("name", '') || ' ' || coalesce("external_id", '')), websearch_to_tsquery($$??)))`,
+			expectedValues:     []any{"thing"},
+		},
+		{
+			name:               "column searches are summed and others ignored",
+			table:              djangolang_example.FuzzTable,
+			query:              url.Values{"column25__search": {"a", "b"}, "column1__eq": {"c"}},
+			expectedExpression: `(ts_rank("column25", websearch_to_tsquery($$??)) + ts_rank("column25", websearch_to_tsquery($$??)))`,
+			expectedValues:     []any{"a", "b"},
+		},
+		{
+			name:      "nothing to rank by",
+			table:     djangolang_example.PhysicalThingTable,
+			query:     url.Values{"name__eq": {"thing"}},
+			expectErr: true,
+		},
+		{
+			name:      "q on a table without search columns",
+			table:     djangolang_example.LocationHistoryTable,
+			query:     url.Values{"q": {"thing"}},
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			expression, values, err := getRankExpression(testCase.table, testCase.query)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v, %#+v", expression, values)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if expression != testCase.expectedExpression {
+				t.Fatalf("expected expression %#+v but got %#+v", testCase.expectedExpression, expression)
+			}
+
+			if !reflect.DeepEqual(values, testCase.expectedValues) {
+				t.Fatalf("expected values %#+v but got %#+v", testCase.expectedValues, values)
+			}
+		})
+	}
+}
+
+func TestParseOrderByRank(t *testing.T) {
+	orderByColumns, err := parseOrderBy("-rank", columnLookupByTable[djangolang_example.PhysicalThingTable], columnKindsByTable[djangolang_example.PhysicalThingTable], "id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []orderByColumn{{Column: rankOrderByColumn, Descending: true}, {Column: "id"}}
+	if !reflect.DeepEqual(orderByColumns, expected) {
+		t.Fatalf("expected %#+v but got %#+v", expected, orderByColumns)
+	}
+
+	if isCursorable(orderByColumns) {
+		t.Fatalf("expected ordering by rank not to be cursorable")
+	}
+}
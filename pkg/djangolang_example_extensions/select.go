@@ -0,0 +1,383 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/types"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+)
+
+// SelectOptions are the extras the Select*WithOptions functions support over the generated Select* functions
+type SelectOptions struct {
+	// OrderBy is an ORDER BY expression (without the ORDER BY); any placeholders in it take their values from after
+	// those of the where
+	OrderBy *string
+
+	// Columns are the columns to select (with any type casts, as per the generated *TableColumnsWithTypeCasts); all of
+	// them if empty
+	Columns []string
+
+	Limit  *int
+	Offset *int
+}
+
+type fromItemer[T any] interface {
+	*T
+	FromItem(item map[string]any) error
+}
+
+// selectObjects is the generated Select* functions with support for SelectOptions (but without loading any foreign
+// objects); soft-deleted rows are left out unless where mentions deleted_at
+func selectObjects[T any, PT fromItemer[T]](
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table string,
+	where string,
+	options SelectOptions,
+	values ...any,
+) ([]*T, error) {
+	if hasDeletedAt(table) {
+		if !mentionsDeletedAt(where) {
+			if where != "" {
+				where += "\n    AND "
+			}
+
+			where += "deleted_at IS null"
+		}
+	}
+
+	columns := options.Columns
+	if len(columns) == 0 {
+		columns = columnsWithTypeCastsByTable[table]
+	}
+
+	items, err := selectItems(
+		ctx,
+		tx,
+		columns,
+		table,
+		where,
+		options.OrderBy,
+		options.Limit,
+		options.Offset,
+		values...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select from %s; err: %v", table, err)
+	}
+
+	objects := make([]*T, 0)
+
+	for _, item := range items {
+		object := PT(new(T))
+
+		err = object.FromItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call FromItem for %s; err: %v", table, err)
+		}
+
+		objects = append(objects, (*T)(object))
+	}
+
+	return objects, nil
+}
+
+// Count returns the number of rows of the given table matching where (see countItems for estimate); soft-deleted rows
+// are left out unless where mentions deleted_at
+func Count(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table string,
+	where string,
+	estimate bool,
+	values ...any,
+) (int64, error) {
+	if hasDeletedAt(table) {
+		if !mentionsDeletedAt(where) {
+			if where != "" {
+				where += "\n    AND "
+			}
+
+			where += "deleted_at IS null"
+		}
+	}
+
+	count, err := countItems(ctx, tx, table, where, estimate, values...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s; err: %v", table, err)
+	}
+
+	return count, nil
+}
+
+// SelectPhysicalThingsWithOptions is djangolang_example.SelectPhysicalThings with support for SelectOptions
+func SelectPhysicalThingsWithOptions(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	options SelectOptions,
+	values ...any,
+) ([]*djangolang_example.PhysicalThing, error) {
+	return selectObjects[djangolang_example.PhysicalThing](ctx, tx, djangolang_example.PhysicalThingTable, where, options, values...)
+}
+
+// SelectLogicalThingsWithOptions is djangolang_example.SelectLogicalThings with support for SelectOptions; the foreign
+// objects are loaded with one query per foreign key (rather than one per row) to the depth given by WithExpansionDepth
+func SelectLogicalThingsWithOptions(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	options SelectOptions,
+	values ...any,
+) ([]*djangolang_example.LogicalThing, error) {
+	objects, err := selectObjects[djangolang_example.LogicalThing](ctx, tx, djangolang_example.LogicalThingTable, where, options, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := getExpansionDepth(ctx)
+	if depth <= 0 {
+		return objects, nil
+	}
+
+	foreignCtx := WithExpansionDepth(ctx, depth-1)
+
+	parentPhysicalThingIDs := make([]uuid.UUID, 0)
+	parentLogicalThingIDs := make([]uuid.UUID, 0)
+
+	for _, object := range objects {
+		if !types.IsZeroUUID(object.ParentPhysicalThingID) {
+			parentPhysicalThingIDs = append(parentPhysicalThingIDs, *object.ParentPhysicalThingID)
+		}
+
+		if !types.IsZeroUUID(object.ParentLogicalThingID) {
+			parentLogicalThingIDs = append(parentLogicalThingIDs, *object.ParentLogicalThingID)
+		}
+	}
+
+	parentPhysicalThingByID, err := loadForeignObjects(
+		parentPhysicalThingIDs,
+		djangolang_example.PhysicalThingTablePrimaryKeyColumn,
+		func(where string, values ...any) ([]*djangolang_example.PhysicalThing, error) {
+			return SelectPhysicalThingsWithOptions(foreignCtx, tx, where, SelectOptions{}, values...)
+		},
+		func(object *djangolang_example.PhysicalThing) uuid.UUID {
+			return object.ID
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ParentPhysicalThingIDObject during SelectLogicalThingsWithOptions; err: %v", err)
+	}
+
+	parentLogicalThingByID, err := loadForeignObjects(
+		parentLogicalThingIDs,
+		djangolang_example.LogicalThingTablePrimaryKeyColumn,
+		func(where string, values ...any) ([]*djangolang_example.LogicalThing, error) {
+			return SelectLogicalThingsWithOptions(foreignCtx, tx, where, SelectOptions{}, values...)
+		},
+		func(object *djangolang_example.LogicalThing) uuid.UUID {
+			return object.ID
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ParentLogicalThingIDObject during SelectLogicalThingsWithOptions; err: %v", err)
+	}
+
+	for _, object := range objects {
+		if !types.IsZeroUUID(object.ParentPhysicalThingID) {
+			object.ParentPhysicalThingIDObject = parentPhysicalThingByID[*object.ParentPhysicalThingID]
+		}
+
+		if !types.IsZeroUUID(object.ParentLogicalThingID) {
+			object.ParentLogicalThingIDObject = parentLogicalThingByID[*object.ParentLogicalThingID]
+		}
+	}
+
+	return objects, nil
+}
+
+// SelectLocationHistorysWithOptions is djangolang_example.SelectLocationHistorys with support for SelectOptions; the
+// foreign objects are loaded as per SelectLogicalThingsWithOptions
+func SelectLocationHistorysWithOptions(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	options SelectOptions,
+	values ...any,
+) ([]*djangolang_example.LocationHistory, error) {
+	objects, err := selectObjects[djangolang_example.LocationHistory](ctx, tx, djangolang_example.LocationHistoryTable, where, options, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadLocationHistoryForeignObjects(ctx, tx, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// loadLocationHistoryForeignObjects loads the foreign objects of the given location history rows (in place) as per
+// SelectLocationHistorysWithOptions
+func loadLocationHistoryForeignObjects(ctx context.Context, tx *sqlx.Tx, objects []*djangolang_example.LocationHistory) error {
+	depth := getExpansionDepth(ctx)
+	if depth <= 0 {
+		return nil
+	}
+
+	foreignCtx := WithExpansionDepth(ctx, depth-1)
+
+	parentPhysicalThingIDs := make([]uuid.UUID, 0)
+
+	for _, object := range objects {
+		if !types.IsZeroUUID(object.ParentPhysicalThingID) {
+			parentPhysicalThingIDs = append(parentPhysicalThingIDs, *object.ParentPhysicalThingID)
+		}
+	}
+
+	parentPhysicalThingByID, err := loadForeignObjects(
+		parentPhysicalThingIDs,
+		djangolang_example.PhysicalThingTablePrimaryKeyColumn,
+		func(where string, values ...any) ([]*djangolang_example.PhysicalThing, error) {
+			return SelectPhysicalThingsWithOptions(foreignCtx, tx, where, SelectOptions{}, values...)
+		},
+		func(object *djangolang_example.PhysicalThing) uuid.UUID {
+			return object.ID
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load ParentPhysicalThingIDObject of LocationHistory; err: %v", err)
+	}
+
+	for _, object := range objects {
+		if !types.IsZeroUUID(object.ParentPhysicalThingID) {
+			object.ParentPhysicalThingIDObject = parentPhysicalThingByID[*object.ParentPhysicalThingID]
+		}
+	}
+
+	return nil
+}
+
+// SelectFuzzsWithOptions is djangolang_example.SelectFuzzs with support for SelectOptions
+func SelectFuzzsWithOptions(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	options SelectOptions,
+	values ...any,
+) ([]*djangolang_example.Fuzz, error) {
+	return selectObjects[djangolang_example.Fuzz](ctx, tx, djangolang_example.FuzzTable, where, options, values...)
+}
+
+// SelectGeofencesWithOptions is djangolang_example.SelectGeofences with support for SelectOptions
+func SelectGeofencesWithOptions(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	options SelectOptions,
+	values ...any,
+) ([]*djangolang_example.Geofence, error) {
+	return selectObjects[djangolang_example.Geofence](ctx, tx, djangolang_example.GeofenceTable, where, options, values...)
+}
+
+// SelectGeofenceEventsWithOptions is djangolang_example.SelectGeofenceEvents with support for SelectOptions; the
+// foreign objects are loaded as per SelectLogicalThingsWithOptions
+func SelectGeofenceEventsWithOptions(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	where string,
+	options SelectOptions,
+	values ...any,
+) ([]*djangolang_example.GeofenceEvent, error) {
+	objects, err := selectObjects[djangolang_example.GeofenceEvent](ctx, tx, djangolang_example.GeofenceEventTable, where, options, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := getExpansionDepth(ctx)
+	if depth <= 0 {
+		return objects, nil
+	}
+
+	foreignCtx := WithExpansionDepth(ctx, depth-1)
+
+	geofenceIDs := make([]uuid.UUID, 0)
+	physicalThingIDs := make([]uuid.UUID, 0)
+	locationHistoryIDs := make([]uuid.UUID, 0)
+
+	for _, object := range objects {
+		if !types.IsZeroUUID(object.GeofenceID) {
+			geofenceIDs = append(geofenceIDs, object.GeofenceID)
+		}
+
+		if !types.IsZeroUUID(object.PhysicalThingID) {
+			physicalThingIDs = append(physicalThingIDs, object.PhysicalThingID)
+		}
+
+		if !types.IsZeroUUID(object.LocationHistoryID) {
+			locationHistoryIDs = append(locationHistoryIDs, *object.LocationHistoryID)
+		}
+	}
+
+	geofenceByID, err := loadForeignObjects(
+		geofenceIDs,
+		djangolang_example.GeofenceTablePrimaryKeyColumn,
+		func(where string, values ...any) ([]*djangolang_example.Geofence, error) {
+			return SelectGeofencesWithOptions(foreignCtx, tx, where, SelectOptions{}, values...)
+		},
+		func(object *djangolang_example.Geofence) uuid.UUID {
+			return object.ID
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GeofenceIDObject during SelectGeofenceEventsWithOptions; err: %v", err)
+	}
+
+	physicalThingByID, err := loadForeignObjects(
+		physicalThingIDs,
+		djangolang_example.PhysicalThingTablePrimaryKeyColumn,
+		func(where string, values ...any) ([]*djangolang_example.PhysicalThing, error) {
+			return SelectPhysicalThingsWithOptions(foreignCtx, tx, where, SelectOptions{}, values...)
+		},
+		func(object *djangolang_example.PhysicalThing) uuid.UUID {
+			return object.ID
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PhysicalThingIDObject during SelectGeofenceEventsWithOptions; err: %v", err)
+	}
+
+	locationHistoryByID, err := loadForeignObjects(
+		locationHistoryIDs,
+		djangolang_example.LocationHistoryTablePrimaryKeyColumn,
+		func(where string, values ...any) ([]*djangolang_example.LocationHistory, error) {
+			return SelectLocationHistorysWithOptions(foreignCtx, tx, where, SelectOptions{}, values...)
+		},
+		func(object *djangolang_example.LocationHistory) uuid.UUID {
+			return object.ID
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load LocationHistoryIDObject during SelectGeofenceEventsWithOptions; err: %v", err)
+	}
+
+	for _, object := range objects {
+		if !types.IsZeroUUID(object.GeofenceID) {
+			object.GeofenceIDObject = geofenceByID[object.GeofenceID]
+		}
+
+		if !types.IsZeroUUID(object.PhysicalThingID) {
+			object.PhysicalThingIDObject = physicalThingByID[object.PhysicalThingID]
+		}
+
+		if !types.IsZeroUUID(object.LocationHistoryID) {
+			object.LocationHistoryIDObject = locationHistoryByID[*object.LocationHistoryID]
+		}
+	}
+
+	return objects, nil
+}
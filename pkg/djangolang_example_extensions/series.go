@@ -0,0 +1,347 @@
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/query"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxSeriesBuckets is the most buckets (across all the groups, including the zero-filled ones) a series may have, so
+// that a tiny bucket size can't be used to get the raw rows
+const maxSeriesBuckets = 100000
+
+// seriesOrigin is what the buckets are aligned to, i.e. a bucket of 1h starts on the hour
+var seriesOrigin = time.Unix(0, 0).UTC()
+
+// SeriesBucket is the number of rows (in a group) with a time in [Bucket, Bucket + the bucket size); group is the values
+// of the group_by columns (empty if not grouped)
+type SeriesBucket struct {
+	Bucket time.Time      `json:"bucket"`
+	Group  map[string]any `json:"group"`
+	Count  int64          `json:"count"`
+}
+
+// seriesGroup is the values of the group_by columns of a group and the count of rows per bucket start that has rows in it
+type seriesGroup struct {
+	values    map[string]any
+	countByAt map[time.Time]int64
+}
+
+// getDefaultSeriesColumn is the time column a series is over unless otherwise specified, i.e. timestamp for tables that
+// have one (e.g. location_history) and created_at for the rest
+func getDefaultSeriesColumn(table string) string {
+	dataType, ok := getColumnDataType(table, "timestamp")
+	if ok && isTimeDataType(dataType) {
+		return "timestamp"
+	}
+
+	return "created_at"
+}
+
+// getSeriesBucketStart returns the start of the bucket that t is in
+func getSeriesBucketStart(t time.Time, bucket time.Duration) time.Time {
+	offset := t.Sub(seriesOrigin)
+
+	start := offset - offset%bucket
+	if offset%bucket < 0 {
+		start -= bucket
+	}
+
+	return seriesOrigin.Add(start).In(t.Location())
+}
+
+// GetSeries counts the (non-deleted) rows of the given table matching the wheres per bucket-long interval of the given
+// time column (and per group of the given columns), between from (inclusive) and to (exclusive); either of from / to may
+// be nil, in which case the series starts / ends with the first / last bucket that has rows in it; buckets without rows
+// are zero-filled, for each group that has rows
+func GetSeries(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table string,
+	column string,
+	bucket time.Duration,
+	groupBy []string,
+	from *time.Time,
+	to *time.Time,
+	wheres []string,
+	values ...any,
+) ([]*SeriesBucket, error) {
+	if bucket <= 0 || bucket%time.Second != 0 {
+		return nil, fmt.Errorf("bucket must be a positive whole number of seconds but got %v", bucket)
+	}
+
+	wheres = append(wheres, fmt.Sprintf("%s IS NOT null", formatColumn("", column)))
+
+	if hasDeletedAt(table) {
+		wheres = append(wheres, fmt.Sprintf("%s IS null", formatColumn("", "deleted_at")))
+	}
+
+	if from != nil {
+		wheres = append(wheres, fmt.Sprintf("%s >= $$??", formatColumn("", column)))
+		values = append(values, *from)
+	}
+
+	if to != nil {
+		wheres = append(wheres, fmt.Sprintf("%s < $$??", formatColumn("", column)))
+		values = append(values, *to)
+	}
+
+	pairs := make([]string, 0)
+	columns := make([]string, 0)
+	for _, groupColumn := range groupBy {
+		pairs = append(pairs, fmt.Sprintf("'%s', %s", groupColumn, formatColumn("", groupColumn)))
+		columns = append(columns, formatColumn("", groupColumn))
+	}
+
+	sql := fmt.Sprintf(
+		`SELECT
+    jsonb_build_object(%s),
+    date_bin(make_interval(secs => $$??), %s::timestamptz, $$??::timestamptz),
+    count(*)
+FROM
+    %s
+WHERE
+    %s
+GROUP BY
+    %s
+ORDER BY
+    %s
+LIMIT %d;`,
+		strings.Join(pairs, ", "),
+		formatColumn("", column),
+		query.FormatObjectName(table),
+		strings.Join(wheres, "\n    AND "),
+		strings.Join(append(columns, "2"), ", "),
+		strings.Join(append(columns, "2"), ", "),
+		maxSeriesBuckets+1,
+	)
+
+	// the placeholders in the SELECT come before those in the WHERE
+	values = append([]any{bucket.Seconds(), seriesOrigin}, values...)
+
+	groups := make([]*seriesGroup, 0)
+	groupByKey := make(map[string]*seriesGroup)
+
+	rowCount := 0
+
+	err := queryRows(ctx, tx, sql, values, func(rows *sqlx.Rows) error {
+		var rawGroup []byte
+		var at time.Time
+		var count int64
+
+		err := rows.Scan(&rawGroup, &at, &count)
+		if err != nil {
+			return err
+		}
+
+		rowCount++
+
+		g, ok := groupByKey[string(rawGroup)]
+		if !ok {
+			g = &seriesGroup{values: make(map[string]any), countByAt: make(map[time.Time]int64)}
+
+			err = json.Unmarshal(rawGroup, &g.values)
+			if err != nil {
+				return err
+			}
+
+			groups = append(groups, g)
+			groupByKey[string(rawGroup)] = g
+		}
+
+		g.countByAt[at.UTC()] = count
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rowCount > maxSeriesBuckets {
+		return nil, fmt.Errorf("series has more than %d buckets; try a bigger bucket, a narrower time window or a narrower filter", maxSeriesBuckets)
+	}
+
+	// note: an ungrouped series always has its (single) group, so that a window with no rows in it comes back as zeroes
+	if len(groupBy) == 0 && len(groups) == 0 {
+		groups = append(groups, &seriesGroup{values: make(map[string]any), countByAt: make(map[time.Time]int64)})
+	}
+
+	return zeroFillSeries(groups, bucket, from, to)
+}
+
+// zeroFillSeries returns a bucket for every bucket start from the first to the last (for each group), with a count of
+// zero for those that have no rows in them; first / last is the bucket of from / to if given, otherwise the first / last
+// bucket that has rows in it (in any group)
+func zeroFillSeries(groups []*seriesGroup, bucket time.Duration, from *time.Time, to *time.Time) ([]*SeriesBucket, error) {
+	var first, last *time.Time
+
+	for _, g := range groups {
+		for at := range g.countByAt {
+			if first == nil || at.Before(*first) {
+				first = helpers.Ptr(at)
+			}
+
+			if last == nil || at.After(*last) {
+				last = helpers.Ptr(at)
+			}
+		}
+	}
+
+	if from != nil {
+		first = helpers.Ptr(getSeriesBucketStart(from.UTC(), bucket))
+	}
+
+	if to != nil {
+		last = helpers.Ptr(getSeriesBucketStart(to.UTC().Add(-time.Nanosecond), bucket))
+	}
+
+	buckets := make([]*SeriesBucket, 0)
+
+	if first == nil || last == nil || last.Before(*first) {
+		return buckets, nil
+	}
+
+	bucketsPerGroup := int64(last.Sub(*first)/bucket) + 1
+	if bucketsPerGroup*int64(len(groups)) > maxSeriesBuckets {
+		return nil, fmt.Errorf("series has more than %d buckets; try a bigger bucket, a narrower time window or a narrower filter", maxSeriesBuckets)
+	}
+
+	for _, g := range groups {
+		for at := *first; !at.After(*last); at = at.Add(bucket) {
+			buckets = append(buckets, &SeriesBucket{
+				Bucket: at,
+				Group:  g.values,
+				Count:  g.countByAt[at],
+			})
+		}
+	}
+
+	return buckets, nil
+}
+
+// parseSeriesBucket parses a bucket size like 15m; it has to be a whole number of seconds, as that's how it's given to
+// date_bin (and zeroFillSeries would otherwise disagree with it about where the buckets start)
+func parseSeriesBucket(rawBucket string) (time.Duration, error) {
+	bucket, err := time.ParseDuration(rawBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	if bucket <= 0 || bucket%time.Second != 0 {
+		return 0, fmt.Errorf("bucket must be a positive whole number of seconds but got %v", bucket)
+	}
+
+	return bucket, nil
+}
+
+func handleGetSeries(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, table string) {
+	ctx := r.Context()
+
+	rawBucket := r.URL.Query().Get("bucket")
+	bucket, err := parseSeriesBucket(rawBucket)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusBadRequest, fmt.Errorf("failed to parse param bucket=%s: %v", rawBucket, err))
+		return
+	}
+
+	column := r.URL.Query().Get("column")
+	if column == "" {
+		column = getDefaultSeriesColumn(table)
+	}
+
+	dataType, ok := getColumnDataType(table, column)
+	if !ok || !isTimeDataType(dataType) {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse param column=%s: not a time column of %s", column, table))
+		return
+	}
+
+	rawGroupBy := r.URL.Query()["group_by"]
+	groupBy, err := parseGroupBy(table, rawGroupBy)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse param group_by=%s: %v", strings.Join(rawGroupBy, ","), err))
+		return
+	}
+
+	from, err := parseTrackTime(r, "from")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	to, err := parseTrackTime(r, "to")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	wheres, values, err := getQueryFilterWheres(table, r.URL.Query(), "bucket", "column", "group_by", "from", "to")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	requestHash, err := getDerivedRequestHash(
+		table,
+		wheres,
+		values,
+		"SERIES",
+		fmt.Sprintf("BUCKET %v", bucket),
+		fmt.Sprintf("COLUMN %v", column),
+		fmt.Sprintf("GROUP BY %s", strings.Join(groupBy, ", ")),
+		fmt.Sprintf("FROM %v", helpers.Deref(from)),
+		fmt.Sprintf("TO %v", helpers.Deref(to)),
+	)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	buckets, err := GetSeries(ctx, tx, table, column, bucket, groupBy, from, to, wheres, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, buckets)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package extensions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/initialed85/djangolang/pkg/helpers"
+)
+
+func TestParseSeriesBucket(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rawBucket string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{name: "hour", rawBucket: "1h", expected: time.Hour},
+		{name: "mixed units", rawBucket: "1m30s", expected: time.Second * 90},
+		{name: "whole seconds in milliseconds", rawBucket: "2000ms", expected: time.Second * 2},
+		{name: "empty", rawBucket: "", expectErr: true},
+		{name: "not a duration", rawBucket: "hourly", expectErr: true},
+		{name: "zero", rawBucket: "0s", expectErr: true},
+		{name: "negative", rawBucket: "-1h", expectErr: true},
+		{name: "fraction of a second", rawBucket: "1500ms", expectErr: true},
+		{name: "less than a second", rawBucket: "1ns", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			bucket, err := parseSeriesBucket(testCase.rawBucket)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %v", bucket)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if bucket != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, bucket)
+			}
+		})
+	}
+}
+
+func TestGetSeriesBucketStart(t *testing.T) {
+	testCases := []struct {
+		name     string
+		t        time.Time
+		bucket   time.Duration
+		expected time.Time
+	}{
+		{
+			name:     "on the hour",
+			t:        time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC),
+			bucket:   time.Hour,
+			expected: time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "within the hour",
+			t:        time.Date(2024, 1, 2, 3, 59, 59, 999999999, time.UTC),
+			bucket:   time.Hour,
+			expected: time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "odd bucket aligned to the epoch",
+			t:        time.Date(1970, 1, 1, 0, 16, 0, 0, time.UTC),
+			bucket:   time.Minute * 7,
+			expected: time.Date(1970, 1, 1, 0, 14, 0, 0, time.UTC),
+		},
+		{
+			name:     "before the epoch",
+			t:        time.Date(1969, 12, 31, 23, 30, 0, 0, time.UTC),
+			bucket:   time.Hour,
+			expected: time.Date(1969, 12, 31, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "other time zone",
+			t:        time.Date(2024, 1, 2, 3, 30, 0, 0, time.FixedZone("ACST", 34200)),
+			bucket:   time.Hour,
+			expected: time.Date(2024, 1, 2, 3, 30, 0, 0, time.FixedZone("ACST", 34200)),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			start := getSeriesBucketStart(testCase.t, testCase.bucket)
+			if !start.Equal(testCase.expected) {
+				t.Fatalf("expected %v but got %v", testCase.expected, start)
+			}
+		})
+	}
+}
+
+func TestZeroFillSeries(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2024, 1, 2, hour, 0, 0, 0, time.UTC)
+	}
+
+	type expectedBucket struct {
+		hour  int
+		group map[string]any
+		count int64
+	}
+
+	a := map[string]any{"type": "A"}
+	b := map[string]any{"type": "B"}
+
+	testCases := []struct {
+		name      string
+		groups    []*seriesGroup
+		from      *time.Time
+		to        *time.Time
+		expected  []expectedBucket
+		expectErr bool
+	}{
+		{
+			name:     "gaps between the first and the last",
+			groups:   []*seriesGroup{{values: map[string]any{}, countByAt: map[time.Time]int64{at(1): 2, at(4): 3}}},
+			expected: []expectedBucket{{1, map[string]any{}, 2}, {2, map[string]any{}, 0}, {3, map[string]any{}, 0}, {4, map[string]any{}, 3}},
+		},
+		{
+			name:     "from and to widen the window",
+			groups:   []*seriesGroup{{values: map[string]any{}, countByAt: map[time.Time]int64{at(2): 1}}},
+			from:     helpers.Ptr(at(1).Add(time.Minute * 30)),
+			to:       helpers.Ptr(at(4)),
+			expected: []expectedBucket{{1, map[string]any{}, 0}, {2, map[string]any{}, 1}, {3, map[string]any{}, 0}},
+		},
+		{
+			name:     "window with no rows",
+			groups:   []*seriesGroup{{values: map[string]any{}, countByAt: map[time.Time]int64{}}},
+			from:     helpers.Ptr(at(1)),
+			to:       helpers.Ptr(at(3)),
+			expected: []expectedBucket{{1, map[string]any{}, 0}, {2, map[string]any{}, 0}},
+		},
+		{
+			name:     "no rows and no window",
+			groups:   []*seriesGroup{{values: map[string]any{}, countByAt: map[time.Time]int64{}}},
+			expected: []expectedBucket{},
+		},
+		{
+			name:     "to before from",
+			groups:   []*seriesGroup{{values: map[string]any{}, countByAt: map[time.Time]int64{}}},
+			from:     helpers.Ptr(at(3)),
+			to:       helpers.Ptr(at(1)),
+			expected: []expectedBucket{},
+		},
+		{
+			name: "each group spans all of the groups",
+			groups: []*seriesGroup{
+				{values: a, countByAt: map[time.Time]int64{at(1): 1}},
+				{values: b, countByAt: map[time.Time]int64{at(3): 2}},
+			},
+			expected: []expectedBucket{{1, a, 1}, {2, a, 0}, {3, a, 0}, {1, b, 0}, {2, b, 0}, {3, b, 2}},
+		},
+		{
+			name:      "too many buckets",
+			groups:    []*seriesGroup{{values: map[string]any{}, countByAt: map[time.Time]int64{}}},
+			from:      helpers.Ptr(at(0)),
+			to:        helpers.Ptr(at(0).Add(time.Hour * (maxSeriesBuckets + 1))),
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			buckets, err := zeroFillSeries(testCase.groups, time.Hour, testCase.from, testCase.to)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %d buckets", len(buckets))
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := make([]*SeriesBucket, 0)
+			for _, e := range testCase.expected {
+				expected = append(expected, &SeriesBucket{Bucket: at(e.hour), Group: e.group, Count: e.count})
+			}
+
+			if !reflect.DeepEqual(buckets, expected) {
+				t.Fatalf("expected %#+v but got %#+v", expected, buckets)
+			}
+		})
+	}
+}
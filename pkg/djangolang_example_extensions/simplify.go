@@ -0,0 +1,169 @@
+package extensions
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// reductionQueryParams are the list params that reduce a track (only supported for location_history)
+var reductionQueryParams = []string{"simplify", "sample"}
+
+// reduction is the downsampling (sample param) and / or simplification (simplify param) to apply to a track
+type reduction struct {
+	sample    time.Duration
+	tolerance float64
+}
+
+// parseReduction returns nil if neither the sample nor the simplify params are given
+func parseReduction(query url.Values) (*reduction, error) {
+	rawSample := query.Get("sample")
+	rawSimplify := query.Get("simplify")
+
+	if rawSample == "" && rawSimplify == "" {
+		return nil, nil
+	}
+
+	r := &reduction{}
+
+	if rawSample != "" {
+		sample, err := time.ParseDuration(rawSample)
+		if err != nil || sample <= 0 {
+			return nil, fmt.Errorf("failed to parse param sample=%s as positive duration: %v", rawSample, err)
+		}
+
+		r.sample = sample
+	}
+
+	if rawSimplify != "" {
+		tolerance, err := strconv.ParseFloat(rawSimplify, 64)
+		if err != nil || tolerance < 0 || math.IsInf(tolerance, 0) || math.IsNaN(tolerance) {
+			return nil, fmt.Errorf("failed to parse param simplify=%s as non-negative number: %v", rawSimplify, err)
+		}
+
+		r.tolerance = tolerance
+	}
+
+	return r, nil
+}
+
+// isFilteredToSingleParent is true if the list request (as the query and any wheres from a nested route) can only
+// match the rows referring to a single row via the given foreign key column
+func isFilteredToSingleParent(query url.Values, parentWheres []string, column string) bool {
+	if slices.Contains(parentWheres, fmt.Sprintf("%s = $$??", column)) {
+		return true
+	}
+
+	return len(query[fmt.Sprintf("%s__eq", column)]) == 1
+}
+
+// sampleLocationHistorys keeps the first of objects (which must be in time order) in each interval-long time bucket
+func sampleLocationHistorys(objects []*djangolang_example.LocationHistory, interval time.Duration) []*djangolang_example.LocationHistory {
+	sampledObjects := make([]*djangolang_example.LocationHistory, 0)
+
+	var lastBucket *time.Time
+	for _, object := range objects {
+		bucket := object.Timestamp.Truncate(interval)
+		if lastBucket != nil && bucket.Equal(*lastBucket) {
+			continue
+		}
+
+		lastBucket = &bucket
+		sampledObjects = append(sampledObjects, object)
+	}
+
+	return sampledObjects
+}
+
+// getSegmentDistance is the distance from p to the closest point of the line segment from a to b
+func getSegmentDistance(p pgtype.Vec2, a pgtype.Vec2, b pgtype.Vec2) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return math.Hypot(p.X-a.X, p.Y-a.Y)
+	}
+
+	t := max(0, min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lengthSquared))
+
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
+
+// simplifyLocationHistorys applies Douglas-Peucker to the points of objects (which must be in time order and all have a
+// Point), dropping those that are within tolerance of the simplified line
+func simplifyLocationHistorys(objects []*djangolang_example.LocationHistory, tolerance float64) []*djangolang_example.LocationHistory {
+	if len(objects) < 3 {
+		return objects
+	}
+
+	keep := make([]bool, len(objects))
+	keep[0] = true
+	keep[len(objects)-1] = true
+
+	// note: iterative rather than recursive, as tracks can be long enough to make the recursion deep
+	type span struct {
+		first int
+		last  int
+	}
+
+	spans := []span{{first: 0, last: len(objects) - 1}}
+
+	for len(spans) > 0 {
+		s := spans[len(spans)-1]
+		spans = spans[:len(spans)-1]
+
+		furthest := -1
+		furthestDistance := 0.0
+
+		for i := s.first + 1; i < s.last; i++ {
+			distance := getSegmentDistance(*objects[i].Point, *objects[s.first].Point, *objects[s.last].Point)
+			if distance > furthestDistance {
+				furthest = i
+				furthestDistance = distance
+			}
+		}
+
+		if furthest == -1 || furthestDistance <= tolerance {
+			continue
+		}
+
+		keep[furthest] = true
+		spans = append(spans, span{first: s.first, last: furthest}, span{first: furthest, last: s.last})
+	}
+
+	simplifiedObjects := make([]*djangolang_example.LocationHistory, 0)
+	for i, object := range objects {
+		if keep[i] {
+			simplifiedObjects = append(simplifiedObjects, object)
+		}
+	}
+
+	return simplifiedObjects
+}
+
+// reduceLocationHistorys downsamples and then simplifies objects (which must be in time order and all have a Point)
+func reduceLocationHistorys(objects []*djangolang_example.LocationHistory, r *reduction) []*djangolang_example.LocationHistory {
+	if r.sample > 0 {
+		objects = sampleLocationHistorys(objects, r.sample)
+	}
+
+	if r.tolerance > 0 {
+		objects = simplifyLocationHistorys(objects, r.tolerance)
+	}
+
+	return objects
+}
+
+// paginate is LIMIT / OFFSET for objects that have already been selected
+func paginate[T any](objects []T, limit int, offset int) []T {
+	start := max(0, min(offset, len(objects)))
+	end := max(start, min(start+limit, len(objects)))
+
+	return objects[start:end]
+}
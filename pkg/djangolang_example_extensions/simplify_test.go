@@ -0,0 +1,194 @@
+package extensions
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// getTestLocationHistorys returns a location history per point, a second apart (in order)
+func getTestLocationHistorys(points ...pgtype.Vec2) []*djangolang_example.LocationHistory {
+	objects := make([]*djangolang_example.LocationHistory, 0)
+
+	for i := range points {
+		objects = append(objects, &djangolang_example.LocationHistory{
+			Timestamp: time.Unix(1700000000, 0).UTC().Add(time.Second * time.Duration(i)),
+			Point:     &points[i],
+		})
+	}
+
+	return objects
+}
+
+func getTestPoints(objects []*djangolang_example.LocationHistory) []pgtype.Vec2 {
+	points := make([]pgtype.Vec2, 0)
+	for _, object := range objects {
+		points = append(points, *object.Point)
+	}
+
+	return points
+}
+
+func TestGetSegmentDistance(t *testing.T) {
+	testCases := []struct {
+		name     string
+		p        pgtype.Vec2
+		a        pgtype.Vec2
+		b        pgtype.Vec2
+		expected float64
+	}{
+		{name: "on the segment", p: pgtype.Vec2{X: 1, Y: 0}, a: pgtype.Vec2{X: 0, Y: 0}, b: pgtype.Vec2{X: 2, Y: 0}, expected: 0},
+		{name: "beside the segment", p: pgtype.Vec2{X: 1, Y: 3}, a: pgtype.Vec2{X: 0, Y: 0}, b: pgtype.Vec2{X: 2, Y: 0}, expected: 3},
+		{name: "beyond the end", p: pgtype.Vec2{X: 5, Y: 4}, a: pgtype.Vec2{X: 0, Y: 0}, b: pgtype.Vec2{X: 2, Y: 0}, expected: 5},
+		{name: "before the start", p: pgtype.Vec2{X: -3, Y: -4}, a: pgtype.Vec2{X: 0, Y: 0}, b: pgtype.Vec2{X: 2, Y: 0}, expected: 5},
+		{name: "zero-length segment", p: pgtype.Vec2{X: 3, Y: 4}, a: pgtype.Vec2{X: 0, Y: 0}, b: pgtype.Vec2{X: 0, Y: 0}, expected: 5},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			distance := getSegmentDistance(testCase.p, testCase.a, testCase.b)
+			if math.Abs(distance-testCase.expected) > 1e-9 {
+				t.Fatalf("expected %v but got %v", testCase.expected, distance)
+			}
+		})
+	}
+}
+
+func TestSimplifyLocationHistorys(t *testing.T) {
+	testCases := []struct {
+		name      string
+		points    []pgtype.Vec2
+		tolerance float64
+		expected  []pgtype.Vec2
+	}{
+		{
+			name:      "too few points",
+			points:    []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 5}},
+			tolerance: 1,
+			expected:  []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 5}},
+		},
+		{
+			name:      "straight line",
+			points:    []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0}, {X: 3, Y: 0}},
+			tolerance: 0,
+			expected:  []pgtype.Vec2{{X: 0, Y: 0}, {X: 3, Y: 0}},
+		},
+		{
+			name:      "wobble within tolerance",
+			points:    []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0.1}, {X: 2, Y: -0.1}, {X: 3, Y: 0}},
+			tolerance: 0.5,
+			expected:  []pgtype.Vec2{{X: 0, Y: 0}, {X: 3, Y: 0}},
+		},
+		{
+			name:      "corner kept",
+			points:    []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0.1}, {X: 2, Y: 0}, {X: 2, Y: 1}, {X: 2.1, Y: 2}, {X: 2, Y: 3}},
+			tolerance: 0.5,
+			expected:  []pgtype.Vec2{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 3}},
+		},
+		{
+			name:      "tolerance at the distance drops the point",
+			points:    []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 0}},
+			tolerance: 1,
+			expected:  []pgtype.Vec2{{X: 0, Y: 0}, {X: 2, Y: 0}},
+		},
+		{
+			name:      "tolerance under the distance keeps the point",
+			points:    []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 0}},
+			tolerance: 0.99,
+			expected:  []pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 0}},
+		},
+		{
+			name:      "there and back",
+			points:    []pgtype.Vec2{{X: 0, Y: 0}, {X: 5, Y: 0}, {X: 10, Y: 0}, {X: 5, Y: 0}, {X: 0, Y: 0}},
+			tolerance: 1,
+			expected:  []pgtype.Vec2{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 0, Y: 0}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			objects := simplifyLocationHistorys(getTestLocationHistorys(testCase.points...), testCase.tolerance)
+
+			points := getTestPoints(objects)
+			if !reflect.DeepEqual(points, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, points)
+			}
+
+			for i := 1; i < len(objects); i++ {
+				if !objects[i].Timestamp.After(objects[i-1].Timestamp) {
+					t.Fatalf("expected the points to stay in time order")
+				}
+			}
+		})
+	}
+}
+
+func TestSampleLocationHistorys(t *testing.T) {
+	objects := getTestLocationHistorys(
+		pgtype.Vec2{X: 0}, pgtype.Vec2{X: 1}, pgtype.Vec2{X: 2}, pgtype.Vec2{X: 3}, pgtype.Vec2{X: 4}, pgtype.Vec2{X: 5},
+	)
+
+	testCases := []struct {
+		name     string
+		interval time.Duration
+		expected []pgtype.Vec2
+	}{
+		{name: "shorter than the gaps", interval: time.Millisecond * 500, expected: getTestPoints(objects)},
+		{name: "two per bucket", interval: time.Second * 2, expected: []pgtype.Vec2{{X: 0}, {X: 2}, {X: 4}}},
+		{name: "one bucket", interval: time.Hour, expected: []pgtype.Vec2{{X: 0}}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			points := getTestPoints(sampleLocationHistorys(objects, testCase.interval))
+			if !reflect.DeepEqual(points, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, points)
+			}
+		})
+	}
+}
+
+func TestParseReduction(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     url.Values
+		expected  *reduction
+		expectErr bool
+	}{
+		{name: "neither", query: url.Values{}, expected: nil},
+		{name: "sample", query: url.Values{"sample": {"30s"}}, expected: &reduction{sample: time.Second * 30}},
+		{name: "simplify", query: url.Values{"simplify": {"0.001"}}, expected: &reduction{tolerance: 0.001}},
+		{name: "both", query: url.Values{"sample": {"1m"}, "simplify": {"2"}}, expected: &reduction{sample: time.Minute, tolerance: 2}},
+		{name: "zero sample", query: url.Values{"sample": {"0s"}}, expectErr: true},
+		{name: "bad sample", query: url.Values{"sample": {"soon"}}, expectErr: true},
+		{name: "negative simplify", query: url.Values{"simplify": {"-1"}}, expectErr: true},
+		{name: "infinite simplify", query: url.Values{"simplify": {"Inf"}}, expectErr: true},
+		{name: "NaN simplify", query: url.Values{"simplify": {"NaN"}}, expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r, err := parseReduction(testCase.query)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", r)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(r, testCase.expected) {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, r)
+			}
+		})
+	}
+}
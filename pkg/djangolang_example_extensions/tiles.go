@@ -0,0 +1,443 @@
+package extensions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang/pkg/stream"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jmoiron/sqlx"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/mvt"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+)
+
+const contentTypeApplicationMVT = "application/vnd.mapbox-vector-tile"
+
+// locationHistoryTilesCachePrefix namespaces the cached tiles; it deliberately isn't "location_history:" so that the
+// tiles aren't thrown away by the CDC change stream on every change, only those that a write touches (see
+// invalidateTiles)
+const locationHistoryTilesCachePrefix = "location_history_tiles"
+
+// locationHistoryTilesVersionPrefix namespaces the version numbers of the zoom levels and tiles (see getTileVersion)
+const locationHistoryTilesVersionPrefix = "location_history_tiles_version"
+
+// tileVersionExpiry is how long (in seconds) a version number is kept after it's last used; it's twice as long as a
+// cached response is kept
+const tileVersionExpiry = 2 * 86400
+
+// locationHistoryTileLayer is the name of the layer (in each tile) that holds the location_history rows
+const locationHistoryTileLayer = "location_history"
+
+// maxTileZoom is the deepest zoom level that tiles are served for
+const maxTileZoom = 22
+
+// maxTileFeatures is the most rows that go into a single tile (the most recent ones win)
+const maxTileFeatures = 10000
+
+// maxInvalidatedTilesPerZoom is the most tiles (at a single zoom level) that a write invalidates one by one; past that
+// the whole zoom level is invalidated instead
+const maxInvalidatedTilesPerZoom = 16
+
+// tileBuffer is how far (as a fraction of the tile size) the features of a tile extend past its edges, so that things
+// drawn on the edge of a tile (e.g. point icons) aren't cut off
+const tileBuffer = 1.0 / 16
+
+var tileClipBound = orb.Bound{
+	Min: orb.Point{-mvt.DefaultExtent * tileBuffer, -mvt.DefaultExtent * tileBuffer},
+	Max: orb.Point{mvt.DefaultExtent * (1 + tileBuffer), mvt.DefaultExtent * (1 + tileBuffer)},
+}
+
+// getTileBound returns the extent (longitude / latitude) of the tile, plus the buffer
+func getTileBound(tile maptile.Tile) orb.Bound {
+	return tile.Bound(tileBuffer)
+}
+
+// getTileRange returns the columns and rows of the tiles (at the given zoom) whose extent (plus the buffer) overlaps bound
+func getTileRange(bound orb.Bound, z maptile.Zoom) (uint32, uint32, uint32, uint32) {
+	// note: rows count down from the north, so the max latitude gives the min row
+	minFraction := maptile.Fraction(orb.Point{bound.Min.X(), bound.Max.Y()}, z)
+	maxFraction := maptile.Fraction(orb.Point{bound.Max.X(), bound.Min.Y()}, z)
+
+	clamp := func(v float64) uint32 {
+		return uint32(max(0, min(float64(uint32(1)<<z-1), math.Floor(v))))
+	}
+
+	return clamp(minFraction.X() - tileBuffer),
+		clamp(minFraction.Y() - tileBuffer),
+		clamp(maxFraction.X() + tileBuffer),
+		clamp(maxFraction.Y() + tileBuffer)
+}
+
+func getTileWheres(tile maptile.Tile) ([]string, []any) {
+	bound := getTileBound(tile)
+
+	box := fmt.Sprintf(
+		"(%s,%s),(%s,%s)",
+		formatFloat(bound.Min.X()),
+		formatFloat(bound.Min.Y()),
+		formatFloat(bound.Max.X()),
+		formatFloat(bound.Max.Y()),
+	)
+
+	return []string{
+		fmt.Sprintf(
+			"(%s <@ $$??::box OR %s && polygon($$??::box))",
+			formatColumn("", djangolang_example.LocationHistoryTablePointColumn),
+			formatColumn("", djangolang_example.LocationHistoryTablePolygonColumn),
+		),
+	}, []any{box, box}
+}
+
+// getTileFeatures turns the objects into features for a tile, one per point / polygon, with the scalar columns as
+// properties (as the MVT format doesn't support nested properties)
+func getTileFeatures(objects []*djangolang_example.LocationHistory) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+
+	for _, object := range objects {
+		geometries := make([]orb.Geometry, 0)
+
+		if object.Point != nil {
+			geometries = append(geometries, orb.Point{object.Point.X, object.Point.Y})
+		}
+
+		if object.Polygon != nil {
+			geometries = append(geometries, orb.Polygon{getRing(*object.Polygon)})
+		}
+
+		for _, geometry := range geometries {
+			feature := geojson.NewFeature(geometry)
+			feature.Properties[djangolang_example.LocationHistoryTableIDColumn] = object.ID.String()
+			feature.Properties[djangolang_example.LocationHistoryTableTimestampColumn] = object.Timestamp.Format(time.RFC3339Nano)
+
+			if object.ParentPhysicalThingID != nil {
+				feature.Properties[djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn] = object.ParentPhysicalThingID.String()
+			}
+
+			fc.Append(feature)
+		}
+	}
+
+	return fc
+}
+
+// GetLocationHistoryTile returns the given tile (as an uncompressed Mapbox Vector Tile) of the location_history rows
+// matching the wheres; the coordinates are taken to be longitude (x) and latitude (y)
+func GetLocationHistoryTile(ctx context.Context, tx *sqlx.Tx, tile maptile.Tile, wheres []string, values ...any) ([]byte, error) {
+	tileWheres, tileValues := getTileWheres(tile)
+
+	wheres = append(wheres, tileWheres...)
+	values = append(values, tileValues...)
+
+	orderBy := formatOrderBy(
+		[]orderByColumn{
+			{Column: djangolang_example.LocationHistoryTableTimestampColumn, Descending: true},
+			{Column: djangolang_example.LocationHistoryTablePrimaryKeyColumn, Descending: true},
+		},
+		false,
+		nil,
+	)
+
+	objects, err := SelectLocationHistorysWithOptions(
+		WithExpansionDepth(ctx, 0),
+		tx,
+		strings.Join(wheres, "\n    AND "),
+		SelectOptions{OrderBy: &orderBy, Limit: helpers.Ptr(maxTileFeatures)},
+		values...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := mvt.Layers{mvt.NewLayer(locationHistoryTileLayer, getTileFeatures(objects))}
+	layers.ProjectToTile(tile)
+	layers.Clip(tileClipBound)
+
+	b, err := mvt.Marshal(layers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tile %v/%v/%v; err: %v", tile.Z, tile.X, tile.Y, err)
+	}
+
+	return b, nil
+}
+
+func parseTile(rawZ string, rawX string, rawY string) (maptile.Tile, error) {
+	z, err := strconv.ParseUint(rawZ, 10, 32)
+	if err != nil || z > maxTileZoom {
+		return maptile.Tile{}, fmt.Errorf("failed to parse z=%s as zoom between 0 and %d: %v", rawZ, maxTileZoom, err)
+	}
+
+	x, err := strconv.ParseUint(rawX, 10, 32)
+	if err != nil || x >= 1<<z {
+		return maptile.Tile{}, fmt.Errorf("failed to parse x=%s as tile column at zoom %d: %v", rawX, z, err)
+	}
+
+	y, err := strconv.ParseUint(rawY, 10, 32)
+	if err != nil || y >= 1<<z {
+		return maptile.Tile{}, fmt.Errorf("failed to parse y=%s as tile row at zoom %d: %v", rawY, z, err)
+	}
+
+	return maptile.New(uint32(x), uint32(y), maptile.Zoom(z)), nil
+}
+
+// getTileVersionKeys returns the Redis keys of the version numbers of the tile's zoom level and of the tile itself
+func getTileVersionKeys(tile maptile.Tile) (string, string) {
+	return fmt.Sprintf("%s:%d", locationHistoryTilesVersionPrefix, tile.Z),
+		fmt.Sprintf("%s:%d:%d:%d", locationHistoryTilesVersionPrefix, tile.Z, tile.X, tile.Y)
+}
+
+// getTileVersion returns the current version of the tile (as the version of its zoom level and its own version); the
+// version is part of the cache key, so bumping either one invalidates the cached tile without having to find it
+func getTileVersion(redisConn redis.Conn, tile maptile.Tile) (string, error) {
+	if redisConn == nil {
+		return "0.0", nil
+	}
+
+	zoomVersionKey, tileVersionKey := getTileVersionKeys(tile)
+
+	versions, err := redis.Int64s(redisConn.Do("MGET", zoomVersionKey, tileVersionKey))
+	if err != nil {
+		return "", fmt.Errorf("failed redis get for %v, %v: %v", zoomVersionKey, tileVersionKey, err)
+	}
+
+	// note: the version keys must outlive any tile cached under them, else a version could come around again (after the
+	// key expired and was bumped back up) and serve a stale tile
+	for _, key := range []string{zoomVersionKey, tileVersionKey} {
+		_, err = redisConn.Do("EXPIRE", key, tileVersionExpiry)
+		if err != nil {
+			return "", fmt.Errorf("failed redis expire for %v: %v", key, err)
+		}
+	}
+
+	return fmt.Sprintf("%d.%d", versions[0], versions[1]), nil
+}
+
+func getTileCachePrefix(tile maptile.Tile, version string) string {
+	return fmt.Sprintf("%s:%d:%d:%d:%s", locationHistoryTilesCachePrefix, tile.Z, tile.X, tile.Y, version)
+}
+
+func bumpTileVersion(redisConn redis.Conn, key string) error {
+	_, err := redisConn.Do("INCR", key)
+	if err != nil {
+		return fmt.Errorf("failed redis incr for %v: %v", key, err)
+	}
+
+	_, err = redisConn.Do("EXPIRE", key, tileVersionExpiry)
+	if err != nil {
+		return fmt.Errorf("failed redis expire for %v: %v", key, err)
+	}
+
+	return nil
+}
+
+// getLocationHistoryBound returns the extent of the point and / or polygon of the object; false if it has neither
+func getLocationHistoryBound(object *djangolang_example.LocationHistory) (orb.Bound, bool) {
+	bound, ok := orb.Bound{}, false
+
+	if object.Point != nil {
+		bound, ok = orb.Point{object.Point.X, object.Point.Y}.Bound(), true
+	}
+
+	if object.Polygon != nil && len(*object.Polygon) > 0 {
+		polygonBound := getRing(*object.Polygon).Bound()
+		if ok {
+			bound = bound.Union(polygonBound)
+		} else {
+			bound, ok = polygonBound, true
+		}
+	}
+
+	return bound, ok
+}
+
+// invalidateTiles bumps the version of each tile that overlaps any of the bounds (or, where that's a lot of tiles, the
+// version of the whole zoom level)
+func invalidateTiles(redisConn redis.Conn, bounds ...orb.Bound) error {
+	if redisConn == nil || len(bounds) == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0)
+
+	for z := maptile.Zoom(0); z <= maxTileZoom; z++ {
+		tiles := make(map[maptile.Tile]struct{})
+
+		for _, bound := range bounds {
+			minX, minY, maxX, maxY := getTileRange(bound, z)
+
+			if uint64(maxX-minX+1)*uint64(maxY-minY+1) > maxInvalidatedTilesPerZoom {
+				tiles = nil
+				break
+			}
+
+			for x := minX; x <= maxX; x++ {
+				for y := minY; y <= maxY; y++ {
+					tiles[maptile.New(x, y, z)] = struct{}{}
+				}
+			}
+		}
+
+		if tiles == nil || len(tiles) > maxInvalidatedTilesPerZoom {
+			zoomVersionKey, _ := getTileVersionKeys(maptile.New(0, 0, z))
+
+			err := bumpTileVersion(redisConn, zoomVersionKey)
+			if err != nil {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		for tile := range tiles {
+			_, tileVersionKey := getTileVersionKeys(tile)
+
+			err := bumpTileVersion(redisConn, tileVersionKey)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// invalidateLocationHistoryObjectTiles invalidates the tiles that any of the given objects (e.g. the old and new
+// versions of an updated row) appear in
+func invalidateLocationHistoryObjectTiles(redisConn redis.Conn, objects ...*djangolang_example.LocationHistory) error {
+	bounds := make([]orb.Bound, 0)
+
+	for _, object := range objects {
+		if object == nil {
+			continue
+		}
+
+		bound, ok := getLocationHistoryBound(object)
+		if ok {
+			bounds = append(bounds, bound)
+		}
+	}
+
+	return invalidateTiles(redisConn, bounds...)
+}
+
+// invalidateLocationHistoryTiles invalidates the tiles that the change touches, for the writes that don't go through
+// the handlers here (which invalidate the tiles of the old and new versions of the rows they write themselves), e.g. the
+// soft-deletes cascading from a physical thing; the CDC change stream only carries the new version of a row, so only
+// its tiles are invalidated for an update, and a hard delete (which carries no geometry) invalidates every tile
+func invalidateLocationHistoryTiles(redisConn redis.Conn, change server.Change) error {
+	if change.TableName != djangolang_example.LocationHistoryTable || redisConn == nil {
+		return nil
+	}
+
+	object, ok := change.Object.(*djangolang_example.LocationHistory)
+	if !ok || change.Action == stream.DELETE {
+		errs := make([]error, 0)
+
+		for z := maptile.Zoom(0); z <= maxTileZoom; z++ {
+			zoomVersionKey, _ := getTileVersionKeys(maptile.New(0, 0, z))
+
+			err := bumpTileVersion(redisConn, zoomVersionKey)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	return invalidateLocationHistoryObjectTiles(redisConn, object)
+}
+
+func handleGetLocationHistoryTile(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, rawZ string, rawX string, rawY string) {
+	ctx := r.Context()
+
+	tile, err := parseTile(rawZ, rawX, rawY)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	wheres, values, err := getQueryFilterWheres(djangolang_example.LocationHistoryTable, r.URL.Query(), "include_deleted", "only_deleted")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	deletedWhere, err := getDeletedWhere(r, djangolang_example.LocationHistoryTable, wheres)
+	if err != nil {
+		helpers.HandleErrorResponse(w, getDeletedRowsStatus(err), err)
+		return
+	}
+
+	if deletedWhere != "" {
+		wheres = append(wheres, deletedWhere)
+	}
+
+	version, err := getTileVersion(redisConn, tile)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	requestHash, err := getRequestHash(getTileCachePrefix(tile, version), wheres, 0, 0, values, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// a cache hit is written straight out, so it needs to be labelled up front
+	w.Header().Set("Content-Type", contentTypeApplicationMVT)
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	w.Header().Del("Content-Type")
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	b, err := GetLocationHistoryTile(ctx, tx, tile, wheres, values...)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeApplicationMVT)
+	helpers.WriteResponse(w, http.StatusOK, b)
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(b))
+	if err != nil {
+		log.Printf("warning: failed to store tile %v/%v/%v in cache: %v", tile.Z, tile.X, tile.Y, err)
+	}
+}
@@ -0,0 +1,242 @@
+package extensions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+)
+
+func TestParseTile(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rawZ      string
+		rawX      string
+		rawY      string
+		expected  maptile.Tile
+		expectErr bool
+	}{
+		{name: "world", rawZ: "0", rawX: "0", rawY: "0", expected: maptile.New(0, 0, 0)},
+		{name: "last tile of a zoom", rawZ: "2", rawX: "3", rawY: "3", expected: maptile.New(3, 3, 2)},
+		{name: "max zoom", rawZ: "22", rawX: "0", rawY: "0", expected: maptile.New(0, 0, 22)},
+		{name: "zoom too deep", rawZ: "23", rawX: "0", rawY: "0", expectErr: true},
+		{name: "column out of range", rawZ: "2", rawX: "4", rawY: "0", expectErr: true},
+		{name: "row out of range", rawZ: "2", rawX: "0", rawY: "4", expectErr: true},
+		{name: "negative", rawZ: "2", rawX: "-1", rawY: "0", expectErr: true},
+		{name: "not a number", rawZ: "z", rawX: "0", rawY: "0", expectErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tile, err := parseTile(testCase.rawZ, testCase.rawX, testCase.rawY)
+			if testCase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %#+v", tile)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tile != testCase.expected {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, tile)
+			}
+		})
+	}
+}
+
+func TestGetTileWheres(t *testing.T) {
+	wheres, values := getTileWheres(maptile.New(1, 0, 1))
+
+	expectedWheres := []string{`("point" <@ $$??::box OR "polygon" && polygon($$??::box))`}
+	if !reflect.DeepEqual(wheres, expectedWheres) {
+		t.Fatalf("expected %#+v but got %#+v", expectedWheres, wheres)
+	}
+
+	// the north east quarter of the world, plus the buffer
+	bound := getTileBound(maptile.New(1, 0, 1))
+	if bound.Min.X() >= 0 || bound.Max.X() <= 180 || bound.Min.Y() >= 0 || bound.Max.Y() < 85 {
+		t.Fatalf("expected the north east quarter plus a buffer but got %#+v", bound)
+	}
+
+	if len(values) != 2 || values[0] != values[1] {
+		t.Fatalf("expected the same box twice but got %#+v", values)
+	}
+}
+
+func TestGetTileRange(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bound    orb.Bound
+		z        maptile.Zoom
+		expected [4]uint32
+	}{
+		{
+			name:     "world at zoom 0",
+			bound:    orb.Bound{Min: orb.Point{-180, -85}, Max: orb.Point{180, 85}},
+			z:        0,
+			expected: [4]uint32{0, 0, 0, 0},
+		},
+		{
+			name:     "point in the middle of a tile",
+			bound:    orb.Point{112.5, 45}.Bound(),
+			z:        2,
+			expected: [4]uint32{3, 1, 3, 1},
+		},
+		{
+			name:     "point on the corner of four tiles is in the buffer of each",
+			bound:    orb.Point{0, 0}.Bound(),
+			z:        1,
+			expected: [4]uint32{0, 0, 1, 1},
+		},
+		{
+			name:     "clamped at the edges of the world",
+			bound:    orb.Bound{Min: orb.Point{-180, -89}, Max: orb.Point{180, 89}},
+			z:        3,
+			expected: [4]uint32{0, 0, 7, 7},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			minX, minY, maxX, maxY := getTileRange(testCase.bound, testCase.z)
+
+			tileRange := [4]uint32{minX, minY, maxX, maxY}
+			if tileRange != testCase.expected {
+				t.Fatalf("expected %#+v but got %#+v", testCase.expected, tileRange)
+			}
+		})
+	}
+}
+
+func TestGetTileFeatures(t *testing.T) {
+	physicalThingID := uuid.New()
+
+	objects := []*djangolang_example.LocationHistory{
+		{ID: uuid.New(), Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Point: &pgtype.Vec2{X: 1, Y: 2}, ParentPhysicalThingID: &physicalThingID},
+		{ID: uuid.New(), Timestamp: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC), Point: &pgtype.Vec2{X: 1, Y: 2}, Polygon: &[]pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+		{ID: uuid.New(), Timestamp: time.Date(2024, 1, 2, 3, 4, 7, 0, time.UTC)},
+	}
+
+	fc := getTileFeatures(objects)
+
+	expectedGeometries := []orb.Geometry{
+		orb.Point{1, 2},
+		orb.Point{1, 2},
+		orb.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+	}
+
+	if len(fc.Features) != len(expectedGeometries) {
+		t.Fatalf("expected %d features but got %d", len(expectedGeometries), len(fc.Features))
+	}
+
+	for i, feature := range fc.Features {
+		if !reflect.DeepEqual(feature.Geometry, expectedGeometries[i]) {
+			t.Fatalf("feature %d: expected %#+v but got %#+v", i, expectedGeometries[i], feature.Geometry)
+		}
+
+		// properties have to be scalars for MVT
+		for k, v := range feature.Properties {
+			_, ok := v.(string)
+			if !ok {
+				t.Fatalf("feature %d: expected property %v to be a string but got %#+v", i, k, v)
+			}
+		}
+	}
+
+	expectedProperties := map[string]any{
+		"id":                       objects[0].ID.String(),
+		"timestamp":                "2024-01-02T03:04:05Z",
+		"parent_physical_thing_id": physicalThingID.String(),
+	}
+	if !reflect.DeepEqual(map[string]any(fc.Features[0].Properties), expectedProperties) {
+		t.Fatalf("expected %#+v but got %#+v", expectedProperties, fc.Features[0].Properties)
+	}
+}
+
+func TestGetLocationHistoryBound(t *testing.T) {
+	testCases := []struct {
+		name       string
+		object     *djangolang_example.LocationHistory
+		expected   orb.Bound
+		expectedOk bool
+	}{
+		{
+			name:   "neither",
+			object: &djangolang_example.LocationHistory{},
+		},
+		{
+			name:       "point",
+			object:     &djangolang_example.LocationHistory{Point: &pgtype.Vec2{X: 1, Y: 2}},
+			expected:   orb.Bound{Min: orb.Point{1, 2}, Max: orb.Point{1, 2}},
+			expectedOk: true,
+		},
+		{
+			name:       "polygon",
+			object:     &djangolang_example.LocationHistory{Polygon: &[]pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+			expected:   orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{1, 1}},
+			expectedOk: true,
+		},
+		{
+			name:       "both",
+			object:     &djangolang_example.LocationHistory{Point: &pgtype.Vec2{X: 5, Y: -1}, Polygon: &[]pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+			expected:   orb.Bound{Min: orb.Point{0, -1}, Max: orb.Point{5, 1}},
+			expectedOk: true,
+		},
+		{
+			name:   "empty polygon",
+			object: &djangolang_example.LocationHistory{Polygon: &[]pgtype.Vec2{}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			bound, ok := getLocationHistoryBound(testCase.object)
+			if ok != testCase.expectedOk || bound != testCase.expected {
+				t.Fatalf("expected %#+v, %v but got %#+v, %v", testCase.expected, testCase.expectedOk, bound, ok)
+			}
+		})
+	}
+}
+
+func TestHandleGetLocationHistoryTileDeletedRows(t *testing.T) {
+	t.Cleanup(func() {
+		SetDeletedRowsAuthorizer(nil)
+	})
+
+	SetDeletedRowsAuthorizer(nil)
+
+	testCases := []struct {
+		name     string
+		rawQuery string
+	}{
+		{name: "include_deleted", rawQuery: "include_deleted=true"},
+		{name: "only_deleted", rawQuery: "only_deleted=true"},
+		{name: "deleted_at filter", rawQuery: "deleted_at__isnull=false"},
+		{name: "deleted_at filter expression", rawQuery: "filter=deleted_at__gt:2024-01-01T00:00:00Z"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/location-histories/tiles/0/0/0.mvt?"+testCase.rawQuery, nil)
+
+			// the request is refused before the cache or the database are touched, so neither is needed
+			handleGetLocationHistoryTile(w, r, nil, nil, nil, "0", "0", "0")
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("expected %d but got %d", http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
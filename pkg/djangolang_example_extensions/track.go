@@ -0,0 +1,306 @@
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/initialed85/djangolang/pkg/helpers"
+	"github.com/initialed85/djangolang/pkg/server"
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultTrackStopSpeed is the speed (in metres per second) at or below which a physical thing counts as stopped, unless
+// otherwise specified by the stop_speed param; it's a slow walk, so that the jitter of a stationary GPS doesn't count as
+// movement
+const defaultTrackStopSpeed = 0.5
+
+// defaultTrackStopDuration is how long a physical thing has to stay (at or below the stop speed) for it to count as a
+// dwell, unless otherwise specified by the stop_duration param
+const defaultTrackStopDuration = time.Minute * 5
+
+// TrackOptions controls the dwell detection of a Track; a dwell is a run of segments at or below StopSpeed that lasts at
+// least StopDuration
+type TrackOptions struct {
+	StopSpeed    float64
+	StopDuration time.Duration
+}
+
+// TrackSegment is the movement between two consecutive points of a Track (which are taken to be longitude / latitude);
+// distance is in metres and speed is in metres per second
+type TrackSegment struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Distance float64   `json:"distance"`
+	Speed    float64   `json:"speed"`
+}
+
+// TrackDwell is a period during which a physical thing stayed put, as per TrackOptions
+type TrackDwell struct {
+	From     time.Time   `json:"from"`
+	To       time.Time   `json:"to"`
+	Duration float64     `json:"duration"`
+	Point    pgtype.Vec2 `json:"point"`
+}
+
+// Track is the points (in time order) of a physical thing along with some metrics derived from them
+type Track struct {
+	Points        []*djangolang_example.LocationHistory `json:"points"`
+	Segments      []*TrackSegment                       `json:"segments"`
+	Dwells        []*TrackDwell                         `json:"dwells"`
+	TotalDistance float64                               `json:"total_distance"`
+	MaxSpeed      float64                               `json:"max_speed"`
+}
+
+// NewTrack derives the segments, dwells and totals for the given points, which must be in time order; points without a
+// Point (i.e. polygons) are ignored
+func NewTrack(points []*djangolang_example.LocationHistory, options TrackOptions) *Track {
+	track := &Track{
+		Points:   make([]*djangolang_example.LocationHistory, 0),
+		Segments: make([]*TrackSegment, 0),
+		Dwells:   make([]*TrackDwell, 0),
+	}
+
+	for _, point := range points {
+		if point.Point == nil {
+			continue
+		}
+
+		track.Points = append(track.Points, point)
+	}
+
+	for i := 1; i < len(track.Points); i++ {
+		a, b := track.Points[i-1], track.Points[i]
+
+		segment := &TrackSegment{
+			From:     a.Timestamp,
+			To:       b.Timestamp,
+			Distance: getHaversineDistance(*a.Point, *b.Point),
+		}
+
+		// note: simultaneous points get no speed rather than an infinite one (which can't be represented in JSON)
+		seconds := b.Timestamp.Sub(a.Timestamp).Seconds()
+		if seconds > 0 {
+			segment.Speed = segment.Distance / seconds
+		}
+
+		track.Segments = append(track.Segments, segment)
+		track.TotalDistance += segment.Distance
+		track.MaxSpeed = max(track.MaxSpeed, segment.Speed)
+	}
+
+	// a dwell is a maximal run of stationary segments; it's considered at the end of each run
+	start := -1
+	for i := 0; i <= len(track.Segments); i++ {
+		if i < len(track.Segments) {
+			segment := track.Segments[i]
+
+			stationary := segment.Speed <= options.StopSpeed && (segment.To.After(segment.From) || segment.Distance == 0)
+			if stationary {
+				if start == -1 {
+					start = i
+				}
+
+				continue
+			}
+		}
+
+		if start == -1 {
+			continue
+		}
+
+		from, to := track.Segments[start].From, track.Segments[i-1].To
+		if to.Sub(from) >= options.StopDuration {
+			track.Dwells = append(track.Dwells, &TrackDwell{
+				From:     from,
+				To:       to,
+				Duration: to.Sub(from).Seconds(),
+				Point:    *track.Points[start].Point,
+			})
+		}
+
+		start = -1
+	}
+
+	return track
+}
+
+func getTrackWheres(physicalThingID any, from *time.Time, to *time.Time) ([]string, []any) {
+	wheres := []string{
+		fmt.Sprintf("%s = $$??", djangolang_example.LocationHistoryTableParentPhysicalThingIDColumn),
+		fmt.Sprintf("%s IS NOT null", djangolang_example.LocationHistoryTablePointColumn),
+	}
+
+	values := []any{physicalThingID}
+
+	if from != nil {
+		wheres = append(wheres, fmt.Sprintf("%s >= $$??", djangolang_example.LocationHistoryTableTimestampColumn))
+		values = append(values, *from)
+	}
+
+	if to != nil {
+		wheres = append(wheres, fmt.Sprintf("%s < $$??", djangolang_example.LocationHistoryTableTimestampColumn))
+		values = append(values, *to)
+	}
+
+	return wheres, values
+}
+
+// GetTrack returns the Track of the given physical thing between from (inclusive) and to (exclusive), either of which
+// may be nil to leave that end of the window open
+func GetTrack(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	physicalThingID uuid.UUID,
+	from *time.Time,
+	to *time.Time,
+	options TrackOptions,
+) (*Track, error) {
+	wheres, values := getTrackWheres(physicalThingID, from, to)
+
+	orderBy := formatOrderBy(
+		[]orderByColumn{
+			{Column: djangolang_example.LocationHistoryTableTimestampColumn},
+			{Column: djangolang_example.LocationHistoryTablePrimaryKeyColumn},
+		},
+		false,
+		nil,
+	)
+
+	// note: the points all belong to the same physical thing, so there's no point loading it for each of them
+	points, err := SelectLocationHistorysWithOptions(WithExpansionDepth(ctx, 0), tx, strings.Join(wheres, "\n    AND "), SelectOptions{OrderBy: &orderBy}, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTrack(points, options), nil
+}
+
+func parseTrackTime(r *http.Request, param string) (*time.Time, error) {
+	rawTime := r.URL.Query().Get(param)
+	if rawTime == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, rawTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse param %s=%s: %v", param, rawTime, err)
+	}
+
+	return &t, nil
+}
+
+func handleGetPhysicalThingTrack(w http.ResponseWriter, r *http.Request, db *sqlx.DB, redisConn redis.Conn, modelMiddlewares []server.ModelMiddleware, primaryKey string) {
+	ctx := r.Context()
+
+	physicalThingID, err := uuid.Parse(primaryKey)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to parse primary key %s: %v", primaryKey, err))
+		return
+	}
+
+	from, err := parseTrackTime(r, "from")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	to, err := parseTrackTime(r, "to")
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	options := TrackOptions{
+		StopSpeed:    defaultTrackStopSpeed,
+		StopDuration: defaultTrackStopDuration,
+	}
+
+	rawStopSpeed := r.URL.Query().Get("stop_speed")
+	if rawStopSpeed != "" {
+		options.StopSpeed, err = strconv.ParseFloat(rawStopSpeed, 64)
+		if err != nil || options.StopSpeed < 0 {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param stop_speed=%s as non-negative number: %v", rawStopSpeed, err),
+			)
+			return
+		}
+	}
+
+	rawStopDuration := r.URL.Query().Get("stop_duration")
+	if rawStopDuration != "" {
+		options.StopDuration, err = time.ParseDuration(rawStopDuration)
+		if err != nil || options.StopDuration < 0 {
+			helpers.HandleErrorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("failed to parse param stop_duration=%s as non-negative duration: %v", rawStopDuration, err),
+			)
+			return
+		}
+	}
+
+	wheres, values := getTrackWheres(physicalThingID, from, to)
+
+	requestHash, err := getDerivedRequestHash(
+		djangolang_example.LocationHistoryTable,
+		wheres,
+		values,
+		"TRACK",
+		fmt.Sprintf("STOP SPEED %v", options.StopSpeed),
+		fmt.Sprintf("STOP DURATION %v", options.StopDuration),
+	)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cacheHit, err := helpers.AttemptCachedResponse(requestHash, redisConn, w)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if cacheHit {
+		return
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	track, err := GetTrack(ctx, tx, physicalThingID, from, to, options)
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		helpers.HandleErrorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnedObjectsAsJSON := helpers.HandleObjectsResponse(w, http.StatusOK, []*Track{track})
+
+	err = helpers.StoreCachedResponse(requestHash, redisConn, string(returnedObjectsAsJSON))
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package extensions
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/initialed85/djangolang_example/pkg/djangolang_example"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// metresPerDegree is the length of a degree of latitude (or of longitude at the equator)
+const metresPerDegree = earthRadius * math.Pi / 180
+
+func TestGetHaversineDistance(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        pgtype.Vec2
+		b        pgtype.Vec2
+		expected float64
+	}{
+		{name: "same point", a: pgtype.Vec2{X: 138.6, Y: -34.9}, b: pgtype.Vec2{X: 138.6, Y: -34.9}, expected: 0},
+		{name: "a degree of latitude", a: pgtype.Vec2{X: 138.6, Y: -35}, b: pgtype.Vec2{X: 138.6, Y: -34}, expected: metresPerDegree},
+		{name: "a degree of longitude at the equator", a: pgtype.Vec2{X: 0, Y: 0}, b: pgtype.Vec2{X: 1, Y: 0}, expected: metresPerDegree},
+		{name: "a degree of longitude at 60 degrees", a: pgtype.Vec2{X: 0, Y: 60}, b: pgtype.Vec2{X: 1, Y: 60}, expected: 55597.1},
+		{name: "across the antimeridian", a: pgtype.Vec2{X: 179.5, Y: 0}, b: pgtype.Vec2{X: -179.5, Y: 0}, expected: metresPerDegree},
+		{name: "antipodes", a: pgtype.Vec2{X: 0, Y: 0}, b: pgtype.Vec2{X: 180, Y: 0}, expected: earthRadius * math.Pi},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			distance := getHaversineDistance(testCase.a, testCase.b)
+			if math.Abs(distance-testCase.expected) > 0.1 {
+				t.Fatalf("expected %v but got %v", testCase.expected, distance)
+			}
+		})
+	}
+}
+
+func TestNewTrack(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2024, 1, 2, 3, minute, 0, 0, time.UTC)
+	}
+
+	// north is a point that many metres north of the equator
+	north := func(metres float64) *pgtype.Vec2 {
+		return &pgtype.Vec2{X: 0, Y: metres / metresPerDegree}
+	}
+
+	points := []*djangolang_example.LocationHistory{
+		{Timestamp: at(0), Point: north(0)},
+		{Timestamp: at(1), Point: north(600)},
+		{Timestamp: at(2), Polygon: &[]pgtype.Vec2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+		{Timestamp: at(3), Point: north(610)},
+		{Timestamp: at(8), Point: north(620)},
+		{Timestamp: at(8), Point: north(620)},
+		{Timestamp: at(9), Point: north(1820)},
+		{Timestamp: at(10), Point: north(1820)},
+	}
+
+	track := NewTrack(points, TrackOptions{StopSpeed: defaultTrackStopSpeed, StopDuration: defaultTrackStopDuration})
+
+	if len(track.Points) != 7 {
+		t.Fatalf("expected the polygon to be left out but got %d points", len(track.Points))
+	}
+
+	expectedSpeeds := []float64{10, 10.0 / 120, 10.0 / 300, 0, 20, 0}
+	if len(track.Segments) != len(expectedSpeeds) {
+		t.Fatalf("expected %d segments but got %d", len(expectedSpeeds), len(track.Segments))
+	}
+
+	for i, segment := range track.Segments {
+		if math.Abs(segment.Speed-expectedSpeeds[i]) > 0.001 {
+			t.Fatalf("segment %d: expected speed %v but got %v", i, expectedSpeeds[i], segment.Speed)
+		}
+	}
+
+	if math.Abs(track.TotalDistance-1820) > 0.01 {
+		t.Fatalf("expected total distance %v but got %v", 1820, track.TotalDistance)
+	}
+
+	if math.Abs(track.MaxSpeed-20) > 0.001 {
+		t.Fatalf("expected max speed %v but got %v", 20, track.MaxSpeed)
+	}
+
+	// the stop from minute 1 to 8 is long enough to be a dwell (the simultaneous points don't interrupt it) but the one
+	// at the end isn't
+	if len(track.Dwells) != 1 {
+		t.Fatalf("expected 1 dwell but got %#+v", track.Dwells)
+	}
+
+	dwell := track.Dwells[0]
+	if !dwell.From.Equal(at(1)) || !dwell.To.Equal(at(8)) || dwell.Duration != 420 || dwell.Point != *north(600) {
+		t.Fatalf("expected a dwell from %v to %v but got %#+v", at(1), at(8), dwell)
+	}
+}
+
+func TestNewTrackWithoutPoints(t *testing.T) {
+	track := NewTrack(nil, TrackOptions{StopSpeed: defaultTrackStopSpeed, StopDuration: defaultTrackStopDuration})
+
+	if len(track.Points) != 0 || len(track.Segments) != 0 || len(track.Dwells) != 0 || track.TotalDistance != 0 {
+		t.Fatalf("expected an empty track but got %#+v", track)
+	}
+}